@@ -0,0 +1,47 @@
+package mimc
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/compress"
+)
+
+/**
+ * 消息压缩配置。
+ * Codec: compress.CODEC_GZIP、compress.CODEC_SNAPPY或通过compress.Register注册的算法
+ * Threshold: 超过该字节数的payload才会尝试压缩
+ */
+type CompressionConfig struct {
+	Codec     byte
+	Threshold int
+}
+
+func DefaultCompressionConfig() CompressionConfig {
+	return CompressionConfig{Codec: compress.CODEC_GZIP, Threshold: cnst.COMPRESS_THRESHOLD}
+}
+
+// 开启发送端压缩，接收端总是会自动解压带有压缩信封头的消息
+func (this *MCUser) EnableCompression(config CompressionConfig) *MCUser {
+	this.compressionConfig = &config
+	return this
+}
+
+func (this *MCUser) compressPayload(payload []byte) []byte {
+	if this.compressionConfig == nil || len(payload) <= this.compressionConfig.Threshold {
+		return payload
+	}
+	data, compressed, err := compress.Encode(this.compressionConfig.Codec, payload)
+	if err != nil {
+		this.Logger().Warn("compress payload fail, send it uncompressed: %v", err)
+		return payload
+	}
+	if compressed {
+		this.Logger().Debug("compress payload: %v -> %v bytes.", len(payload), len(data))
+	}
+	return data
+}
+
+// 解压失败时认为不是SDK压缩的消息，原样返回
+func (this *MCUser) decompressPayload(payload []byte) []byte {
+	decoder := PayloadDecoder{Logger: this.Logger()}
+	return decoder.decompress(payload)
+}
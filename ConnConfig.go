@@ -0,0 +1,86 @@
+package mimc
+
+import (
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/protocol"
+	"strconv"
+	"strings"
+)
+
+/**
+ * 与服务端协商得到的连接配置。
+ * Peer为当前连接的地址，RedirectHost为服务端在CONN响应中要求切换到的地址，
+ * 其余字段来自CONN响应中的PushServiceConfigMsg。
+ */
+type ConnConfig struct {
+	Peer          string
+	RedirectHost  string
+	FetchBucket   bool
+	UseBucketV2   bool
+	ClientVersion int32
+	CloudVersion  int32
+	Dots          int32
+}
+
+func newConnConfig(peer *Peer, redirectHost string, psc *protocol.PushServiceConfigMsg) ConnConfig {
+	config := ConnConfig{RedirectHost: redirectHost}
+	if peer != nil {
+		config.Peer = peer.ToString()
+	}
+	config.FetchBucket = psc.GetFetchBucket()
+	config.UseBucketV2 = psc.GetUseBucketV2()
+	config.ClientVersion = psc.GetClientVersion()
+	config.CloudVersion = psc.GetCloudVersion()
+	config.Dots = psc.GetDots()
+	return config
+}
+
+// 解析服务端下发的host，未带端口时沿用当前连接的端口
+func parseRedirectPeer(host string, current *Peer) *Peer {
+	host = strings.TrimSpace(host)
+	if len(host) == 0 {
+		return nil
+	}
+	port := 0
+	if current != nil {
+		port = current.Port()
+	}
+	if index := strings.LastIndex(host, ":"); index > 0 {
+		if p, err := strconv.Atoi(host[index+1:]); err == nil {
+			port = p
+			host = host[:index]
+		}
+	}
+	if port <= 0 {
+		return nil
+	}
+	return new(Peer).SetHost(host).SetPort(port)
+}
+
+// 合并服务端下发的配置，未下发的字段保留旧值
+func mergePsc(local, remote *protocol.PushServiceConfigMsg) *protocol.PushServiceConfigMsg {
+	if remote == nil {
+		return local
+	}
+	merged := new(protocol.PushServiceConfigMsg)
+	if local != nil {
+		*merged = *local
+	}
+	if remote.FetchBucket != nil {
+		merged.FetchBucket = remote.FetchBucket
+	}
+	if remote.UseBucketV2 != nil {
+		merged.UseBucketV2 = remote.UseBucketV2
+	}
+	if remote.CloudVersion != nil {
+		merged.CloudVersion = remote.CloudVersion
+	}
+	if remote.Dots != nil {
+		merged.Dots = remote.Dots
+	}
+	if remote.ClientVersion != nil {
+		merged.ClientVersion = remote.ClientVersion
+	}
+	merged.XXX_unrecognized = nil
+	return merged
+}
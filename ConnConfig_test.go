@@ -0,0 +1,189 @@
+package mimc
+
+import (
+	"fmt"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/protocol"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyConnResp(t *testing.T) {
+	conn := NewConn()
+	conn.peer = new(Peer).SetHost("app.chat.xiaomi.net").SetPort(80)
+
+	cloudVersion := int32(7)
+	fetchBucket := true
+	if conn.ApplyConnResp("app.chat.xiaomi.net", &protocol.PushServiceConfigMsg{CloudVersion: &cloudVersion}) {
+		t.Errorf("same host should not redirect")
+	}
+	if !conn.ApplyConnResp("10.0.0.1:5222", &protocol.PushServiceConfigMsg{FetchBucket: &fetchBucket}) {
+		t.Fatalf("different host should redirect")
+	}
+	config := conn.Config()
+	if config.RedirectHost != "10.0.0.1:5222" || conn.redirectPeer.ToString() != "10.0.0.1:5222" {
+		t.Errorf("unexpected redirect: %+v", config)
+	}
+	if !config.FetchBucket || config.CloudVersion != 7 {
+		t.Errorf("psc should be merged: %+v", config)
+	}
+	if psc := conn.PscToReport(); psc.GetClientVersion() != 7 {
+		t.Errorf("should report applied cloud version, got %v", psc)
+	}
+}
+
+func TestRedirectLimit(t *testing.T) {
+	conn := NewConn()
+	conn.peer = new(Peer).SetHost("app.chat.xiaomi.net").SetPort(80)
+	for i := 0; i < cnst.MAX_REDIRECTS; i++ {
+		if !conn.ApplyConnResp(fmt.Sprintf("10.0.0.%v", i+1), nil) {
+			t.Fatalf("redirect %v should be applied", i+1)
+		}
+	}
+	if conn.ApplyConnResp("10.0.0.100", nil) {
+		t.Errorf("redirects beyond the limit should be ignored")
+	}
+	conn.ResetRedirects()
+	if !conn.ApplyConnResp("10.0.0.100", nil) {
+		t.Errorf("redirect should be applied after login")
+	}
+	conn.ClearRedirect()
+	if config := conn.Config(); config.RedirectHost != "" || conn.redirectPeer != nil {
+		t.Errorf("redirect should be cleared, got %+v", config)
+	}
+}
+
+type changeCounter struct {
+	nopDelegate
+	changes int
+}
+
+func (this *changeCounter) HandleChange(isOnline bool, errType, errReason, errDescription *string) {
+	this.changes++
+}
+
+func TestRedirectResetQuietly(t *testing.T) {
+	counter := new(changeCounter)
+	user := NewUser("alice").RegisterStatusDelegate(counter)
+	user.heartbeat = newHeartbeat()
+	conn := NewConn().User(user)
+	conn.status = HANDSHAKE_CONNECTED
+	conn.reset(false)
+	if counter.changes != 0 || conn.Status() != NOT_CONNECTED {
+		t.Errorf("redirect reset should not report status, changes: %v", counter.changes)
+	}
+	conn.status = HANDSHAKE_CONNECTED
+	conn.Reset()
+	if counter.changes != 1 {
+		t.Errorf("network reset should report status, changes: %v", counter.changes)
+	}
+}
+
+func TestParseRedirectPeer(t *testing.T) {
+	current := new(Peer).SetHost("a").SetPort(80)
+	if peer := parseRedirectPeer("b", current); peer.ToString() != "b:80" {
+		t.Errorf("host without port should reuse current port, got %v", peer.ToString())
+	}
+	if peer := parseRedirectPeer(" ", current); peer != nil {
+		t.Errorf("blank host should be ignored")
+	}
+}
+
+func TestConnConfigBeforeSetup(t *testing.T) {
+	user := NewUser("alice")
+	if config := user.ConnConfig(); config != (ConnConfig{}) {
+		t.Errorf("expect zero config before InitAndSetup, got %+v", config)
+	}
+	if rtt := user.PingRtt(); rtt != 0 {
+		t.Errorf("expect zero rtt before InitAndSetup, got %v", rtt)
+	}
+}
+
+type staticFetcher struct {
+	peer *Peer
+}
+
+func (this staticFetcher) FetchPeer() *Peer {
+	return this.peer
+}
+
+func TestPeerFetcherSurvivesReset(t *testing.T) {
+	fetcher := staticFetcher{new(Peer).SetHost("127.0.0.1").SetPort(1)}
+	user := NewUser("alice").SetPeerFetcher(fetcher)
+	user.InitAndSetup()
+	defer closeAndWait(t, user)
+	if user.conn.peerFetcher != fetcher {
+		t.Fatalf("peerFetcher set before InitAndSetup should be used")
+	}
+	other := NewUser("bob")
+	other.heartbeat = newHeartbeat()
+	conn := NewConn().User(other).PeerFetcher(fetcher)
+	conn.status = HANDSHAKE_CONNECTED
+	conn.reset(false)
+	if conn.peerFetcher != fetcher {
+		t.Errorf("peerFetcher should be kept after reset")
+	}
+}
+
+// InitAndSetup创建的收发、触发、回调goroutine的数量，包括还没有开始运行的
+func userRoutines() int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	return strings.Count(string(buf), "created by github.com/Xiaomi-mimc/mimc-go-sdk.(*MCUser).InitAndSetup")
+}
+
+// 等待goroutine退出到不超过max个
+func waitUserRoutines(t *testing.T, max int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for count := userRoutines(); count > max; count = userRoutines() {
+		if time.Now().After(deadline) {
+			t.Fatalf("user routines should exit, %v > %v", count, max)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// 关闭用户并等待它的4个goroutine退出，避免影响之后的测试
+func closeAndWait(t *testing.T, user *MCUser) {
+	running := userRoutines()
+	if !user.Close() {
+		t.Fatalf("close fail")
+	}
+	waitUserRoutines(t, running-4)
+}
+
+func TestCloseStopsRoutines(t *testing.T) {
+	if NewUser("alice").Close() {
+		t.Errorf("close before InitAndSetup should return false")
+	}
+	baseline := userRoutines()
+	user := NewUser("alice").SetPeerFetcher(staticFetcher{new(Peer).SetHost("127.0.0.1").SetPort(1)})
+	user.InitAndSetup()
+	user.setTryLogin(true)
+	running := userRoutines()
+	if running != baseline+4 {
+		t.Fatalf("expect 4 routines, got %v", running-baseline)
+	}
+	// 等待goroutine进入循环，连接失败后会一直重试
+	time.Sleep(300 * time.Millisecond)
+	if !user.Close() || user.Close() {
+		t.Fatalf("close should succeed only once")
+	}
+	if user.Logout() || user.tryLogin {
+		t.Errorf("logout after close should clear tryLogin and return false")
+	}
+	waitUserRoutines(t, running-4)
+	if user.Status() != Offline || user.conn.Status() != NOT_CONNECTED {
+		t.Errorf("closed user should be offline")
+	}
+}
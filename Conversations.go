@@ -0,0 +1,55 @@
+package mimc
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/conversation"
+)
+
+/**
+ * 开启会话列表，在发送和接收路径上维护每个单聊对方和topic的最后一条消息、未读数和已读位置。
+ * conversationStore为nil时只保存在内存中。
+ */
+func (this *MCUser) EnableConversations(conversationStore conversation.Store) error {
+	tracker, err := conversation.NewTracker(conversationStore)
+	if err != nil {
+		return err
+	}
+	this.conversations = tracker
+	return nil
+}
+
+func (this *MCUser) RegisterConversationDelegate(conversationDelegate ConversationDelegate) *MCUser {
+	this.conversationDelegate = conversationDelegate
+	return this
+}
+
+// 按最后一条消息的时间从新到旧返回所有会话，没有开启会话列表时返回nil
+func (this *MCUser) Conversations() []*conversation.Conversation {
+	if this.conversations == nil {
+		return nil
+	}
+	return this.conversations.List()
+}
+
+func (this *MCUser) Conversation(id string) *conversation.Conversation {
+	if this.conversations == nil {
+		return nil
+	}
+	return this.conversations.Get(id)
+}
+
+func (this *MCUser) DeleteConversation(id string) error {
+	if this.conversations == nil {
+		return nil
+	}
+	return this.conversations.Delete(id)
+}
+
+func (this *MCUser) notifyConversation(changed *conversation.Conversation, err error) {
+	if err != nil {
+		this.Logger().Warn("[conversation] save conversation fail: %v", err)
+	}
+	if changed == nil || this.conversationDelegate == nil {
+		return
+	}
+	this.conversationDelegate.HandleConversationChange(changed)
+}
@@ -0,0 +1,56 @@
+package mimc
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/conversation"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/map"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/queue"
+)
+
+type conversationCollector struct {
+	changes []*conversation.Conversation
+}
+
+func (this *conversationCollector) HandleConversationChange(conversation *conversation.Conversation) {
+	this.changes = append(this.changes, conversation)
+}
+
+func TestConversations(t *testing.T) {
+	user := NewUser("Alice")
+	user.messageToSend = que.NewConQueue()
+	user.messageToAck = cmap.NewConMap()
+	if err := user.MarkRead("Bob", 1); err != ErrReceiptsDisabled {
+		t.Errorf("expect ErrReceiptsDisabled, got %v", err)
+	}
+	collector := new(conversationCollector)
+	user.EnableConversations(nil)
+	user.RegisterConversationDelegate(collector)
+
+	packetId := user.SendMessage("Bob", []byte("hello"))
+	user.recordStatus(packetId, store.STATUS_ACKED, 7, CurrentTimeMillis())
+	packetIdReceived, from, to, sequence, timestamp := "p_received", "Bob", "Alice", int64(8), CurrentTimeMillis()+1
+	received := list.New()
+	received.PushBack(msg.NewP2pMsg(&packetIdReceived, &from, &to, &sequence, &timestamp, []byte("hi")))
+	user.recordReceived(received)
+	if len(collector.changes) != 3 {
+		t.Fatalf("expect 3 changes, got %v", len(collector.changes))
+	}
+
+	bob := user.Conversation(store.P2PConversation("Bob"))
+	if bob == nil || bob.UnreadCount != 1 || bob.LastSequence != 8 || bob.LastMessage.PacketId != packetIdReceived {
+		t.Fatalf("unexpected conversation: %+v", bob)
+	}
+	if err := user.MarkRead("Bob", 8); err != nil {
+		t.Fatalf("mark read fail: %v", err)
+	}
+	if changed := collector.changes[len(collector.changes)-1]; changed.UnreadCount != 0 || changed.LastReadSequence != 8 {
+		t.Errorf("mark read should clear unread: %+v", changed)
+	}
+	if conversations := user.Conversations(); len(conversations) != 1 {
+		t.Errorf("expect 1 conversation, got %v", len(conversations))
+	}
+}
@@ -0,0 +1,84 @@
+package mimc
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/e2e"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+/**
+ * 端到端加密配置。
+ * Identity: 当前账号的身份密钥，私钥只保存在客户端
+ * Directory: 账号公钥目录，发送时查询接收方公钥，接收时查询发送方公钥
+ * RequireEncryption: 为true时丢弃未加密的消息并通过ErrorDelegate报告，没有sender key的topic也不能发送群聊消息
+ */
+type E2EConfig struct {
+	Identity          *e2e.Identity
+	Directory         e2e.KeyDirectory
+	RequireEncryption bool
+}
+
+/**
+ * 开启端到端加密，会把当前账号的公钥发布到公钥目录。
+ * 开启后SendMessage在接收方公钥不存在时发送失败，不会降级为明文发送；
+ * 群聊需要再通过SetTopicMembers开启。
+ */
+func (this *MCUser) EnableE2E(config E2EConfig) error {
+	if config.Identity == nil || config.Directory == nil {
+		return e2e.ErrInvalidKey
+	}
+	if err := config.Directory.SetPublicKey(this.appAccount, config.Identity.PublicKey()); err != nil {
+		return err
+	}
+	this.groupKeys = newGroupKeys()
+	this.e2eConfig = &config
+	return nil
+}
+
+func (this *MCUser) encryptPayload(toAppAccount string, payload []byte) ([]byte, bool) {
+	if this.e2eConfig == nil {
+		return payload, true
+	}
+	recipientKey, err := this.e2eConfig.Directory.PublicKey(toAppAccount)
+	if err == nil {
+		payload, err = e2e.Seal(this.e2eConfig.Identity, this.appAccount, toAppAccount, recipientKey, payload)
+	}
+	if err != nil {
+		this.Logger().Warn("[Send P2P Msg] encrypt message to %v fail: %v", toAppAccount, err)
+		this.reportError(&E2EError{Account: toAppAccount, Err: err})
+		return nil, false
+	}
+	return payload, true
+}
+
+// 解密并验证收到的单聊消息，返回false表示消息验证失败需要丢弃
+func (this *MCUser) decryptPayload(fromAppAccount string, packetId string, payload []byte) ([]byte, bool) {
+	data, err := this.openPayload(fromAppAccount, payload)
+	if err != nil {
+		this.reportE2EError(fromAppAccount, packetId, err)
+		return nil, false
+	}
+	return data, true
+}
+
+// PayloadDecoder的Decrypt，没有开启端到端加密时原样返回
+func (this *MCUser) openPayload(fromAppAccount string, payload []byte) ([]byte, error) {
+	if this.e2eConfig == nil {
+		return payload, nil
+	}
+	if !e2e.IsEncrypted(payload) {
+		if this.e2eConfig.RequireEncryption {
+			return nil, ErrNotEncrypted
+		}
+		return payload, nil
+	}
+	senderKey, err := this.e2eConfig.Directory.PublicKey(fromAppAccount)
+	if err != nil {
+		return nil, err
+	}
+	return e2e.Open(this.e2eConfig.Identity, fromAppAccount, this.appAccount, senderKey, payload)
+}
+
+func (this *MCUser) reportE2EError(fromAppAccount string, packetId string, err error) {
+	this.Logger().With(log.FieldPacketId, packetId).Warn("[handle packet] drop message from %v: %v", fromAppAccount, err)
+	this.reportError(&E2EError{Account: fromAppAccount, PacketId: packetId, Err: err})
+}
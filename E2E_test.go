@@ -0,0 +1,124 @@
+package mimc
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/e2e"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/map"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/queue"
+)
+
+type errorCollector struct {
+	errs []error
+}
+
+func (this *errorCollector) HandleError(err error) {
+	this.errs = append(this.errs, err)
+}
+
+func TestE2EPayload(t *testing.T) {
+	directory := e2e.NewMemoryDirectory()
+	alice, bob, carol := NewUser("Alice"), NewUser("Bob"), NewUser("Carol")
+	for _, user := range []*MCUser{alice, bob} {
+		identity, _ := e2e.GenerateIdentity()
+		if err := user.EnableE2E(E2EConfig{Identity: identity, Directory: directory, RequireEncryption: true}); err != nil {
+			t.Fatalf("enable e2e fail: %v", err)
+		}
+	}
+	collector := new(errorCollector)
+	alice.RegisterErrorDelegate(collector)
+	bob.RegisterErrorDelegate(collector)
+
+	if _, ok := alice.encryptPayload("Carol", []byte("hi")); ok || !errors.Is(collector.errs[0], e2e.ErrNoPublicKey) {
+		t.Fatalf("send to account without public key should fail")
+	}
+	envelope, ok := alice.encryptPayload("Bob", []byte("hi bob"))
+	if !ok {
+		t.Fatalf("encrypt fail: %v", collector.errs)
+	}
+	if data, ok := bob.decryptPayload("Alice", "p1", envelope); !ok || !bytes.Equal(data, []byte("hi bob")) {
+		t.Fatalf("decrypt fail: %v", collector.errs)
+	}
+	if _, ok := carol.decryptPayload("Alice", "p1", envelope); !ok {
+		t.Errorf("user without e2e should pass payload through")
+	}
+	if _, ok := bob.decryptPayload("Alice", "p2", []byte("plain")); ok || !errors.Is(collector.errs[1], ErrNotEncrypted) {
+		t.Errorf("plain message should be rejected when encryption is required")
+	}
+	if _, ok := bob.decryptPayload("Carol", "p3", envelope); ok || !errors.Is(collector.errs[2], e2e.ErrNoPublicKey) {
+		t.Errorf("message from unknown sender should be rejected")
+	}
+}
+
+type groupMessageCollector struct {
+	MessageHandlerDelegate
+	messages []*msg.P2TMessage
+}
+
+func (this *groupMessageCollector) HandleGroupMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.messages = append(this.messages, ele.Value.(*msg.P2TMessage))
+	}
+}
+
+func TestGroupE2E(t *testing.T) {
+	directory := e2e.NewMemoryDirectory()
+	alice, bob, carol := NewUser("Alice"), NewUser("Bob"), NewUser("Carol")
+	collector := new(errorCollector)
+	messages := new(groupMessageCollector)
+	for _, user := range []*MCUser{alice, bob, carol} {
+		user.messageToSend = que.NewConQueue()
+		user.messageToAck = cmap.NewConMap()
+		user.internalPackets = cmap.NewConMap()
+		identity, _ := e2e.GenerateIdentity()
+		user.EnableE2E(E2EConfig{Identity: identity, Directory: directory})
+		user.RegisterErrorDelegate(collector)
+	}
+	bob.RegisterMessageDelegate(messages)
+	topicId := int64(100)
+	if err := alice.SetTopicMembers(topicId, []string{"Alice", "Bob", "Carol"}); err != nil {
+		t.Fatalf("set topic members fail: %v", err)
+	}
+	if alice.internalPackets.Size() != 2 {
+		t.Errorf("sender key should be distributed to 2 members, got %v", alice.internalPackets.Size())
+	}
+	bob.SetTopicMembers(topicId, []string{"Alice", "Bob", "Carol"})
+	oldKey := alice.groupKeys.senderKey(topicId)
+	distribution := oldKey.Distribution(topicId)
+
+	newGroupMessage := func(packetId string, payload []byte) *pendingGroupMessage {
+		from := "Alice"
+		return &pendingGroupMessage{arrived: CurrentTimeMillis(), packetId: &packetId, fromAccount: &from, sequence: new(int64), timestamp: new(int64), topicId: &topicId, payload: payload}
+	}
+	envelope, ok := alice.encryptGroupPayload(topicId, []byte("hello group"))
+	if !ok || !e2e.IsGroupEncrypted(envelope) {
+		t.Fatalf("group message should be encrypted")
+	}
+	// sender key分发消息晚于群聊消息到达
+	if bob.openGroupMessage(newGroupMessage("p1", envelope)) != nil {
+		t.Fatalf("message should wait for sender key")
+	}
+	if !bob.handleSenderKeyDistribution("Alice", "d1", true, distribution) {
+		t.Fatalf("distribution should be consumed")
+	}
+	if len(messages.messages) != 1 || string(messages.messages[0].Payload()) != "hello group" {
+		t.Fatalf("pending message should be delivered after sender key arrives, errors: %v", collector.errs)
+	}
+
+	// Carol离开后Alice轮换sender key
+	alice.SetTopicMembers(topicId, []string{"Alice", "Bob"})
+	if alice.groupKeys.senderKey(topicId) == oldKey {
+		t.Fatalf("sender key should be rotated when a member leaves")
+	}
+	if bob.handleSenderKeyDistribution("Carol", "d2", false, oldKey.Distribution(topicId)); !errors.Is(collector.errs[0], ErrNotEncrypted) {
+		t.Errorf("unencrypted distribution should be rejected, got %v", collector.errs)
+	}
+	bob.SetTopicMembers(topicId, []string{"Alice", "Bob"})
+	if bob.handleSenderKeyDistribution("Carol", "d3", true, oldKey.Distribution(topicId)); !errors.Is(collector.errs[1], ErrNotTopicMember) {
+		t.Errorf("distribution from non-member should be rejected, got %v", collector.errs)
+	}
+}
@@ -0,0 +1,70 @@
+package mimc
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/envelope"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/id"
+)
+
+/**
+ * 以应用层信封格式发送单聊消息，没有设置ClientMsgId和SentAt时由SDK生成。
+ * 接收方通过P2PMessage.Envelope()解析。
+ */
+func (this *MCUser) SendEnvelope(toAppAccount string, env *envelope.Envelope) string {
+	payload := this.encodeEnvelope(env)
+	if payload == nil {
+		return ""
+	}
+	return this.SendMessage(toAppAccount, payload)
+}
+
+func (this *MCUser) SendText(toAppAccount string, text string) string {
+	return this.SendEnvelope(toAppAccount, envelope.NewText(text))
+}
+
+func (this *MCUser) SendJSON(toAppAccount string, v interface{}) string {
+	env, err := envelope.NewJSON(v)
+	if err != nil {
+		this.Logger().Warn("[Send P2P Msg] marshal json fail: %v", err)
+		return ""
+	}
+	return this.SendEnvelope(toAppAccount, env)
+}
+
+func (this *MCUser) SendGroupEnvelope(topicId *int64, env *envelope.Envelope) string {
+	payload := this.encodeEnvelope(env)
+	if payload == nil {
+		return ""
+	}
+	return this.SendGroupMessage(topicId, payload)
+}
+
+func (this *MCUser) SendGroupText(topicId *int64, text string) string {
+	return this.SendGroupEnvelope(topicId, envelope.NewText(text))
+}
+
+func (this *MCUser) SendGroupJSON(topicId *int64, v interface{}) string {
+	env, err := envelope.NewJSON(v)
+	if err != nil {
+		this.Logger().Warn("[Send P2T Msg] marshal json fail: %v", err)
+		return ""
+	}
+	return this.SendGroupEnvelope(topicId, env)
+}
+
+func (this *MCUser) encodeEnvelope(env *envelope.Envelope) []byte {
+	if env == nil {
+		return nil
+	}
+	if len(env.ClientMsgId) == 0 {
+		env.ClientMsgId = *(id.Generate())
+	}
+	if env.SentAt == 0 {
+		env.SentAt = CurrentTimeMillis()
+	}
+	payload, err := envelope.Encode(env)
+	if err != nil {
+		this.Logger().Warn("encode envelope fail: %v", err)
+		return nil
+	}
+	return payload
+}
@@ -0,0 +1,192 @@
+package mimc
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/fragment"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/id"
+	"strconv"
+	"sync"
+)
+
+/**
+ * 大消息分片配置。
+ * FragmentSize: 单个分片数据部分的最大字节数，超过该大小的消息会被分片发送；接收端拒绝分片数超过MaxReassembleBytes/FragmentSize的消息
+ * ReassembleTimeoutMs: 接收端等待一个消息所有分片的最长时间
+ * MaxReassembleBytes: 接收端缓存未收齐分片的最大字节数
+ */
+type FragmentConfig struct {
+	FragmentSize        int
+	ReassembleTimeoutMs int64
+	MaxReassembleBytes  int
+}
+
+func DefaultFragmentConfig() FragmentConfig {
+	return FragmentConfig{
+		FragmentSize:        cnst.FRAGMENT_SIZE,
+		ReassembleTimeoutMs: cnst.REASSEMBLE_TIMEOUT_MS,
+		MaxReassembleBytes:  cnst.MAX_REASSEMBLE_BYTES,
+	}
+}
+
+type fragmentedSend struct {
+	total    int
+	acked    int
+	resolved int
+	failed   bool
+}
+
+// 跟踪分片发送的结果，保证每个消息只报告一次完成或失败
+type fragmentSender struct {
+	mu       sync.Mutex
+	packets  map[string]string
+	messages map[string]*fragmentedSend
+}
+
+func newFragmentSender() *fragmentSender {
+	this := new(fragmentSender)
+	this.packets = make(map[string]string)
+	this.messages = make(map[string]*fragmentedSend)
+	return this
+}
+
+// 在发送第一个分片前登记消息，total为分片数
+func (this *fragmentSender) track(msgId string, total int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.messages[msgId] = &fragmentedSend{total: total}
+}
+
+// 登记分片的packetId，在分片进入发送队列前调用，避免ack先于登记到达；发送时不持有锁
+func (this *fragmentSender) add(msgId string, packetId string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.packets[packetId] = msgId
+}
+
+/**
+ * 处理一个分片的ack或超时，packetId不属于分片消息时isFragment为false。
+ * 消息的所有分片都被ack时completed为true，第一个分片超时时failed为true。
+ */
+func (this *fragmentSender) resolve(packetId string, acked bool) (msgId string, state fragmentedSend, isFragment, completed, failed bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	msgId, isFragment = this.packets[packetId]
+	if !isFragment {
+		return
+	}
+	delete(this.packets, packetId)
+	message := this.messages[msgId]
+	message.resolved += 1
+	if acked {
+		message.acked += 1
+	} else if !message.failed {
+		message.failed = true
+		failed = true
+	}
+	completed = !message.failed && message.acked == message.total
+	if message.resolved == message.total {
+		delete(this.messages, msgId)
+	}
+	state = *message
+	return
+}
+
+// 开启大消息分片，发送端和接收端都需要开启
+func (this *MCUser) EnableFragmentation(config FragmentConfig) *MCUser {
+	if config.FragmentSize <= 0 {
+		config.FragmentSize = cnst.FRAGMENT_SIZE
+	}
+	if config.ReassembleTimeoutMs <= 0 {
+		config.ReassembleTimeoutMs = cnst.REASSEMBLE_TIMEOUT_MS
+	}
+	this.fragmentConfig = &config
+	this.fragmentSender = newFragmentSender()
+	this.reassembler = fragment.NewReassembler(config.ReassembleTimeoutMs, config.MaxReassembleBytes).SetFragmentSize(config.FragmentSize)
+	return this
+}
+
+func (this *MCUser) RegisterFragmentDelegate(fragmentDelegate FragmentDelegate) *MCUser {
+	this.fragmentDelegate = fragmentDelegate
+	return this
+}
+
+func (this *MCUser) needFragment(msgByte []byte) bool {
+	return this.fragmentConfig != nil && len(msgByte) > this.fragmentConfig.FragmentSize
+}
+
+/**
+ * 分片发送，返回消息级别的id，接收端重组后的消息以该id作为packetId。register在发送分片前以该id调用。
+ * send需要在分片进入发送队列前以packetId调用传入的register。
+ */
+func (this *MCUser) sendFragments(msgByte []byte, send func(data []byte, register func(packetId string)) string, register func(msgId string)) string {
+	msgId := *(id.Generate())
+	fragments, err := fragment.Split(msgId, msgByte, this.fragmentConfig.FragmentSize)
+	if err != nil {
+		this.Logger().Error("split message into fragments fail: %v", err)
+		return ""
+	}
+	if register != nil {
+		register(msgId)
+	}
+	this.fragmentSender.track(msgId, len(fragments))
+	for _, data := range fragments {
+		send(data, func(packetId string) {
+			this.fragmentSender.add(msgId, packetId)
+		})
+	}
+	this.Logger().Info("[Send Fragments] msgId: %v, size: %v, fragments: %v.", msgId, len(msgByte), len(fragments))
+	return msgId
+}
+
+// 处理分片的ack或超时，返回true表示packetId属于分片消息，不需要再回调MessageHandlerDelegate
+func (this *MCUser) resolveFragment(packetId string, acked bool) bool {
+	if this.fragmentSender == nil {
+		return false
+	}
+	msgId, state, isFragment, completed, failed := this.fragmentSender.resolve(packetId, acked)
+	if !isFragment {
+		return false
+	}
+	if completed {
+		this.recordStatus(msgId, store.STATUS_ACKED, 0, 0)
+	} else if failed {
+		this.recordStatus(msgId, store.STATUS_TIMEOUT, 0, 0)
+	}
+	if this.fragmentDelegate == nil {
+		if failed {
+			this.Logger().Warn("fragmented message %v send fail, you need to handle this.", msgId)
+		}
+		return true
+	}
+	if completed {
+		this.fragmentDelegate.HandleFragmentSendComplete(msgId, state.total)
+	} else if failed {
+		this.fragmentDelegate.HandleFragmentSendFailure(msgId, state.acked, state.total)
+	}
+	return true
+}
+
+func p2pFragmentKey(fromAccount string) string {
+	return "p2p/" + fromAccount
+}
+
+func p2tFragmentKey(topicId int64, fromAccount string) string {
+	return "p2t/" + strconv.FormatInt(topicId, 10) + "/" + fromAccount
+}
+
+func (this *MCUser) expireFragments() {
+	if this.reassembler == nil {
+		return
+	}
+	for _, failure := range this.reassembler.Expire(CurrentTimeMillis()) {
+		this.handleReassembleFailure(failure)
+	}
+}
+
+func (this *MCUser) handleReassembleFailure(failure *fragment.Failure) {
+	this.Logger().Warn("reassemble message %v from %v fail: %v, received: %v/%v.", failure.MsgId, failure.Key, failure.Err, failure.Received, failure.Total)
+	if this.fragmentDelegate != nil {
+		this.fragmentDelegate.HandleFragmentReceiveFailure(failure.Key, failure.MsgId, failure.Received, failure.Total, failure.Err)
+	}
+}
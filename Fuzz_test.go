@@ -0,0 +1,84 @@
+package mimc
+
+import (
+	"bytes"
+	"container/list"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/conversation"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+	"github.com/golang/protobuf/proto"
+)
+
+// 实现所有回调，应用通常直接解引用消息的字段，交给应用的消息不应有空指针
+type nopDelegate struct{}
+
+func (this nopDelegate) HandleMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		message := ele.Value.(*msg.P2PMessage)
+		_, _, _, _, _ = *message.PacketId(), *message.Sequence(), *message.Timestamp(), *message.FromAccount(), *message.ToAccount()
+		_ = message.ContentType()
+	}
+}
+func (this nopDelegate) HandleGroupMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		message := ele.Value.(*msg.P2TMessage)
+		_, _, _, _, _ = *message.PacketId(), *message.Sequence(), *message.Timestamp(), *message.FromAccount(), *message.GroupId()
+		_ = message.ContentType()
+	}
+}
+func (this nopDelegate) HandleServerAck(packetId *string, sequence, timestamp *int64)           {}
+func (this nopDelegate) HandleSendMessageTimeout(message *msg.P2PMessage)                       {}
+func (this nopDelegate) HandleSendGroupMessageTimeout(message *msg.P2TMessage)                  {}
+func (this nopDelegate) HandleChange(isOnline bool, errType, errReason, errDescription *string) {}
+func (this nopDelegate) HandleError(err error)                                                  {}
+func (this nopDelegate) HandleDelivered(toAccount, packetId string)                             {}
+func (this nopDelegate) HandleRead(toAccount, packetId string)                                  {}
+func (this nopDelegate) HandleFragmentSendComplete(msgId string, total int)                     {}
+func (this nopDelegate) HandleFragmentSendFailure(msgId string, acked, total int)               {}
+func (this nopDelegate) HandleFragmentReceiveFailure(conversation, msgId string, received, total int, err error) {
+}
+func (this nopDelegate) HandleConversationChange(conversation *conversation.Conversation) {}
+
+/**
+ * 服务端下发的包在解密后交给handleResponse，畸形的payload不应导致panic。
+ * BIND响应在token过期时会重新登录并读写缓存文件，不在这里覆盖。
+ */
+func FuzzHandleResponse(f *testing.F) {
+	log.SetLogger(log.NewNopLogger())
+	p2p, _ := proto.Marshal(&MIMCP2PMessage{From: &MIMCUser{AppAccount: proto.String("Bob")}, To: &MIMCUser{AppAccount: proto.String("Alice")}, Payload: []byte("hi")})
+	p2t, _ := proto.Marshal(&MIMCP2TMessage{From: &MIMCUser{AppAccount: proto.String("Bob")}, To: &MIMCGroup{TopicId: proto.Int64(1)}, Payload: []byte("hi")})
+	packetList, _ := proto.Marshal(&MIMCPacketList{Packets: []*MIMCPacket{
+		{PacketId: proto.String("p1"), Type: MIMC_MSG_TYPE_P2P_MESSAGE.Enum(), Payload: p2p},
+		{PacketId: proto.String("p2"), Type: MIMC_MSG_TYPE_P2T_MESSAGE.Enum(), Payload: p2t},
+		{Type: MIMC_MSG_TYPE_P2P_MESSAGE.Enum()},
+	}})
+	compound, _ := proto.Marshal(&MIMCPacket{PacketId: proto.String("c1"), Type: MIMC_MSG_TYPE_COMPOUND.Enum(), Payload: packetList})
+	ack, _ := proto.Marshal(&MIMCPacketAck{PacketId: proto.String("s1"), Sequence: proto.Int64(1)})
+	ackPacket, _ := proto.Marshal(&MIMCPacket{Type: MIMC_MSG_TYPE_PACKET_ACK.Enum(), Payload: ack})
+	connResp, _ := proto.Marshal(&XMMsgConnResp{Challenge: proto.String("challenge")})
+	f.Add(cnst.CMD_SECMSG, compound)
+	f.Add(cnst.CMD_SECMSG, ackPacket)
+	f.Add(cnst.CMD_SECMSG, []byte{0x08, 0x06})
+	f.Add(cnst.CMD_CONN, connResp)
+	f.Add(cnst.CMD_NOTIFY, []byte{})
+	f.Add(cnst.CMD_KICK, []byte{})
+	f.Fuzz(func(t *testing.T, cmd string, payload []byte) {
+		if cmd == cnst.CMD_BIND {
+			return
+		}
+		user := NewUser("Alice")
+		// 空的回放只用来初始化队列和连接
+		user.Replay(bytes.NewReader(nil), ReplayOptions{})
+		delegate := nopDelegate{}
+		user.RegisterMessageDelegate(delegate).RegisterStatusDelegate(delegate).RegisterErrorDelegate(delegate)
+		user.RegisterReceiptDelegate(delegate).RegisterFragmentDelegate(delegate).RegisterConversationDelegate(delegate)
+		header := &ClientHeader{Cmd: proto.String(cmd), Id: proto.String("h1")}
+		user.handleResponse(packet.NewV6Packet().Header(header).Payload(payload))
+	})
+}
@@ -0,0 +1,344 @@
+package mimc
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/e2e"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+// 每个发送方保留的历史sender key数量，轮换后仍能解密路上的旧消息
+const receiverKeysPerSender = 2
+
+// 收到群聊消息时还没有收到发送方的sender key，等待分发消息到达
+type pendingGroupMessage struct {
+	arrived     int64
+	packetId    *string
+	fromAccount *string
+	sequence    *int64
+	timestamp   *int64
+	topicId     *int64
+	payload     []byte
+	transient   bool
+}
+
+type groupKeys struct {
+	mu           sync.Mutex
+	members      map[int64]map[string]bool
+	senderKeys   map[int64]*e2e.SenderKey
+	receiverKeys map[string][]*e2e.ReceiverKey
+	pending      map[string][]*pendingGroupMessage
+	pendingSize  int
+}
+
+func newGroupKeys() *groupKeys {
+	this := new(groupKeys)
+	this.members = make(map[int64]map[string]bool)
+	this.senderKeys = make(map[int64]*e2e.SenderKey)
+	this.receiverKeys = make(map[string][]*e2e.ReceiverKey)
+	this.pending = make(map[string][]*pendingGroupMessage)
+	return this
+}
+
+func (this *groupKeys) senderKey(topicId int64) *e2e.SenderKey {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.senderKeys[topicId]
+}
+
+func (this *groupKeys) receiverKey(topicId int64, fromAccount string, keyId uint32) *e2e.ReceiverKey {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, receiverKey := range this.receiverKeys[p2tFragmentKey(topicId, fromAccount)] {
+		if receiverKey.KeyId() == keyId {
+			return receiverKey
+		}
+	}
+	return nil
+}
+
+func (this *groupKeys) isMember(topicId int64, appAccount string) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	members, ok := this.members[topicId]
+	return !ok || members[appAccount]
+}
+
+/**
+ * 设置topic的成员列表，开启该topic的群聊端到端加密。
+ * 有成员离开或者第一次设置时生成新的sender key并分发给所有成员，只有新成员加入时把当前的sender key分发给新成员。
+ * 每个成员都需要在成员变化时调用，离开的成员才无法解密此后所有人发送的消息。
+ */
+func (this *MCUser) SetTopicMembers(topicId int64, members []string) error {
+	if this.e2eConfig == nil {
+		return ErrE2EDisabled
+	}
+	memberSet := make(map[string]bool, len(members))
+	for _, member := range members {
+		if member != this.appAccount {
+			memberSet[member] = true
+		}
+	}
+	this.groupKeys.mu.Lock()
+	oldMembers, registered := this.groupKeys.members[topicId]
+	senderKey := this.groupKeys.senderKeys[topicId]
+	rotate := senderKey == nil
+	for member := range oldMembers {
+		if !memberSet[member] {
+			rotate = true
+			delete(this.groupKeys.receiverKeys, p2tFragmentKey(topicId, member))
+		}
+	}
+	targets := make([]string, 0, len(memberSet))
+	for member := range memberSet {
+		if rotate || !registered || !oldMembers[member] {
+			targets = append(targets, member)
+		}
+	}
+	if rotate {
+		newSenderKey, err := e2e.NewSenderKey()
+		if err != nil {
+			this.groupKeys.mu.Unlock()
+			return err
+		}
+		senderKey = newSenderKey
+		this.groupKeys.senderKeys[topicId] = senderKey
+	}
+	this.groupKeys.members[topicId] = memberSet
+	this.groupKeys.mu.Unlock()
+	return this.distributeSenderKey(topicId, senderKey, targets)
+}
+
+// 主动轮换当前账号在topic中的sender key并分发给所有成员
+func (this *MCUser) RotateSenderKey(topicId int64) error {
+	if this.e2eConfig == nil {
+		return ErrE2EDisabled
+	}
+	senderKey, err := e2e.NewSenderKey()
+	if err != nil {
+		return err
+	}
+	this.groupKeys.mu.Lock()
+	members := make([]string, 0, len(this.groupKeys.members[topicId]))
+	for member := range this.groupKeys.members[topicId] {
+		members = append(members, member)
+	}
+	this.groupKeys.senderKeys[topicId] = senderKey
+	this.groupKeys.mu.Unlock()
+	return this.distributeSenderKey(topicId, senderKey, members)
+}
+
+// 通过端到端加密的单聊把sender key发给成员，返回第一个失败的错误，其余错误通过ErrorDelegate报告
+func (this *MCUser) distributeSenderKey(topicId int64, senderKey *e2e.SenderKey, members []string) error {
+	distribution := senderKey.Distribution(topicId)
+	var firstErr error
+	for _, member := range members {
+		payload, ok := this.encryptPayload(member, distribution)
+		if !ok {
+			if firstErr == nil {
+				firstErr = &E2EError{Account: member, TopicId: topicId, Err: e2e.ErrNoPublicKey}
+			}
+			continue
+		}
+		this.sendP2PMessage(member, payload, nil, func(packetId string) {
+			this.internalPackets.Push(packetId, member)
+		})
+	}
+	this.Logger().Info("[Send Sender Key] topicId: %v, keyId: %v, members: %v.", topicId, senderKey.KeyId(), len(members))
+	return firstErr
+}
+
+// sender key分发、回执等SDK内部消息的ack和超时不回调MessageHandlerDelegate
+func (this *MCUser) resolveInternal(packetId string, acked bool) bool {
+	member := this.internalPackets.Pop(packetId)
+	if member == nil {
+		return false
+	}
+	if !acked {
+		this.Logger().With(log.FieldPacketId, packetId).Warn("[Send Sender Key] distribution to %v timeout.", member)
+		this.reportError(&E2EError{Account: member.(string), PacketId: packetId, Err: ErrSenderKeyTimeout})
+	}
+	return true
+}
+
+func (this *MCUser) encryptGroupPayload(topicId int64, payload []byte) ([]byte, bool) {
+	if this.e2eConfig == nil {
+		return payload, true
+	}
+	senderKey := this.groupKeys.senderKey(topicId)
+	if senderKey == nil {
+		if this.e2eConfig.RequireEncryption {
+			this.Logger().Warn("[Send P2T Msg] topic %v has no sender key, call SetTopicMembers first.", topicId)
+			this.reportError(&E2EError{TopicId: topicId, Err: e2e.ErrNoSenderKey})
+			return nil, false
+		}
+		return payload, true
+	}
+	data, err := senderKey.Seal(topicId, this.appAccount, payload)
+	if err != nil {
+		this.Logger().Warn("[Send P2T Msg] encrypt message to topic %v fail: %v", topicId, err)
+		this.reportError(&E2EError{TopicId: topicId, Err: err})
+		return nil, false
+	}
+	return data, true
+}
+
+/**
+ * 处理收到的sender key分发消息，返回true表示payload是分发消息，不需要交给应用。
+ * 只接受经过端到端加密的分发消息。
+ */
+func (this *MCUser) handleSenderKeyDistribution(fromAppAccount string, packetId string, encrypted bool, payload []byte) bool {
+	if this.e2eConfig == nil || !e2e.IsDistribution(payload) {
+		return false
+	}
+	distribution, err := e2e.ParseDistribution(payload)
+	if err == nil && !encrypted {
+		err = ErrNotEncrypted
+	}
+	if err == nil && !this.groupKeys.isMember(distribution.TopicId, fromAppAccount) {
+		err = ErrNotTopicMember
+	}
+	if err != nil {
+		this.reportE2EError(fromAppAccount, packetId, err)
+		return true
+	}
+	key := p2tFragmentKey(distribution.TopicId, fromAppAccount)
+	this.groupKeys.mu.Lock()
+	receiverKeys := this.groupKeys.receiverKeys[key]
+	for _, receiverKey := range receiverKeys {
+		if receiverKey.KeyId() == distribution.KeyId {
+			this.groupKeys.mu.Unlock()
+			return true
+		}
+	}
+	receiverKeys = append([]*e2e.ReceiverKey{e2e.NewReceiverKey(distribution)}, receiverKeys...)
+	if len(receiverKeys) > receiverKeysPerSender {
+		receiverKeys = receiverKeys[:receiverKeysPerSender]
+	}
+	this.groupKeys.receiverKeys[key] = receiverKeys
+	pending := this.groupKeys.pending[key]
+	delete(this.groupKeys.pending, key)
+	this.groupKeys.pendingSize -= len(pending)
+	this.groupKeys.mu.Unlock()
+
+	this.Logger().Info("[handle packet] receive sender key from %v, topicId: %v, keyId: %v.", fromAppAccount, distribution.TopicId, distribution.KeyId)
+	p2tMsgList := list.New()
+	for _, message := range pending {
+		if p2tMsg, ok := this.openPendingGroupMessage(message); ok {
+			p2tMsgList.PushBack(p2tMsg)
+		}
+	}
+	if p2tMsgList.Len() > 0 && this.msgDelegate != nil {
+		this.recordReceived(p2tMsgList)
+		this.msgDelegate.HandleGroupMessage(p2tMsgList)
+	}
+	return true
+}
+
+/**
+ * 解密收到的群聊消息，返回nil表示消息被丢弃或者在等待sender key。
+ * message.payload为已经重组的数据，解密后再解压。
+ */
+func (this *MCUser) openGroupMessage(message *pendingGroupMessage) *msg.P2TMessage {
+	return this.deliverGroupMessage(message, this.decodeGroupMessage(message))
+}
+
+// 根据解码结果生成群聊消息，缺少sender key时缓存消息并返回nil
+func (this *MCUser) deliverGroupMessage(message *pendingGroupMessage, decoded DecodedPayload) *msg.P2TMessage {
+	if errors.Is(decoded.Err, e2e.ErrNoSenderKey) {
+		this.bufferGroupMessage(message)
+		return nil
+	}
+	if decoded.Err != nil {
+		this.reportGroupE2EError(message, decoded.Err)
+		return nil
+	}
+	return msg.NewP2tMsg(message.packetId, message.fromAccount, message.sequence, message.timestamp, message.topicId, decoded.Payload).SetTransient(message.transient)
+}
+
+// 收到sender key后解密等待中的消息，仍然无法解密时丢弃
+func (this *MCUser) openPendingGroupMessage(message *pendingGroupMessage) (*msg.P2TMessage, bool) {
+	decoded := this.decodeGroupMessage(message)
+	if decoded.Err != nil {
+		this.reportGroupE2EError(message, decoded.Err)
+		return nil, false
+	}
+	p2tMsg := msg.NewP2tMsg(message.packetId, message.fromAccount, message.sequence, message.timestamp, message.topicId, decoded.Payload)
+	return p2tMsg.SetTransient(message.transient), true
+}
+
+func (this *MCUser) decodeGroupMessage(message *pendingGroupMessage) DecodedPayload {
+	decoded := DecodedPayload{Kind: PAYLOAD_MESSAGE, PacketId: *message.packetId, Payload: message.payload}
+	return this.payloadDecoder().openGroup(*message.topicId, *message.fromAccount, decoded)
+}
+
+// PayloadDecoder的DecryptGroup，缺少发送方的sender key时返回e2e.ErrNoSenderKey
+func (this *MCUser) openGroupPayload(topicId int64, fromAppAccount string, payload []byte) ([]byte, error) {
+	if this.e2eConfig == nil {
+		return payload, nil
+	}
+	if !e2e.IsGroupEncrypted(payload) {
+		if this.e2eConfig.RequireEncryption {
+			return nil, ErrNotEncrypted
+		}
+		return payload, nil
+	}
+	receiverKey := this.groupKeys.receiverKey(topicId, fromAppAccount, e2e.GroupKeyId(payload))
+	if receiverKey == nil {
+		return nil, e2e.ErrNoSenderKey
+	}
+	return receiverKey.Open(topicId, fromAppAccount, payload)
+}
+
+func (this *MCUser) bufferGroupMessage(message *pendingGroupMessage) {
+	this.groupKeys.mu.Lock()
+	if this.groupKeys.pendingSize >= cnst.MAX_PENDING_GROUP_MESSAGES {
+		this.groupKeys.mu.Unlock()
+		this.reportGroupE2EError(message, e2e.ErrNoSenderKey)
+		return
+	}
+	key := p2tFragmentKey(*message.topicId, *message.fromAccount)
+	this.groupKeys.pending[key] = append(this.groupKeys.pending[key], message)
+	this.groupKeys.pendingSize += 1
+	this.groupKeys.mu.Unlock()
+	this.Logger().With(log.FieldPacketId, *message.packetId).Debug("[handle packet] wait for sender key of %v in topic %v.", *message.fromAccount, *message.topicId)
+}
+
+// 丢弃等待sender key超时的群聊消息
+func (this *MCUser) expireGroupMessages() {
+	if this.e2eConfig == nil {
+		return
+	}
+	now := CurrentTimeMillis()
+	var expired []*pendingGroupMessage
+	this.groupKeys.mu.Lock()
+	for key, messages := range this.groupKeys.pending {
+		remain := messages[:0]
+		for _, message := range messages {
+			if now-message.arrived >= cnst.SENDER_KEY_WAIT_MS {
+				expired = append(expired, message)
+			} else {
+				remain = append(remain, message)
+			}
+		}
+		if len(remain) == 0 {
+			delete(this.groupKeys.pending, key)
+		} else {
+			this.groupKeys.pending[key] = remain
+		}
+	}
+	this.groupKeys.pendingSize -= len(expired)
+	this.groupKeys.mu.Unlock()
+	for _, message := range expired {
+		this.reportGroupE2EError(message, e2e.ErrNoSenderKey)
+	}
+}
+
+func (this *MCUser) reportGroupE2EError(message *pendingGroupMessage, err error) {
+	this.Logger().With(log.FieldPacketId, *message.packetId).Warn("[handle packet] drop group message from %v in topic %v: %v", *message.fromAccount, *message.topicId, err)
+	this.reportError(&E2EError{Account: *message.fromAccount, TopicId: *message.topicId, PacketId: *message.packetId, Err: err})
+}
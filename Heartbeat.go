@@ -0,0 +1,102 @@
+package mimc
+
+import (
+	"container/list"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"sync"
+)
+
+/**
+ * 心跳状态：记录已发出但未收到pong的ping，计算RTT，
+ * 连续PING_MAX_MISSED个ping超时则认为连接已断开。
+ * pong中不带ping的id，按发送顺序一一对应。
+ */
+type heartbeat struct {
+	mu       sync.Mutex
+	pending  *list.List
+	missed   int
+	interval int64
+	lastRtt  int64
+}
+
+func newHeartbeat() *heartbeat {
+	this := new(heartbeat)
+	this.pending = list.New()
+	this.reset()
+	return this
+}
+
+func (this *heartbeat) reset() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.pending.Init()
+	this.missed = 0
+	this.interval = cnst.PING_TIMEVAL_MS
+}
+
+// 当前的ping间隔，空闲时逐步变长，丢失pong后缩短
+func (this *heartbeat) Interval() int64 {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.interval
+}
+
+func (this *heartbeat) LastRtt() int64 {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.lastRtt
+}
+
+func (this *heartbeat) pingSent(timestamp int64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.pending.PushBack(timestamp)
+}
+
+// 有业务数据发出，恢复默认间隔
+func (this *heartbeat) active() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.missed == 0 && this.interval > cnst.PING_TIMEVAL_MS {
+		this.interval = cnst.PING_TIMEVAL_MS
+	}
+}
+
+// 收到pong，返回对应ping的RTT，没有未完成的ping时返回-1
+func (this *heartbeat) pongReceived(timestamp int64) int64 {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	front := this.pending.Front()
+	if front == nil {
+		return -1
+	}
+	this.pending.Remove(front)
+	this.lastRtt = timestamp - front.Value.(int64)
+	this.missed = 0
+	this.interval += cnst.PING_TIMEVAL_MS
+	if this.interval > cnst.PING_MAX_TIMEVAL_MS {
+		this.interval = cnst.PING_MAX_TIMEVAL_MS
+	}
+	return this.lastRtt
+}
+
+// 清理超时的ping，连续丢失的pong达到上限时返回true并清空状态
+func (this *heartbeat) expire(timestamp int64) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for front := this.pending.Front(); front != nil; front = this.pending.Front() {
+		if timestamp-front.Value.(int64) < cnst.PONG_TIMEOUT_MS {
+			break
+		}
+		this.pending.Remove(front)
+		this.missed += 1
+		this.interval = cnst.PING_MIN_TIMEVAL_MS
+	}
+	if this.missed < cnst.PING_MAX_MISSED {
+		return false
+	}
+	// 连接即将被重置，清空计数避免重连后再次触发
+	this.pending.Init()
+	this.missed = 0
+	return true
+}
@@ -0,0 +1,47 @@
+package mimc
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"testing"
+)
+
+func TestHeartbeatRtt(t *testing.T) {
+	heartbeat := newHeartbeat()
+	heartbeat.pingSent(1000)
+	heartbeat.pingSent(2000)
+	if rtt := heartbeat.pongReceived(1050); rtt != 50 {
+		t.Errorf("rtt should be 50, got %v", rtt)
+	}
+	if rtt := heartbeat.pongReceived(2100); rtt != 100 {
+		t.Errorf("rtt should be 100, got %v", rtt)
+	}
+	if rtt := heartbeat.pongReceived(3000); rtt != -1 {
+		t.Errorf("unexpected pong should return -1, got %v", rtt)
+	}
+	if heartbeat.Interval() <= cnst.PING_TIMEVAL_MS {
+		t.Errorf("interval should grow when idle, got %v", heartbeat.Interval())
+	}
+	heartbeat.active()
+	if heartbeat.Interval() != cnst.PING_TIMEVAL_MS {
+		t.Errorf("interval should be restored on traffic, got %v", heartbeat.Interval())
+	}
+}
+
+func TestHeartbeatMissedPongs(t *testing.T) {
+	heartbeat := newHeartbeat()
+	now := int64(0)
+	for i := 0; i < cnst.PING_MAX_MISSED; i++ {
+		heartbeat.pingSent(now)
+		now += cnst.PONG_TIMEOUT_MS
+		dead := heartbeat.expire(now)
+		if heartbeat.Interval() != cnst.PING_MIN_TIMEVAL_MS && !dead {
+			t.Errorf("interval should shrink after a missed pong, got %v", heartbeat.Interval())
+		}
+		if dead != (i == cnst.PING_MAX_MISSED-1) {
+			t.Fatalf("unexpected dead peer state after %v missed pongs: %v", i+1, dead)
+		}
+	}
+	if heartbeat.expire(now + cnst.PONG_TIMEOUT_MS) {
+		t.Errorf("state should be cleared after reporting a dead peer")
+	}
+}
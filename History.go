@@ -0,0 +1,107 @@
+package mimc
+
+import (
+	"container/list"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/fragment"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/history"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+)
+
+// 返回使用当前用户token的历史消息客户端，需要先登录获取token
+func (this *MCUser) HistoryClient() *history.Client {
+	return history.NewClient(this.restUrl, this.currentToken)
+}
+
+/**
+ * 查询与peer之间的单聊历史消息，返回*msg.P2PMessage列表和下一页的查询条件，没有更多消息时为nil。
+ * payload按收到消息时的流程解码，回执、sender key分发、无法解密的消息(包括自己发出的端到端加密消息)被跳过，
+ * 分片只在同一页内收齐时重组。
+ * 设置了消息存储时，本地没有的消息会合并到存储中，不计入会话未读数。
+ */
+func (this *MCUser) QueryP2PHistory(peer string, query history.Query) (*list.List, *history.Query, error) {
+	page, err := this.HistoryClient().P2P(this.appAccount, peer, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	decoder := this.historyDecoder()
+	msgList := list.New()
+	for _, message := range page.Messages {
+		decoded := decoder.DecodeP2P(message.FromAccount, message.PacketId, message.Payload)
+		if decoded.Kind != PAYLOAD_MESSAGE {
+			continue
+		}
+		p2pMsg := msg.NewP2pMsg(&decoded.PacketId, &message.FromAccount, &message.ToAccount, &message.Sequence, &message.Timestamp, decoded.Payload)
+		msgList.PushBack(p2pMsg)
+		this.mergeHistory(&store.Message{
+			PacketId:     decoded.PacketId,
+			Conversation: store.P2PConversation(peer),
+			Type:         store.TYPE_P2P,
+			FromAccount:  message.FromAccount,
+			ToAccount:    message.ToAccount,
+			Sequence:     message.Sequence,
+			Timestamp:    message.Timestamp,
+			Payload:      decoded.Payload,
+		})
+	}
+	return msgList, page.Next(query), nil
+}
+
+// 查询topicId的群聊历史消息，返回*msg.P2TMessage列表，其他同QueryP2PHistory
+func (this *MCUser) QueryP2THistory(topicId int64, query history.Query) (*list.List, *history.Query, error) {
+	page, err := this.HistoryClient().P2T(this.appAccount, topicId, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	decoder := this.historyDecoder()
+	msgList := list.New()
+	for _, message := range page.Messages {
+		decoded := decoder.DecodeP2T(topicId, message.FromAccount, message.PacketId, message.Payload)
+		if decoded.Kind != PAYLOAD_MESSAGE {
+			continue
+		}
+		p2tMsg := msg.NewP2tMsg(&decoded.PacketId, &message.FromAccount, &message.Sequence, &message.Timestamp, &topicId, decoded.Payload)
+		msgList.PushBack(p2tMsg)
+		this.mergeHistory(&store.Message{
+			PacketId:     decoded.PacketId,
+			Conversation: store.P2TConversation(topicId),
+			Type:         store.TYPE_P2T,
+			FromAccount:  message.FromAccount,
+			TopicId:      topicId,
+			Sequence:     message.Sequence,
+			Timestamp:    message.Timestamp,
+			Payload:      decoded.Payload,
+		})
+	}
+	return msgList, page.Next(query), nil
+}
+
+// 历史消息使用独立的分片重组状态，不影响正在接收的消息，重组失败不回调FragmentDelegate
+func (this *MCUser) historyDecoder() *PayloadDecoder {
+	decoder := this.payloadDecoder()
+	decoder.Reassembler = nil
+	decoder.HandleFailure = nil
+	if this.fragmentConfig != nil {
+		decoder.Reassembler = fragment.NewReassembler(this.fragmentConfig.ReassembleTimeoutMs, this.fragmentConfig.MaxReassembleBytes).SetFragmentSize(this.fragmentConfig.FragmentSize)
+	}
+	return decoder
+}
+
+func (this *MCUser) mergeHistory(message *store.Message) {
+	if this.messageStore == nil {
+		return
+	}
+	if _, err := this.messageStore.Get(message.PacketId); err != store.ErrNotFound {
+		return
+	}
+	message.Outgoing = message.FromAccount == this.appAccount
+	if message.Outgoing {
+		message.Status = store.STATUS_ACKED
+	} else {
+		message.Status = store.STATUS_RECEIVED
+	}
+	if err := this.messageStore.Save(message); err != nil {
+		this.Logger().Warn("[store] save history message %v fail: %v", message.PacketId, err)
+	}
+}
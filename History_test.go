@@ -0,0 +1,83 @@
+package mimc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/e2e"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/fragment"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/history"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/receipt"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+)
+
+func TestQueryP2PHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/msg/p2p/query/" || r.Header.Get("token") != "t" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"code":200,"message":"success","data":{"hasMore":true,"messages":[
+			{"packetId":"p3","sequence":"3","fromAccount":"Alice","toAccount":"Bob","ts":"2000","payload":"aGVsbG8="},
+			{"packetId":"p2","sequence":"2","fromAccount":"Bob","toAccount":"Alice","ts":"1000","payload":"aGk="}]}}`))
+	}))
+	defer server.Close()
+	token := "t"
+	user := NewUser("Alice").SetRestUrl(server.URL).SetMessageStore(store.NewMemoryStore())
+	user.token = &token
+
+	msgList, next, err := user.QueryP2PHistory("Bob", history.Query{Count: 2, Reverse: true})
+	if err != nil {
+		t.Fatalf("query history fail: %v", err)
+	}
+	if msgList.Len() != 2 || string(msgList.Front().Value.(*msg.P2PMessage).Payload()) != "hello" {
+		t.Fatalf("unexpected history: %v", msgList.Len())
+	}
+	if next == nil || next.MaxSequence == nil || *next.MaxSequence != 1 || next.EndTime != 1000 {
+		t.Errorf("unexpected next query: %+v", next)
+	}
+	messages, _ := user.QueryMessages(store.Query{Conversation: store.P2PConversation("Bob")})
+	if len(messages) != 2 || !messages[1].Outgoing || messages[1].Status != store.STATUS_ACKED || messages[0].Status != store.STATUS_RECEIVED {
+		t.Errorf("history should be merged into store: %+v", messages)
+	}
+}
+
+// 历史消息与收到的消息使用相同的解码流程，SDK内部消息被跳过，同一页内的分片被重组
+func TestHistorySkipsInternalMessages(t *testing.T) {
+	senderKey, _ := e2e.NewSenderKey()
+	receiptPayload, _ := receipt.Encode(&receipt.Receipt{Type: receipt.TYPE_DELIVERED, PacketIds: []string{"p1"}})
+	fragments, _ := fragment.Split("m1", []byte("hello fragments"), 8)
+	payloads := [][]byte{senderKey.Distribution(1), receiptPayload, fragments[0], fragments[1]}
+	var messages []string
+	for i, payload := range payloads {
+		messages = append(messages, fmt.Sprintf(`{"packetId":"p%v","sequence":"%v","fromAccount":"Bob","toAccount":"Alice","ts":"1000","payload":"%v"}`, i, i+1, base64.StdEncoding.EncodeToString(payload)))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":200,"message":"success","data":{"hasMore":false,"messages":[` + strings.Join(messages, ",") + `]}}`))
+	}))
+	defer server.Close()
+	token := "t"
+	user := NewUser("Alice").SetRestUrl(server.URL).SetMessageStore(store.NewMemoryStore()).EnableFragmentation(DefaultFragmentConfig())
+	user.token = &token
+
+	msgList, _, err := user.QueryP2PHistory("Bob", history.Query{Count: 4})
+	if err != nil {
+		t.Fatalf("query history fail: %v", err)
+	}
+	if msgList.Len() != 1 {
+		t.Fatalf("only the reassembled message should be returned, got %v", msgList.Len())
+	}
+	message := msgList.Front().Value.(*msg.P2PMessage)
+	if *message.PacketId() != "m1" || string(message.Payload()) != "hello fragments" {
+		t.Errorf("unexpected message %v: %q", *message.PacketId(), message.Payload())
+	}
+	stored, _ := user.QueryMessages(store.Query{Conversation: store.P2PConversation("Bob")})
+	if len(stored) != 1 {
+		t.Errorf("internal messages should not be merged into store: %+v", stored)
+	}
+}
@@ -2,6 +2,7 @@ package mimc
 
 import (
 	"container/list"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/conversation"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
 )
 
@@ -9,6 +10,11 @@ type Token interface {
 	FetchToken() *string
 }
 
+// 缓存token的Token实现可以同时实现此接口，token过期重新登录前SDK会调用Invalidate
+type TokenInvalidator interface {
+	Invalidate()
+}
+
 type StatusDelegate interface {
 	/**
 	 * @param[isOnline bool] true: 在线，false：离线
@@ -19,6 +25,51 @@ type StatusDelegate interface {
 	HandleChange(isOnline bool, errType, errReason, errDescription *string)
 }
 
+type ErrorDelegate interface {
+	/**
+	 * @param[err error] 服务端返回的*ServerError或端到端加密的*E2EError，可用errors.Is判断具体类型
+	 */
+	HandleError(err error)
+}
+
+type FragmentDelegate interface {
+	/**
+	 * 分片消息的全部分片都被服务端确认
+	 * @param[msgId string] SendMessage/SendGroupMessage返回的消息id
+	 */
+	HandleFragmentSendComplete(msgId string, total int)
+	/**
+	 * 分片消息有分片发送超时，每个消息只回调一次
+	 */
+	HandleFragmentSendFailure(msgId string, acked, total int)
+	/**
+	 * 接收端重组失败(超时、超出缓存限制等)，每个消息只回调一次
+	 * @param[conversation string] p2p/{fromAccount}或p2t/{topicId}/{fromAccount}
+	 */
+	HandleFragmentReceiveFailure(conversation, msgId string, received, total int, err error)
+}
+
+type ReceiptDelegate interface {
+	/**
+	 * 对方设备已收到消息并交给了应用，每个消息只回调一次
+	 * @param[toAccount string] 消息的接收方
+	 * @param[packetId string] SendMessage返回的消息id
+	 */
+	HandleDelivered(toAccount, packetId string)
+	/**
+	 * 对方已读消息，没有收到送达回执的消息会先回调HandleDelivered
+	 */
+	HandleRead(toAccount, packetId string)
+}
+
+type ConversationDelegate interface {
+	/**
+	 * 会话的最后一条消息、未读数或已读位置发生变化
+	 * @param[conversation *conversation.Conversation] 变化后的会话副本
+	 */
+	HandleConversationChange(conversation *conversation.Conversation)
+}
+
 type MessageHandlerDelegate interface {
 	HandleMessage(packets *list.List)
 	HandleGroupMessage(packets *list.List)
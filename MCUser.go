@@ -3,13 +3,19 @@ package mimc
 import (
 	"container/list"
 	"encoding/json"
+	"errors"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/conversation"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/fragment"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/inspect"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/metrics"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
 	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
 	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
-	"github.com/Xiaomi-mimc/mimc-go-sdk/util/byte"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/recording"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/util/map"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/util/queue"
@@ -17,12 +23,13 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type UserStatus int
 
-var logger *log.Logger
-
 const (
 	Online UserStatus = iota
 	Offline
@@ -33,6 +40,12 @@ func init() {
 }
 
 type MCUser struct {
+	// 登录状态和凭证会被应用、发送、回调goroutine同时读写，
+	// chid、uuid、resource、status、appId、appPackage、securityKey、token、tryLogin、closed由stateLock保护
+	stateLock sync.RWMutex
+	// InitAndSetup时创建，Close时关闭，通知goroutine退出
+	closed chan struct{}
+
 	chid     float64
 	uuid     int64
 	resource string
@@ -53,26 +66,58 @@ type MCUser struct {
 	securityKey string
 	token       *string
 	tryLogin    bool
+	restUrl     string
 
 	sequenceReceived        map[uint32]interface{}
 	conn                    *MIMCConnection
-	lastLoginTimestamp      int64
+	peerFetcher             frontend.IFrontendPeerFetcher
+	lastLoginTimestamp      int64 // 与lastCreateConnTimestamp一样通过atomic读写
 	lastCreateConnTimestamp int64
 	lastPingTimestamp       int64
+	heartbeat               *heartbeat
+
+	// userLogger和logger会在resource变化时被回调goroutine改写，由loggerLock保护
+	loggerLock sync.RWMutex
+	userLogger log.Logger
+	logger     log.Logger
+	metrics    metrics.Metrics
 
 	tokenDelegate  Token
 	statusDelegate StatusDelegate
 	msgDelegate    MessageHandlerDelegate
+	errDelegate    ErrorDelegate
+
+	fragmentConfig   *FragmentConfig
+	fragmentSender   *fragmentSender
+	reassembler      *fragment.Reassembler
+	fragmentDelegate FragmentDelegate
+
+	compressionConfig *CompressionConfig
+	e2eConfig         *E2EConfig
+	groupKeys         *groupKeys
+
+	receipts        *receiptTracker
+	receiptDelegate ReceiptDelegate
+
+	messageStore         store.MessageStore
+	conversations        *conversation.Tracker
+	conversationDelegate ConversationDelegate
+
+	// 录制器可以在收发过程中设置，由recorderLock保护
+	recorderLock sync.RWMutex
+	recorder     *recording.Recorder
 
 	messageToSend    *que.ConQueue
 	messageToAck     *cmap.ConMap
+	internalPackets  *cmap.ConMap
+	transientPackets *cmap.ConMap
 	packetToCallback *que.ConQueue
 }
 
 func NewUser(appAccount string) *MCUser {
-	logger = log.GetLogger()
 	this := NewMCUser()
 	this.appAccount = appAccount
+	this.refreshLogger()
 	return this
 }
 
@@ -91,64 +136,140 @@ func (this *MCUser) RegisterMessageDelegate(msgDelegate MessageHandlerDelegate)
 	return this
 }
 
+func (this *MCUser) RegisterErrorDelegate(errDelegate ErrorDelegate) *MCUser {
+	this.errDelegate = errDelegate
+	return this
+}
+
 func NewMCUser() *MCUser {
 	mcUser := new(MCUser)
+	mcUser.userLogger = log.GetLogger()
+	mcUser.metrics = metrics.GetMetrics()
+	mcUser.restUrl = cnst.REST_URL_ONLINE
+	mcUser.refreshLogger()
 	return mcUser
 }
 
+// 为当前用户指定Logger，SDK会在其上附加appAccount和resource字段
+func (this *MCUser) SetLogger(logger log.Logger) *MCUser {
+	if logger == nil {
+		logger = log.GetLogger()
+	}
+	this.loggerLock.Lock()
+	this.userLogger = logger
+	this.loggerLock.Unlock()
+	this.refreshLogger()
+	return this
+}
+
+// 为当前用户指定指标上报，传nil则不上报
+func (this *MCUser) SetMetrics(m metrics.Metrics) *MCUser {
+	if m == nil {
+		m = metrics.NewNopMetrics()
+	}
+	this.metrics = m
+	return this
+}
+
+func (this *MCUser) refreshLogger() {
+	resource := this.Resource()
+	this.loggerLock.Lock()
+	defer this.loggerLock.Unlock()
+	this.logger = this.userLogger.With(log.FieldAppAccount, this.appAccount, log.FieldResource, resource)
+}
+
 func (this *MCUser) InitAndSetup() {
 	void := ""
+	this.stateLock.Lock()
 	this.status = Offline
 	this.resource = strutil.RandomStrWithLength(10)
-	this.lastLoginTimestamp = 0
-	this.lastCreateConnTimestamp = 0
-	this.lastPingTimestamp = 0
-	this.conn = NewConn().User(this)
-	this.messageToSend = que.NewConQueue()
-	this.messageToAck = cmap.NewConMap()
-	this.packetToCallback = que.NewConQueue()
 	this.appPackage = void
 	this.chid = 0
 	this.uuid = 0
 	this.appId = 0
 	this.token = &void
 	this.securityKey = void
+	this.tryLogin = false
+	closed := make(chan struct{})
+	this.closed = closed
+	this.stateLock.Unlock()
+	this.refreshLogger()
+	atomic.StoreInt64(&this.lastLoginTimestamp, 0)
+	atomic.StoreInt64(&this.lastCreateConnTimestamp, 0)
+	this.lastPingTimestamp = 0
+	this.heartbeat = newHeartbeat()
+	this.conn = NewConn().User(this)
+	if this.peerFetcher != nil {
+		this.conn.PeerFetcher(this.peerFetcher)
+	}
+	this.messageToSend = que.NewConQueue()
+	this.messageToAck = cmap.NewConMap()
+	this.internalPackets = cmap.NewConMap()
+	this.transientPackets = cmap.NewConMap()
+	this.packetToCallback = que.NewConQueue()
 	this.clientAttrs = void
 	this.cloudAttrs = void
-	this.tryLogin = false
 	//this.synchronizeResource()
-	go this.sendRoutine()
-	go this.receiveRoutine()
-	go this.triggerRoutine()
-	go this.callBackRoutine()
+	go this.sendRoutine(closed)
+	go this.receiveRoutine(closed)
+	go this.triggerRoutine(closed)
+	go this.callBackRoutine(closed)
+}
+
+/**
+ * 关闭用户，停止InitAndSetup启动的goroutine并断开连接，之后不再重连。
+ * 在线时发送goroutine退出前会发送UNBIND，不回调statusDelegate。
+ * 与Logout不同，登录中或者离线重连中的用户同样会停止。没有InitAndSetup或者已经关闭时返回false。
+ */
+func (this *MCUser) Close() bool {
+	this.stateLock.Lock()
+	closed := this.closed
+	this.closed = nil
+	this.tryLogin = false
+	this.stateLock.Unlock()
+	if closed == nil {
+		return false
+	}
+	close(closed)
+	return true
+}
+
+func isClosed(closed <-chan struct{}) bool {
+	select {
+	case <-closed:
+		return true
+	default:
+		return false
+	}
 }
 
 func (this *MCUser) synchronizeResource() {
 	root, _ := exec.LookPath(os.Args[0])
 	dir := cnst.CACHE_DIR
 	file := cnst.CACHE_FILE
-	key := strconv.FormatInt(this.appId, 10) + "_" + this.appAccount + "_resource"
-	this.resource = *(strutil.SynchronizeResource(&root, &dir, &file, &key, &(this.resource)))
+	key := strconv.FormatInt(this.AppId(), 10) + "_" + this.appAccount + "_resource"
+	resource := this.Resource()
+	this.SetResource(*(strutil.SynchronizeResource(&root, &dir, &file, &key, &resource)))
 }
 func (this *MCUser) synchronizeToken() *string {
 	root, _ := exec.LookPath(os.Args[0])
 	dir := cnst.CACHE_DIR
 	file := cnst.CACHE_FILE
-	key := strconv.FormatInt(this.appId, 10) + "_" + this.appAccount + "_token"
-	token := strutil.SynchronizeResource(&root, &dir, &file, &key, this.token)
-	this.token = token
+	key := strconv.FormatInt(this.AppId(), 10) + "_" + this.appAccount + "_token"
+	token := strutil.SynchronizeResource(&root, &dir, &file, &key, this.Token())
+	this.SetToken(token)
 	return token
 }
 
 func (this *MCUser) refreshToken() bool {
 	if this.tokenDelegate == nil {
-		logger.Error("%v Login fail, have to fetch token.", this.appAccount)
+		this.Logger().Error("%v Login fail, have to fetch token.", this.appAccount)
 		return false
 	}
 	tokenJsonStr := this.tokenDelegate.FetchToken()
-	this.tryLogin = true
+	this.setTryLogin(true)
 	if tokenJsonStr == nil {
-		logger.Warn("%v Login fail, get nil token string.", this.appAccount)
+		this.Logger().Warn("%v Login fail, get nil token string.", this.appAccount)
 		return false
 	}
 	var tokenMap map[string]interface{}
@@ -156,29 +277,37 @@ func (this *MCUser) refreshToken() bool {
 		data := tokenMap["data"].(map[string]interface{})
 		code := tokenMap["code"].(float64)
 		if code != 200 {
-			logger.Warn("%v Login fail, response code: %v", this.appAccount, data)
+			this.Logger().Warn("%v Login fail, response code: %v", this.appAccount, data)
 			return false
 		}
 		appAccount := data["appAccount"].(string)
 		if appAccount != this.appAccount {
-			logger.Warn("appAccount:%v does not match token generated by appAccount: %v.", this.appAccount, appAccount)
+			this.Logger().Warn("appAccount:%v does not match token generated by appAccount: %v.", this.appAccount, appAccount)
 			return false
 		}
-		this.appPackage = data["appPackage"].(string)
-		this.chid = data["miChid"].(float64)
-		this.appId, _ = strconv.ParseInt(data["appId"].(string), 10, 64)
+		appPackage := data["appPackage"].(string)
+		chid := data["miChid"].(float64)
+		appId, _ := strconv.ParseInt(data["appId"].(string), 10, 64)
 		uuid, err := strconv.ParseInt(data["miUserId"].(string), 10, 64)
 		if err != nil {
-			logger.Error("%v Login fail, can not parse token string.", this.appAccount)
+			this.Logger().Error("%v Login fail, can not parse token string.", this.appAccount)
 			return false
 		}
+		securityKey := data["miUserSecurityKey"].(string)
+		this.stateLock.Lock()
+		this.appPackage = appPackage
+		this.chid = chid
+		this.appId = appId
 		this.uuid = uuid
-		this.securityKey = data["miUserSecurityKey"].(string)
+		this.securityKey = securityKey
+		this.stateLock.Unlock()
 		token, ok := data["token"]
 		if ok {
 			tokenStr := token.(string)
+			this.stateLock.Lock()
 			this.token = &(tokenStr)
 			this.tryLogin = false
+			this.stateLock.Unlock()
 			return true
 		} else {
 			return false
@@ -196,18 +325,22 @@ func (this *MCUser) Login() bool {
 	if result {
 		this.synchronizeResource()
 	}
-	this.tryLogin = true
+	this.setTryLogin(true)
 	return result
 
 }
+
+// 在线时发送UNBIND，不再自动重新登录。离线时返回false，需要停止重连时使用Close
 func (this *MCUser) Logout() bool {
-	if this.status == Offline {
+	this.setTryLogin(false)
+	if this.Status() == Offline {
 		return false
 	}
 	v6PacketForUnbind := BuildUnBindPacket(this)
 	unBindPacket := msg.NewMsgPacket(cnst.MIMC_C2S_DOUBLE_DIRECTION, v6PacketForUnbind)
 	this.messageToSend.Push(unBindPacket)
-	this.tryLogin = false
+	this.setTryLogin(false)
+	this.conn.ClearRedirect()
 	return true
 }
 
@@ -215,53 +348,98 @@ func (this *MCUser) SendMessage(toAppAccount string, msgByte []byte) string {
 	if &toAppAccount == nil || msgByte == nil || len(msgByte) == 0 {
 		return ""
 	}
-	logger.Info("[Send P2P Msg]%v -> %v: %v.\n", this.appAccount, toAppAccount, string(msgByte))
+	payload, ok := this.encryptPayload(toAppAccount, this.compressPayload(msgByte))
+	if !ok {
+		return ""
+	}
+	// 入队前写入存储和回执跟踪，避免ack或回执先于记录到达
+	register := func(packetId string) {
+		this.recordSent(packetId, store.TYPE_P2P, toAppAccount, 0, msgByte)
+		this.trackReceipt(toAppAccount, packetId)
+	}
+	if this.needFragment(payload) {
+		return this.sendFragments(payload, func(data []byte, registerFragment func(packetId string)) string {
+			return this.sendP2PMessage(toAppAccount, data, nil, registerFragment)
+		}, register)
+	}
+	return this.sendP2PMessage(toAppAccount, payload, msgByte, register)
+}
+
+// register在包进入发送队列前调用，用于登记ack、超时等需要与packetId关联的状态
+func (this *MCUser) sendP2PMessage(toAppAccount string, msgByte []byte, original []byte, register func(packetId string)) string {
 	v6Packet, mimcPacket := BuildP2PMessagePacket(this, toAppAccount, msgByte, true)
-	timeoutPacket := packet.NewTimeoutPacket(CurrentTimeMillis(), mimcPacket)
+	this.Logger().With(log.FieldPacketId, *(mimcPacket.PacketId)).Info("[Send P2P Msg]%v -> %v: %v.\n", this.appAccount, toAppAccount, string(msgByte))
+	timeoutPacket := packet.NewTimeoutPacket(CurrentTimeMillis(), mimcPacket).SetPayload(original)
 	msgPacket := msg.NewMsgPacket(cnst.MIMC_C2S_DOUBLE_DIRECTION, v6Packet)
-	this.messageToSend.Push(msgPacket)
+	// 先登记再入队，发送goroutine发出后ack可能立即返回
 	this.messageToAck.Push(*(mimcPacket.PacketId), timeoutPacket)
+	if register != nil {
+		register(*(mimcPacket.PacketId))
+	}
+	this.messageToSend.Push(msgPacket)
 	return *(mimcPacket.PacketId)
-
 }
 
 func (this *MCUser) SendGroupMessage(topicId *int64, msgByte []byte) string {
-	if &topicId == nil || msgByte == nil || len(msgByte) == 0 {
+	if topicId == nil || msgByte == nil || len(msgByte) == 0 {
 		return ""
 	}
-	logger.Info("[Send P2T Msg]%v send p2t msg to %v: %v.\n", this.appAccount, *topicId, string(msgByte))
-	v6Packet, mimcPacket := BuildP2TMessagePacket(this, *topicId, msgByte, true)
-	timeoutPacket := packet.NewTimeoutPacket(CurrentTimeMillis(), mimcPacket)
+	payload, ok := this.encryptGroupPayload(*topicId, this.compressPayload(msgByte))
+	if !ok {
+		return ""
+	}
+	register := func(packetId string) {
+		this.recordSent(packetId, store.TYPE_P2T, "", *topicId, msgByte)
+	}
+	if this.needFragment(payload) {
+		return this.sendFragments(payload, func(data []byte, registerFragment func(packetId string)) string {
+			return this.sendP2TMessage(*topicId, data, nil, registerFragment)
+		}, register)
+	}
+	return this.sendP2TMessage(*topicId, payload, msgByte, register)
+}
+
+// register在包进入发送队列前调用，用于登记ack、超时等需要与packetId关联的状态
+func (this *MCUser) sendP2TMessage(topicId int64, msgByte []byte, original []byte, register func(packetId string)) string {
+	v6Packet, mimcPacket := BuildP2TMessagePacket(this, topicId, msgByte, true)
+	this.Logger().With(log.FieldPacketId, *(mimcPacket.PacketId)).Info("[Send P2T Msg]%v send p2t msg to %v: %v.\n", this.appAccount, topicId, string(msgByte))
+	timeoutPacket := packet.NewTimeoutPacket(CurrentTimeMillis(), mimcPacket).SetPayload(original)
 	msgPacket := msg.NewMsgPacket(cnst.MIMC_C2S_DOUBLE_DIRECTION, v6Packet)
-	this.messageToSend.Push(msgPacket)
+	// 先登记再入队，发送goroutine发出后ack可能立即返回
 	this.messageToAck.Push(*(mimcPacket.PacketId), timeoutPacket)
+	if register != nil {
+		register(*(mimcPacket.PacketId))
+	}
+	this.messageToSend.Push(msgPacket)
 	return *(mimcPacket.PacketId)
 }
 
-func (this *MCUser) sendRoutine() {
-	logger.Info("initate send goroutine.")
+func (this *MCUser) sendRoutine(closed <-chan struct{}) {
+	this.Logger().Info("initate send goroutine.")
 	if this.conn == nil {
 		return
 	}
+	conn := this.conn
+	defer this.closeConn(conn)
 	msgType := cnst.MIMC_C2S_DOUBLE_DIRECTION
 
-	for {
+	for !isClosed(closed) {
 		var pkt *packet.MIMCV6Packet = nil
 		if this.conn.Status() == NOT_CONNECTED {
-			logger.Debug("the conn not connected.\n")
+			this.Logger().Debug("the conn not connected.\n")
 			currTimeMillis := CurrentTimeMillis()
-			if currTimeMillis-this.lastCreateConnTimestamp <= cnst.CONNECT_TIMEOUT {
+			if currTimeMillis-atomic.LoadInt64(&this.lastCreateConnTimestamp) <= cnst.CONNECT_TIMEOUT {
 				Sleep(100)
 				continue
 			}
-			this.lastCreateConnTimestamp = CurrentTimeMillis()
+			atomic.StoreInt64(&this.lastCreateConnTimestamp, CurrentTimeMillis())
 			if !this.conn.Connect() {
-				logger.Warn("connet to MIMC Server fail.\n")
+				this.Logger().Warn("connet to MIMC Server fail.\n")
 				continue
 			}
 			this.conn.Sock_Connected()
-			this.lastCreateConnTimestamp = 0
-			logger.Info("%v: build conn packet.", this.appAccount)
+			atomic.StoreInt64(&this.lastCreateConnTimestamp, 0)
+			this.Logger().Info("%v: build conn packet.", this.appAccount)
 			pkt = BuildConnectionPacket(this.conn.Udid(), this)
 		}
 		if this.conn.Status() == SOCK_CONNECTED {
@@ -269,38 +447,42 @@ func (this *MCUser) sendRoutine() {
 		}
 		if this.conn.Status() == HANDSHAKE_CONNECTED {
 			currTimeMillis := CurrentTimeMillis()
-			if this.status == Offline && currTimeMillis-this.lastLoginTimestamp <= cnst.LOGIN_TIMEOUT {
+			offline := this.Status() == Offline
+			lastLoginTimestamp := atomic.LoadInt64(&this.lastLoginTimestamp)
+			if offline && currTimeMillis-lastLoginTimestamp <= cnst.LOGIN_TIMEOUT {
 				Sleep(100)
 				continue
 			}
-			if this.tryLogin && this.status == Offline && currTimeMillis-this.lastLoginTimestamp > cnst.LOGIN_TIMEOUT {
-				logger.Debug("%v: build bind packet.", this.appAccount)
+			if this.isTryLogin() && offline && currTimeMillis-lastLoginTimestamp > cnst.LOGIN_TIMEOUT {
+				this.Logger().Debug("%v: build bind packet.", this.appAccount)
 				pkt = BuildBindPacket(this)
 				if pkt == nil {
 					Sleep(100)
 					continue
 				}
 			}
-			this.lastLoginTimestamp = CurrentTimeMillis()
+			atomic.StoreInt64(&this.lastLoginTimestamp, CurrentTimeMillis())
 		}
-		if this.status == Online {
+		if this.Status() == Online {
 
 			msgPacketToSend := this.messageToSend.Pop()
 			if msgPacketToSend == nil {
 				dist := CurrentTimeMillis() - this.lastPingTimestamp
-				isPing := dist-cnst.PING_TIMEVAL_MS > 0
+				isPing := dist-this.heartbeat.Interval() > 0
 				if isPing {
 					pkt = BuildPingPacket(this)
-					logger.Info("%v: build ping packet.", this.appAccount)
+					this.heartbeat.pingSent(CurrentTimeMillis())
+					this.Logger().Info("%v: build ping packet.", this.appAccount)
 				} else {
 					Sleep(100)
 					continue
 				}
 			} else {
 				msgPacket := msgPacketToSend.(*msg.MsgPacket)
+				this.heartbeat.active()
 				msgType = msgPacket.MsgType()
 				pkt = msgPacket.Packet()
-				logger.Debug("%v: send msg packet.", this.appAccount)
+				this.Logger().Debug("%v: send msg packet.", this.appAccount)
 
 			}
 
@@ -317,34 +499,60 @@ func (this *MCUser) sendRoutine() {
 		if msgType == cnst.MIMC_C2S_DOUBLE_DIRECTION {
 			this.conn.TrySetNextResetSockTs()
 		}
-		payloadKey := PayloadKey(this.securityKey, pkt.HeaderId())
+		this.recordFrame(inspect.DIRECTION_OUT, pkt)
+		payloadKey := PayloadKey(this.SecKey(), pkt.HeaderId())
 		bodyKey := this.conn.Rc4Key()
 		packetData := pkt.Bytes(bodyKey, payloadKey)
 		this.lastPingTimestamp = CurrentTimeMillis()
 		size := len(packetData)
 		if this.Conn().Writen(&packetData, size) != size {
-			logger.Error("write data error.")
+			this.Logger().Error("write data error.")
 			this.conn.Reset()
 		} else {
+			this.metrics.AddBytesSent(this.appAccount, size)
 			if pkt.GetHeader() != nil {
-				logger.Debug("[send]: send packet: %v succ.\n", *(pkt.GetHeader().Id))
+				this.Logger().With(log.FieldPacketId, pkt.GetHeader().GetId(), log.FieldCmd, pkt.GetHeader().GetCmd()).Debug("[send]: send packet: %v succ.\n", *(pkt.GetHeader().Id))
 			} else {
-				logger.Debug("[send]: send packet succ.\n")
+				this.Logger().Debug("[send]: send packet succ.\n")
 			}
 
 		}
 	}
 }
+
+// Close后发送goroutine退出时调用，在线时尽量发送UNBIND，然后关闭连接
+func (this *MCUser) closeConn(conn *MIMCConnection) {
+	if conn.Status() == HANDSHAKE_CONNECTED && this.Status() == Online {
+		pkt := BuildUnBindPacket(this)
+		packetData := pkt.Bytes(conn.Rc4Key(), PayloadKey(this.SecKey(), pkt.HeaderId()))
+		if conn.Writen(&packetData, len(packetData)) != len(packetData) {
+			this.Logger().Warn("send unbind packet fail.")
+		}
+	}
+	this.setStatus(Offline)
+	conn.Close()
+	this.Logger().Info("send goroutine exits, conn closed.")
+}
+
 func (this *MCUser) PeerFetcher(fetcher frontend.ProdFrontPeerFetcher) {
-	this.conn.PeerFetcher(fetcher)
+	this.SetPeerFetcher(fetcher)
 }
-func (this *MCUser) receiveRoutine() {
-	logger.Info("initate receive goroutine.\n")
+
+// 指定获取前端地址的方式，可以在InitAndSetup之前调用，重新InitAndSetup和连接重置后仍然保留
+func (this *MCUser) SetPeerFetcher(fetcher frontend.IFrontendPeerFetcher) *MCUser {
+	this.peerFetcher = fetcher
+	if this.conn != nil {
+		this.conn.PeerFetcher(fetcher)
+	}
+	return this
+}
+func (this *MCUser) receiveRoutine(closed <-chan struct{}) {
+	this.Logger().Info("initate receive goroutine.\n")
 	var counter int = 0
 	if this.conn == nil {
 		return
 	}
-	for {
+	for !isClosed(closed) {
 		if this.conn.Status() == NOT_CONNECTED {
 			Sleep(1000)
 			continue
@@ -352,27 +560,16 @@ func (this *MCUser) receiveRoutine() {
 		headerBins := make([]byte, cnst.V6_HEAD_LENGTH)
 		length := this.conn.Readn(&headerBins, int(cnst.V6_HEAD_LENGTH))
 		if length != int(cnst.V6_HEAD_LENGTH) {
-			logger.Error("%v->[rcv]: error head. need length: %v, read length: %v\n", this.appAccount, cnst.V6_HEAD_LENGTH, length)
+			this.Logger().Error("%v->[rcv]: error head. need length: %v, read length: %v\n", this.appAccount, cnst.V6_HEAD_LENGTH, length)
 			this.conn.Reset()
 			Sleep(1000)
 			continue
 
 		}
-		magic := byteutil.GetUint16FromBytes(&headerBins, cnst.V6_MAGIC_OFFSET)
-		if magic != cnst.MAGIC {
-			logger.Error("%v->[rcv]: error magic: %v.", this.appAccount, magic)
-			this.conn.Reset()
-			continue
-		}
-		version := byteutil.GetUint16FromBytes(&headerBins, cnst.V6_VERSION_OFFSET)
-		if version != cnst.V6_VERSION {
-			logger.Error("%v->[rcv]: error version: %v.", this.appAccount, version)
-			this.conn.Reset()
-			continue
-		}
-		bodyLen := byteutil.GetIntFromBytes(&headerBins, cnst.V6_BODYLEN_OFFSET)
-		if bodyLen < 0 {
-			logger.Error("%v->[rcv]: error bodylen: %v.", this.appAccount, bodyLen)
+		// 校验magic、version，包体长度超过上限时不分配内存，直接重置连接
+		bodyLen, err := packet.ParseHead(headerBins)
+		if err != nil {
+			this.Logger().Error("%v->[rcv]: %v.", this.appAccount, err)
 			this.conn.Reset()
 			continue
 		}
@@ -382,7 +579,7 @@ func (this *MCUser) receiveRoutine() {
 			if bodyLen != 0 {
 				length = this.conn.Readn(&bodyBins, bodyLen)
 				if length != bodyLen {
-					logger.Error("%v->[rcv]: error body.length: %v, bodyLen:%v", this.appAccount, length, bodyLen)
+					this.Logger().Error("%v->[rcv]: error body.length: %v, bodyLen:%v", this.appAccount, length, bodyLen)
 					this.conn.Reset()
 					continue
 				} else {
@@ -393,51 +590,71 @@ func (this *MCUser) receiveRoutine() {
 		crcBins := make([]byte, cnst.V6_CRC_LENGTH)
 		crclen := this.conn.Readn(&crcBins, cnst.V6_CRC_LENGTH)
 		if crclen != cnst.V6_CRC_LENGTH {
-			logger.Error("%v->[rcv]: error crc: %v.", this.appAccount, crclen)
+			this.Logger().Error("%v->[rcv]: error crc: %v.", this.appAccount, crclen)
 			this.conn.Reset()
 			continue
 		}
 		this.conn.ClearSockTimestamp()
+		this.metrics.AddBytesReceived(this.appAccount, int(cnst.V6_HEAD_LENGTH)+bodyLen+cnst.V6_CRC_LENGTH)
 		bodyKey := this.conn.Rc4Key()
-		packetBytes := packet.NewPacketBytes(&headerBins, &bodyBins, &crcBins, &bodyKey, &(this.securityKey))
+		secKey := this.SecKey()
+		packetBytes := packet.NewPacketBytes(&headerBins, &bodyBins, &crcBins, &bodyKey, &secKey)
 		counter += 1
 		this.packetToCallback.Push(packetBytes)
 	}
 }
-func (this *MCUser) triggerRoutine() {
-	logger.Info("initiate trigger goroutine.")
+func (this *MCUser) triggerRoutine(closed <-chan struct{}) {
+	this.Logger().Info("initiate trigger goroutine.")
 	if this.conn == nil {
 		return
 	}
-	for {
+	for !isClosed(closed) {
 		nowTimeMillis := CurrentTimeMillis()
 		nextRestSockTimeMillis := this.conn.NextResetSockTimestamp()
 		if nextRestSockTimeMillis > 0 && nowTimeMillis-nextRestSockTimeMillis > 0 {
-			logger.Warn("[trigger] wait for response timeout.")
+			this.Logger().Warn("[trigger] wait for response timeout.")
 			this.conn.Reset()
 		}
 		Sleep(200)
+		this.reportQueueDepth()
+		this.expireFragments()
+		this.expireGroupMessages()
+		this.expireTransients()
+		if this.heartbeat.expire(nowTimeMillis) {
+			this.Logger().Warn("[trigger] %v pongs missed, reset conn.", cnst.PING_MAX_MISSED)
+			this.conn.Reset()
+		}
 		this.scanAndCallback()
 	}
 }
 
-func (this *MCUser) callBackRoutine() {
-	logger.Info("initiate callback goroutine.")
+func (this *MCUser) reportQueueDepth() {
+	this.metrics.SetQueueDepth(this.appAccount, metrics.QueueMessageToSend, int(this.messageToSend.Size()))
+	this.metrics.SetQueueDepth(this.appAccount, metrics.QueueMessageToAck, this.messageToAck.Size())
+	this.metrics.SetQueueDepth(this.appAccount, metrics.QueuePacketToCallback, int(this.packetToCallback.Size()))
+}
+
+func (this *MCUser) callBackRoutine(closed <-chan struct{}) {
+	this.Logger().Info("initiate callback goroutine.")
 	if this.conn == nil {
 		return
 	}
-	for {
+	for !isClosed(closed) {
 		//logger.Info("%v size: %v", this.appAccount, this.packetToCallback.Size())
 		pktByts := this.packetToCallback.Pop()
 		if pktByts != nil {
 			packetBytes := pktByts.(*packet.PacketBytes)
-			v6Packet := packet.ParseBytesToPacket(packetBytes.HeaderBins, packetBytes.BodyBins, packetBytes.CrcBins, packetBytes.BodyKey, packetBytes.SecKey)
-			if v6Packet == nil {
-				logger.Error("[rcv]: parse into v6Packet fail.")
+			v6Packet, err := packet.Parse(*packetBytes.HeaderBins, *packetBytes.BodyBins, *packetBytes.CrcBins, *packetBytes.BodyKey, *packetBytes.SecKey)
+			if err != nil {
+				if errors.Is(err, packet.ErrCrc) {
+					this.metrics.IncCrcFailure(this.appAccount)
+				}
+				this.Logger().Error("[rcv]: parse into v6Packet fail: %v", err)
 				this.conn.Reset()
 				continue
 			}
 			//logger.Info("%v size: %v", this.appAccount, this.packetToCallback.Size())
+			this.recordFrame(inspect.DIRECTION_IN, v6Packet)
 			this.handleResponse(v6Packet)
 		} else {
 			Sleep(100)
@@ -447,129 +664,243 @@ func (this *MCUser) callBackRoutine() {
 
 func (this *MCUser) scanAndCallback() {
 	if this.msgDelegate == nil {
-		logger.Warn("%v need to handle Message for timeout.", this.appAccount)
+		this.Logger().Warn("%v need to handle Message for timeout.", this.appAccount)
 		return
 	}
+	// 持有锁时只取出超时的包，回调在释放锁后进行，回调中可以重新发送消息
 	this.messageToAck.Lock()
-	defer this.messageToAck.Unlock()
 	kvs := this.messageToAck.KVs()
-	timeoutKeys := list.New()
+	timeoutPackets := list.New()
 	for key := range kvs {
 		timeoutPacket := kvs[key].(*packet.MIMCTimeoutPacket)
 		if CurrentTimeMillis()-timeoutPacket.Timestamp() < cnst.CHECK_TIMEOUT_TIMEVAL_MS {
 			continue
 		}
-		mimcPacket := timeoutPacket.Packet()
-		if *(mimcPacket.Type) == MIMC_MSG_TYPE_P2P_MESSAGE {
-			p2pMessage := new(MIMCP2PMessage)
-			err := Deserialize(mimcPacket.Payload, p2pMessage)
-			if !err {
-				return
-			}
-			p2pMsg := msg.NewP2pMsg(mimcPacket.PacketId, p2pMessage.From.AppAccount, p2pMessage.To.AppAccount, mimcPacket.Sequence, mimcPacket.Timestamp, p2pMessage.Payload)
+		timeoutPackets.PushBack(timeoutPacket)
+		// 已经持有锁，直接从kvs中删除，调用Pop会死锁
+		delete(kvs, key)
+	}
+	this.messageToAck.Unlock()
+	for ele := timeoutPackets.Front(); ele != nil; ele = ele.Next() {
+		this.handleSendTimeout(ele.Value.(*packet.MIMCTimeoutPacket))
+	}
+}
+
+func (this *MCUser) handleSendTimeout(timeoutPacket *packet.MIMCTimeoutPacket) {
+	mimcPacket := timeoutPacket.Packet()
+	if mimcPacket.GetType() == MIMC_MSG_TYPE_P2P_MESSAGE {
+		p2pMessage := new(MIMCP2PMessage)
+		if !Deserialize(mimcPacket.Payload, p2pMessage) {
+			return
+		}
+		p2pMsg := msg.NewP2pMsg(mimcPacket.PacketId, p2pMessage.From.AppAccount, p2pMessage.To.AppAccount, mimcPacket.Sequence, mimcPacket.Timestamp, this.timeoutPayload(timeoutPacket, p2pMessage.Payload))
+		this.metrics.IncSendTimeout(this.appAccount, metrics.MsgTypeP2P)
+		this.recordStatus(*(mimcPacket.PacketId), store.STATUS_TIMEOUT, 0, 0)
+		if !this.resolveFragment(*(mimcPacket.PacketId), false) && !this.resolveInternal(*(mimcPacket.PacketId), false) {
 			this.msgDelegate.HandleSendMessageTimeout(p2pMsg)
-		} else if *(mimcPacket.Type) == MIMC_MSG_TYPE_P2T_MESSAGE {
-			p2tMessage := new(MIMCP2TMessage)
-			err := Deserialize(mimcPacket.Payload, p2tMessage)
-			if !err {
-				return
-			}
-			p2tMsg := msg.NewP2tMsg(mimcPacket.PacketId, p2tMessage.From.AppAccount, mimcPacket.Sequence, mimcPacket.Timestamp, p2tMessage.To.TopicId, p2tMessage.Payload)
+		}
+	} else if mimcPacket.GetType() == MIMC_MSG_TYPE_P2T_MESSAGE {
+		p2tMessage := new(MIMCP2TMessage)
+		if !Deserialize(mimcPacket.Payload, p2tMessage) {
+			return
+		}
+		p2tMsg := msg.NewP2tMsg(mimcPacket.PacketId, p2tMessage.From.AppAccount, mimcPacket.Sequence, mimcPacket.Timestamp, p2tMessage.To.TopicId, this.timeoutPayload(timeoutPacket, p2tMessage.Payload))
+		this.metrics.IncSendTimeout(this.appAccount, metrics.MsgTypeP2T)
+		this.recordStatus(*(mimcPacket.PacketId), store.STATUS_TIMEOUT, 0, 0)
+		if !this.resolveFragment(*(mimcPacket.PacketId), false) {
 			this.msgDelegate.HandleSendGroupMessageTimeout(p2tMsg)
 		}
-		timeoutKeys.PushBack(key)
 	}
-	for ele := timeoutKeys.Front(); ele != nil; ele = ele.Next() {
-		packet := this.messageToAck.Pop(ele.Value.(string))
-		if packet == nil {
-			logger.Warn("pop message fails. packetId: %v", ele.Value.(string))
-		}
+}
+
+// 超时回调使用发送前的原始payload，分片等没有记录原始payload的消息尝试解压
+func (this *MCUser) timeoutPayload(timeoutPacket *packet.MIMCTimeoutPacket, payload []byte) []byte {
+	if timeoutPacket.Payload() != nil {
+		return timeoutPacket.Payload()
 	}
+	return this.decompressPayload(payload)
 }
 
 func (this *MCUser) handleResponse(v6Packet *packet.MIMCV6Packet) {
-	if v6Packet.GetHeader() == nil {
-		logger.Info("[handle packet]get a pong packet.")
+	this.dispatchResponse(v6Packet, false)
+}
+
+/**
+ * 处理服务端下发的包。replay为true时用于回放录制的会话，只触发回调，
+ * 不做握手、重定向、重置连接、重新登录，也不修改登录状态。
+ */
+func (this *MCUser) dispatchResponse(v6Packet *packet.MIMCV6Packet, replay bool) {
+	if v6Packet.GetHeader() == nil || v6Packet.GetHeader().GetCmd() == cnst.CMD_PING {
+		this.handlePong()
 		return
 	}
-	cmd := v6Packet.GetHeader().Cmd
+	header := v6Packet.GetHeader()
+	// 头部的错误码先交给ErrorDelegate，CONN、BIND等响应仍按原流程处理，以便重定向和token过期重登
+	hasErr := header.GetErrCode() != 0 || len(header.GetErrStr()) > 0
+	if hasErr {
+		this.handleError(newServerError(header.GetCmd(), header.GetSubcmd(), header.GetId(), header.GetErrCode(), header.GetErrStr()))
+	}
+	cmd := header.Cmd
 	if cnst.CMD_SECMSG == *cmd {
+		if hasErr {
+			this.failSent(header.GetId())
+			return
+		}
 		this.handleSecMsg(v6Packet)
 	} else if cnst.CMD_CONN == *cmd {
-		logger.Debug("[handle packet] conn response.")
+		this.Logger().Debug("[handle packet] conn response.")
+		if replay {
+			return
+		}
 		connResp := new(XMMsgConnResp)
 		err := Deserialize(v6Packet.GetPayload(), connResp)
-		if !err {
-			logger.Error("[handle packet] parse connResp fail.")
+		// challenge为空时无法生成包体的RC4密钥
+		if !err || connResp.GetChallenge() == "" {
+			this.Logger().Error("[handle packet] parse connResp fail.")
 			this.conn.Reset()
 			return
 		}
-		this.conn.HandshakeConnected()
-		logger.Debug("[handle packet] handshake succ.")
+		if this.conn.ApplyConnResp(connResp.GetHost(), connResp.GetPsc()) {
+			this.Logger().Info("[handle packet] server redirects to host: %v, reconnect.", connResp.GetHost())
+			this.conn.reset(false)
+			return
+		}
+		// 先生成RC4密钥再标记握手成功，否则发送goroutine可能用空密钥发出BIND
 		this.conn.SetChallenge(*(connResp.Challenge))
 		this.conn.SetChallengeAndRc4Key(*(connResp.Challenge))
+		this.conn.HandshakeConnected()
+		this.Logger().Debug("[handle packet] handshake succ.")
 	} else if cnst.CMD_BIND == *cmd {
 		bindResp := new(XMMsgBindResp)
 		err := Deserialize(v6Packet.GetPayload(), bindResp)
 		if err {
-			if *bindResp.Result {
-				this.status = Online
-				this.lastLoginTimestamp = 0
-				logger.Debug("[handle packet] login succ.")
+			if replay {
+				this.Logger().Debug("[replay] bind response, result: %v.", bindResp.GetResult())
+			} else if bindResp.GetResult() {
+				this.setStatus(Online)
+				atomic.StoreInt64(&this.lastLoginTimestamp, 0)
+				this.conn.ResetRedirects()
+				this.Logger().Debug("[handle packet] login succ.")
 			} else {
-
-				if cnst.MIMC_TOKEN_EXPIRE == *(bindResp.ErrorType) {
-					logger.Warn("[handle packet] token expired, relogin().")
+				this.metrics.IncBindFailure(this.appAccount, bindResp.GetErrorType())
+				if cnst.MIMC_TOKEN_EXPIRE == bindResp.GetErrorType() {
+					this.Logger().Warn("[handle packet] token expired, relogin().")
+					if invalidator, ok := this.tokenDelegate.(TokenInvalidator); ok {
+						invalidator.Invalidate()
+					}
 					this.Login()
 				} else {
-					this.status = Offline
-					logger.Warn("[handle packet] login fail. %v", err)
+					this.setStatus(Offline)
+					this.Logger().Warn("[handle packet] login fail. %v", err)
 				}
 
 			}
 			if this.statusDelegate == nil {
-				logger.Warn("%v status changed, you need to handle this.", this.appAccount)
+				this.Logger().Warn("%v status changed, you need to handle this.", this.appAccount)
 			} else {
-				this.statusDelegate.HandleChange(*(bindResp.Result), bindResp.ErrorType, bindResp.ErrorReason, bindResp.ErrorDesc)
+				this.statusDelegate.HandleChange(bindResp.GetResult(), bindResp.ErrorType, bindResp.ErrorReason, bindResp.ErrorDesc)
 			}
 		}
 	} else if cnst.CMD_KICK == *cmd {
-		this.status = Offline
+		if !replay {
+			this.setStatus(Offline)
+		}
 		kick := "kick"
-		logger.Debug("[handle] logout succ.")
+		this.Logger().Debug("[handle] logout succ.")
 		if this.statusDelegate == nil {
-			logger.Warn("%v status changed, you need to handle this.", this.appAccount)
+			this.Logger().Warn("%v status changed, you need to handle this.", this.appAccount)
 		} else {
 			this.statusDelegate.HandleChange(false, &kick, &kick, &kick)
 		}
+	} else if cnst.CMD_NOTIFY == *cmd {
+		notify := new(XMMsgNotify)
+		if !Deserialize(v6Packet.GetPayload(), notify) {
+			this.Logger().Warn("[handle packet] parse notify fail.")
+			return
+		}
+		this.handleError(newServerError(*cmd, header.GetSubcmd(), header.GetId(), notify.GetErrCode(), notify.GetErrStr()))
 	} else {
-		logger.Debug("cmd: %v", *cmd)
+		this.Logger().Debug("cmd: %v", *cmd)
+		return
+	}
+}
+
+// 服务端拒绝了packetId对应的包，错误已经上报，从超时队列中移除，避免再回调一次超时
+func (this *MCUser) failSent(packetId string) {
+	if len(packetId) == 0 {
 		return
 	}
+	this.messageToAck.Pop(packetId)
+	this.transientPackets.Pop(packetId)
+	this.internalPackets.Pop(packetId)
+	this.recordStatus(packetId, store.STATUS_FAILED, 0, 0)
+	this.resolveFragment(packetId, false)
+}
+
+func (this *MCUser) handleError(serverError *ServerError) {
+	this.Logger().With(log.FieldCmd, serverError.Cmd, log.FieldPacketId, serverError.PacketId).Warn("[handle packet] server error, code: %v, reason: %v.", serverError.Code, serverError.Reason)
+	this.reportError(serverError)
+}
+
+func (this *MCUser) reportError(err error) {
+	if this.errDelegate == nil {
+		this.Logger().Warn("%v need to regist error handler for errors.", this.appAccount)
+		return
+	}
+	this.errDelegate.HandleError(err)
+}
+
+func (this *MCUser) handlePong() {
+	rtt := this.heartbeat.pongReceived(CurrentTimeMillis())
+	if rtt < 0 {
+		this.Logger().Debug("[handle packet] get an unexpected pong packet.")
+		return
+	}
+	this.metrics.ObservePingRtt(this.appAccount, time.Duration(rtt)*time.Millisecond)
+	this.Logger().Debug("[handle packet] get a pong packet, rtt: %vms, next interval: %vms.", rtt, this.heartbeat.Interval())
 }
 
 func (this *MCUser) handleSecMsg(v6Packet *packet.MIMCV6Packet) {
 	if this.msgDelegate == nil {
-		logger.Warn("%v need to regist mssage handler for received messages.", this.appAccount)
+		this.Logger().Warn("%v need to regist mssage handler for received messages.", this.appAccount)
 	}
 	mimcPacket := new(MIMCPacket)
 	err := Deserialize(v6Packet.GetPayload(), mimcPacket)
 	if !err {
-		logger.Warn("[handleSecMsg] unserialize mimcPacket fails.%v", err)
+		this.Logger().Warn("[handleSecMsg] unserialize mimcPacket fails.%v", err)
 		return
 	} else {
-		switch *(mimcPacket.Type) {
+		switch mimcPacket.GetType() {
 		case MIMC_MSG_TYPE_PACKET_ACK:
-			logger.Debug("handle Sec Msg] packet Ack.")
+			this.Logger().Debug("handle Sec Msg] packet Ack.")
 			packetAck := new(MIMCPacketAck)
 			err := Deserialize(mimcPacket.Payload, packetAck)
-			if !err {
+			if !err || packetAck.PacketId == nil {
 				return
 			}
-			this.msgDelegate.HandleServerAck(packetAck.PacketId, packetAck.Sequence, packetAck.Timestamp)
-			packet := this.messageToAck.Pop(*(packetAck.PacketId))
-			if packet == nil {
-				logger.Warn("pop message fails. packetId: %v", *(packetAck.PacketId))
+			if len(packetAck.GetErrorMsg()) > 0 {
+				serverError := newServerError(cnst.CMD_SECMSG, "", packetAck.GetPacketId(), 0, packetAck.GetErrorMsg())
+				if serverError.Err == nil {
+					serverError.Err = ErrPacketReject
+				}
+				this.handleError(serverError)
+				// 被拒绝的包按发送失败处理，不回调HandleServerAck
+				this.failSent(packetAck.GetPacketId())
+				return
+			}
+			this.recordStatus(packetAck.GetPacketId(), store.STATUS_ACKED, packetAck.GetSequence(), packetAck.GetTimestamp())
+			if !this.resolveFragment(packetAck.GetPacketId(), true) && !this.resolveInternal(packetAck.GetPacketId(), true) {
+				this.msgDelegate.HandleServerAck(packetAck.PacketId, packetAck.Sequence, packetAck.Timestamp)
+			}
+			timeoutPacket := this.messageToAck.Pop(*(packetAck.PacketId))
+			if timeoutPacket == nil {
+				if this.resolveTransient(packetAck.GetPacketId()) {
+					break
+				}
+				this.Logger().Warn("pop message fails. packetId: %v", *(packetAck.PacketId))
+			} else {
+				latency := CurrentTimeMillis() - timeoutPacket.(*packet.MIMCTimeoutPacket).Timestamp()
+				this.metrics.ObserveAckLatency(this.appAccount, time.Duration(latency)*time.Millisecond)
 			}
 			break
 		case MIMC_MSG_TYPE_COMPOUND:
@@ -578,46 +909,88 @@ func (this *MCUser) handleSecMsg(v6Packet *packet.MIMCV6Packet) {
 			if !err {
 				return
 			}
-			if this.resource != *(packetList.Resource) {
-				logger.Warn("Handle SecMsg MIMCPacketList resource:, current resource:", *(packetList.Resource), this.resource)
+			if resource := this.Resource(); resource != packetList.GetResource() {
+				this.Logger().Warn("Handle SecMsg MIMCPacketList resource: %v, current resource: %v", packetList.GetResource(), resource)
 				return
 			}
 			seqAckPacket := BuildSequenceAckPacket(this, packetList)
 			pktToSend := msg.NewMsgPacket(cnst.MIMC_C2S_SINGLE_DIRECTION, seqAckPacket)
 			this.messageToSend.Push(pktToSend)
 			pktNum := len(packetList.Packets)
+			// 分片重组、解密、解压和识别SDK内部消息与callback包共用同一流程
+			decoder := this.payloadDecoder()
 			p2pMsgList := list.New()
 			p2tMsgList := list.New()
 			for i := 0; i < pktNum; i++ {
 				packet := packetList.Packets[i]
-				if packet == nil {
+				if packet == nil || packet.PacketId == nil {
 					continue
 				}
-				if *(packet.Type) == MIMC_MSG_TYPE_P2P_MESSAGE {
+				// 服务端没有下发sequence、timestamp时按0处理，回调中的指针不为nil
+				sequence, timestamp := packet.GetSequence(), packet.GetTimestamp()
+				if packet.GetType() == MIMC_MSG_TYPE_P2P_MESSAGE {
 					p2pMessage := new(MIMCP2PMessage)
 					err := Deserialize(packet.Payload, p2pMessage)
-					if !err {
+					if !err || p2pMessage.From == nil || p2pMessage.From.AppAccount == nil || p2pMessage.To == nil || p2pMessage.To.AppAccount == nil {
+						continue
+					}
+					fromAccount := p2pMessage.From.GetAppAccount()
+					decoded := decoder.DecodeP2P(fromAccount, packet.GetPacketId(), p2pMessage.Payload)
+					switch decoded.Kind {
+					case PAYLOAD_INCOMPLETE:
+						continue
+					case PAYLOAD_DROPPED:
+						this.reportE2EError(fromAccount, decoded.PacketId, decoded.Err)
+						continue
+					case PAYLOAD_RECEIPT:
+						this.handleReceipt(fromAccount, decoded.Payload)
 						continue
+					case PAYLOAD_SENDER_KEY:
+						// 没有开启端到端加密时按普通消息交给应用
+						if this.handleSenderKeyDistribution(fromAccount, decoded.PacketId, decoded.Encrypted, decoded.Payload) {
+							continue
+						}
 					}
-					p2pMsgList.PushBack(msg.NewP2pMsg(packet.PacketId, p2pMessage.From.AppAccount, p2pMessage.To.AppAccount, packet.Sequence, packet.Timestamp, p2pMessage.Payload))
+					packetId := &decoded.PacketId
+					p2pMsg := msg.NewP2pMsg(packetId, p2pMessage.From.AppAccount, p2pMessage.To.AppAccount, &sequence, &timestamp, decoded.Payload)
+					p2pMsgList.PushBack(p2pMsg.SetTransient(p2pMessage.IsStore != nil && !p2pMessage.GetIsStore()))
 					continue
-				} else if *(packet.Type) == MIMC_MSG_TYPE_P2T_MESSAGE {
+				} else if packet.GetType() == MIMC_MSG_TYPE_P2T_MESSAGE {
 					p2tMessage := new(MIMCP2TMessage)
 					err := Deserialize(packet.Payload, p2tMessage)
 
-					if !err {
+					if !err || p2tMessage.From == nil || p2tMessage.From.AppAccount == nil || p2tMessage.To == nil || p2tMessage.To.TopicId == nil {
 						continue
 					}
-					p2tMsgList.PushBack(msg.NewP2tMsg(packet.PacketId, p2tMessage.From.AppAccount, packet.Sequence, packet.Timestamp, p2tMessage.To.TopicId, p2tMessage.Payload))
+					decoded := decoder.DecodeP2T(p2tMessage.To.GetTopicId(), p2tMessage.From.GetAppAccount(), packet.GetPacketId(), p2tMessage.Payload)
+					if decoded.Kind == PAYLOAD_INCOMPLETE {
+						continue
+					}
+					message := &pendingGroupMessage{
+						arrived:     CurrentTimeMillis(),
+						packetId:    &decoded.PacketId,
+						fromAccount: p2tMessage.From.AppAccount,
+						sequence:    &sequence,
+						timestamp:   &timestamp,
+						topicId:     p2tMessage.To.TopicId,
+						payload:     decoded.Payload,
+						transient:   p2tMessage.IsStore != nil && !p2tMessage.GetIsStore(),
+					}
+					if p2tMsg := this.deliverGroupMessage(message, decoded); p2tMsg != nil {
+						p2tMsgList.PushBack(p2tMsg)
+					}
 					continue
 				}
 			}
 			if p2pMsgList.Len() > 0 {
 				//logger.Info("call p2p msg handler.")
+				this.recordReceived(p2pMsgList)
 				this.msgDelegate.HandleMessage(p2pMsgList)
+				this.sendDeliveryReceipts(p2pMsgList)
 			}
 			if p2tMsgList.Len() > 0 {
-				logger.Info("call p2t msg handler.")
+				this.Logger().Info("call p2t msg handler.")
+				this.recordReceived(p2tMsgList)
 				this.msgDelegate.HandleGroupMessage(p2tMsgList)
 			}
 			break
@@ -627,18 +1000,25 @@ func (this *MCUser) handleSecMsg(v6Packet *packet.MIMCV6Packet) {
 	}
 }
 func (this *MCUser) handleToken() {
-	this.token = this.tokenDelegate.FetchToken()
+	this.SetToken(this.tokenDelegate.FetchToken())
 }
 
 func (this *MCUser) SetResource(resource string) *MCUser {
+	this.stateLock.Lock()
 	this.resource = resource
+	this.stateLock.Unlock()
+	this.refreshLogger()
 	return this
 }
 func (this *MCUser) SetUuid(uuid int64) *MCUser {
+	this.stateLock.Lock()
+	defer this.stateLock.Unlock()
 	this.uuid = uuid
 	return this
 }
 func (this *MCUser) SetChid(chid float64) *MCUser {
+	this.stateLock.Lock()
+	defer this.stateLock.Unlock()
 	this.chid = chid
 	return this
 }
@@ -647,22 +1027,31 @@ func (this *MCUser) SetConn(conn *MIMCConnection) *MCUser {
 	return this
 }
 func (this *MCUser) SetToken(token *string) *MCUser {
+	this.stateLock.Lock()
+	defer this.stateLock.Unlock()
 	this.token = token
 	return this
 }
 func (this *MCUser) SetSecKey(secKey string) *MCUser {
+	this.stateLock.Lock()
+	defer this.stateLock.Unlock()
 	this.securityKey = secKey
 	return this
 }
 func (this *MCUser) SetAppPackage(appPackage string) *MCUser {
+	this.stateLock.Lock()
+	defer this.stateLock.Unlock()
 	this.appPackage = appPackage
 	return this
 }
 func (this *MCUser) SetAppAccount(appAccount string) *MCUser {
 	this.appAccount = appAccount
+	this.refreshLogger()
 	return this
 }
 func (this *MCUser) SetAppId(appId int64) *MCUser {
+	this.stateLock.Lock()
+	defer this.stateLock.Unlock()
 	this.appId = appId
 	return this
 }
@@ -671,6 +1060,8 @@ func (this *MCUser) AppAccount() string {
 	return this.appAccount
 }
 func (this *MCUser) AppId() int64 {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
 	return this.appId
 }
 func (this *MCUser) Conn() *MIMCConnection {
@@ -678,18 +1069,28 @@ func (this *MCUser) Conn() *MIMCConnection {
 }
 
 func (this *MCUser) Uuid() int64 {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
 	return this.uuid
 }
 func (this *MCUser) Chid() float64 {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
 	return this.chid
 }
 func (this *MCUser) Resource() string {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
 	return this.resource
 }
 func (this *MCUser) SecKey() string {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
 	return this.securityKey
 }
 func (this *MCUser) Token() *string {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
 	return this.token
 }
 func (this *MCUser) ClientAttrs() string {
@@ -699,9 +1100,56 @@ func (this *MCUser) CloudAttrs() string {
 	return this.cloudAttrs
 }
 func (this *MCUser) AppPackage() string {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
 	return this.appPackage
 }
 
+func (this *MCUser) Logger() log.Logger {
+	this.loggerLock.RLock()
+	defer this.loggerLock.RUnlock()
+	if this.logger == nil {
+		return log.GetLogger()
+	}
+	return this.logger
+}
+
+// 与服务端协商得到的连接配置，InitAndSetup之前返回零值
+func (this *MCUser) ConnConfig() ConnConfig {
+	if this.conn == nil {
+		return ConnConfig{}
+	}
+	return this.conn.Config()
+}
+
+// 最近一次ping的往返时间，InitAndSetup之前返回0
+func (this *MCUser) PingRtt() time.Duration {
+	if this.heartbeat == nil {
+		return 0
+	}
+	return time.Duration(this.heartbeat.LastRtt()) * time.Millisecond
+}
+
 func (this *MCUser) Status() UserStatus {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
 	return this.status
 }
+
+func (this *MCUser) setStatus(status UserStatus) {
+	this.stateLock.Lock()
+	defer this.stateLock.Unlock()
+	this.status = status
+}
+
+func (this *MCUser) isTryLogin() bool {
+	this.stateLock.RLock()
+	defer this.stateLock.RUnlock()
+	return this.tryLogin
+}
+
+func (this *MCUser) setTryLogin(tryLogin bool) {
+	this.stateLock.Lock()
+	defer this.stateLock.Unlock()
+	this.tryLogin = tryLogin
+}
@@ -6,12 +6,16 @@ import (
 	"github.com/Xiaomi-mimc/mimc-go-sdk/cipher"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/demo/handler"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
 	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/util/byte"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/map"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/queue"
 	"github.com/golang/protobuf/proto"
 	"strconv"
 	"testing"
+	"time"
 )
 
 // online
@@ -220,3 +224,113 @@ func TestPayloadKey(t *testing.T) {
 
 	fmt.Printf("key: %v\nvalue: %v\nenVal: %v\nenVal: %v\n", []byte(key), value, enVal, enVal1)
 }
+
+type timeoutCollector struct {
+	nopDelegate
+	p2p, p2t int
+}
+
+func (this *timeoutCollector) HandleSendMessageTimeout(message *msg.P2PMessage) {
+	this.p2p++
+}
+
+func (this *timeoutCollector) HandleSendGroupMessageTimeout(message *msg.P2TMessage) {
+	this.p2t++
+}
+
+// 超时的包回调后从messageToAck中移除，扫描时持有锁，不能再调用Pop
+func TestScanAndCallbackTimeout(t *testing.T) {
+	collector := new(timeoutCollector)
+	user := NewUser("Alice").RegisterMessageDelegate(collector)
+	user.messageToSend = que.NewConQueue()
+	user.messageToAck = cmap.NewConMap()
+	user.internalPackets = cmap.NewConMap()
+	user.SendMessage("Bob", []byte("hello"))
+	topicId := int64(1)
+	user.SendGroupMessage(&topicId, []byte("hi"))
+	// 把发出的两条消息改为已经超时
+	var sent []*MIMCPacket
+	for _, value := range user.messageToAck.KVs() {
+		sent = append(sent, value.(*packet.MIMCTimeoutPacket).Packet())
+	}
+	for _, mimcPacket := range sent {
+		user.messageToAck.Push(mimcPacket.GetPacketId(), packet.NewTimeoutPacket(CurrentTimeMillis()-cnst.CHECK_TIMEOUT_TIMEVAL_MS, mimcPacket))
+	}
+	user.messageToAck.Push("fresh", packet.NewTimeoutPacket(CurrentTimeMillis(), &MIMCPacket{PacketId: proto.String("fresh"), Type: MIMC_MSG_TYPE_P2P_MESSAGE.Enum()}))
+
+	done := make(chan struct{})
+	go func() {
+		user.scanAndCallback()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("scanAndCallback deadlocked")
+	}
+	if collector.p2p != 1 || collector.p2t != 1 {
+		t.Errorf("expect one timeout of each type, got p2p: %v, p2t: %v", collector.p2p, collector.p2t)
+	}
+	if user.messageToAck.Size() != 1 || user.messageToAck.Pop("fresh") == nil {
+		t.Errorf("only the fresh packet should remain, size: %v", user.messageToAck.Size())
+	}
+}
+
+// 超时回调中重新发送消息
+type resendingDelegate struct {
+	timeoutCollector
+	user             *MCUser
+	fragmentFailures int
+}
+
+func (this *resendingDelegate) HandleSendMessageTimeout(message *msg.P2PMessage) {
+	this.p2p++
+	this.user.SendMessage(*message.ToAccount(), message.Payload())
+}
+
+func (this *resendingDelegate) HandleFragmentSendComplete(msgId string, total int) {}
+
+func (this *resendingDelegate) HandleFragmentSendFailure(msgId string, acked, total int) {
+	this.fragmentFailures++
+	this.user.SendMessage("Bob", []byte("retry"))
+}
+
+func (this *resendingDelegate) HandleFragmentReceiveFailure(conversation, msgId string, received, total int, err error) {
+}
+
+// 回调在释放messageToAck的锁后进行，回调中重新发送消息、处理分片超时不会死锁
+func TestTimeoutCallbackCanResend(t *testing.T) {
+	delegate := new(resendingDelegate)
+	user := NewUser("Alice").RegisterMessageDelegate(delegate)
+	delegate.user = user
+	user.RegisterFragmentDelegate(delegate).EnableFragmentation(FragmentConfig{FragmentSize: 16})
+	user.messageToSend = que.NewConQueue()
+	user.messageToAck = cmap.NewConMap()
+	user.internalPackets = cmap.NewConMap()
+	user.SendMessage("Bob", []byte("hello"))
+	user.SendMessage("Bob", bytes.Repeat([]byte("large message "), 8))
+	var sent []*MIMCPacket
+	for _, value := range user.messageToAck.KVs() {
+		sent = append(sent, value.(*packet.MIMCTimeoutPacket).Packet())
+	}
+	for _, mimcPacket := range sent {
+		user.messageToAck.Push(mimcPacket.GetPacketId(), packet.NewTimeoutPacket(CurrentTimeMillis()-cnst.CHECK_TIMEOUT_TIMEVAL_MS, mimcPacket))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		user.scanAndCallback()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("scanAndCallback deadlocked")
+	}
+	if delegate.p2p != 1 || delegate.fragmentFailures != 1 {
+		t.Errorf("expect one timeout and one fragment failure, got %v, %v", delegate.p2p, delegate.fragmentFailures)
+	}
+	if user.messageToAck.Size() != 2 {
+		t.Errorf("only the resent messages should remain, size: %v", user.messageToAck.Size())
+	}
+}
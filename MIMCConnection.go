@@ -5,8 +5,13 @@ import (
 	"github.com/Xiaomi-mimc/mimc-go-sdk/cipher"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
 	. "github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/protocol"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/util/string"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type ConnStatus int
@@ -18,6 +23,8 @@ const (
 )
 
 type MIMCConnection struct {
+	// 连接状态会被发送、接收、回调和定时goroutine同时读写，以下字段由connLock保护
+	connLock    sync.RWMutex
 	tcpConn     net.Conn
 	peer        *Peer
 	peerFetcher IFrontendPeerFetcher
@@ -41,38 +48,62 @@ type MIMCConnection struct {
 	lastPingTimestamp      uint64
 	tryCreateConnCount     uint8
 	defaults               map[string]string
+
+	// 以下为服务端下发的配置，连接重置后仍然保留
+	configLock   sync.Mutex
+	psc          *protocol.PushServiceConfigMsg
+	redirectPeer *Peer
+	redirectHost string
+	// 本次登录中已经重定向的次数
+	redirects int
 }
 
 func (this *MIMCConnection) Rc4Key() []byte {
+	this.connLock.RLock()
+	defer this.connLock.RUnlock()
 	return this.rc4Key
 }
 func (this *MIMCConnection) Status() ConnStatus {
+	this.connLock.RLock()
+	defer this.connLock.RUnlock()
 	return this.status
 }
 func (this *MIMCConnection) Sock_Connected() {
+	this.connLock.Lock()
+	defer this.connLock.Unlock()
 	this.status = SOCK_CONNECTED
 }
 func (this *MIMCConnection) HandshakeConnected() *MIMCConnection {
+	this.connLock.Lock()
+	defer this.connLock.Unlock()
 	this.status = HANDSHAKE_CONNECTED
 	return this
 }
 
 func (this *MIMCConnection) ClearSockTimestamp() *MIMCConnection {
+	this.connLock.Lock()
+	defer this.connLock.Unlock()
 	this.nextResetSockTimestamp = -1
 	return this
 }
 
 func (this *MIMCConnection) TrySetNextResetSockTs() {
+	this.connLock.Lock()
+	defer this.connLock.Unlock()
 	if this.nextResetSockTimestamp > 0 {
 		return
 	}
 	this.nextResetSockTimestamp = CurrentTimeMillis() + cnst.RESET_SOCKET_TIMEOUT_TIMEVAL_MS
 }
 func (this *MIMCConnection) NextResetSockTimestamp() int64 {
+	this.connLock.RLock()
+	defer this.connLock.RUnlock()
 	return this.nextResetSockTimestamp
 }
 
 func (this *MIMCConnection) PeerFetcher(peerFetcher IFrontendPeerFetcher) *MIMCConnection {
+	this.connLock.Lock()
+	defer this.connLock.Unlock()
 	this.peerFetcher = peerFetcher
 	return this
 }
@@ -82,60 +113,207 @@ func (this *MIMCConnection) User(user *MCUser) *MIMCConnection {
 	return this
 }
 
+func (this *MIMCConnection) logger() log.Logger {
+	if this.user == nil {
+		return log.GetLogger()
+	}
+	return this.user.Logger()
+}
+
 func (this *MIMCConnection) Challenge() string {
+	this.connLock.RLock()
+	defer this.connLock.RUnlock()
 	return this.challenge
 }
 func (this *MIMCConnection) SetChallenge(challenge string) {
+	this.connLock.Lock()
+	defer this.connLock.Unlock()
 	this.challenge = challenge
 }
 func (this *MIMCConnection) Udid() string {
+	this.connLock.RLock()
+	defer this.connLock.RUnlock()
 	return this.udid
 }
 
 func (this *MIMCConnection) Reset() {
+	this.reset(true)
+}
+
+// notify为false时不通知statusDelegate，用于服务端重定向这类预期内的重连
+// 多个goroutine同时发现连接异常时只有第一个会重置并通知
+func (this *MIMCConnection) reset(notify bool) {
+	this.connLock.Lock()
 	if this.status == NOT_CONNECTED {
+		this.connLock.Unlock()
 		return
 	}
+	// 先标记用户离线再把连接置为未连接，避免重连后在BIND之前发送消息
 	if this.user != nil {
-		this.user.status = Offline
+		this.user.setStatus(Offline)
 	}
+	tcpConn := this.tcpConn
+	this.init()
+	this.connLock.Unlock()
 
-	if this.tcpConn != nil {
-		this.tcpConn.Close()
+	if tcpConn != nil {
+		tcpConn.Close()
 	}
-	logger.Info("reset conn.")
-	this.user.lastCreateConnTimestamp = 0
+	this.logger().Info("reset conn.")
+	this.user.metrics.IncReconnect(this.user.appAccount)
+	this.user.heartbeat.reset()
+	atomic.StoreInt64(&this.user.lastCreateConnTimestamp, 0)
 	network_error := "NETWORK_ERROR"
-	this.user.status = Offline
-	this.user.statusDelegate.HandleChange(false, &network_error, &network_error, &network_error)
+	if notify && this.user.statusDelegate != nil {
+		this.user.statusDelegate.HandleChange(false, &network_error, &network_error, &network_error)
+	}
+}
+
+// 关闭连接，不通知statusDelegate，用于MCUser.Close
+func (this *MIMCConnection) Close() {
+	this.connLock.Lock()
+	tcpConn := this.tcpConn
+	this.tcpConn = nil
 	this.init()
+	this.connLock.Unlock()
+	if tcpConn != nil {
+		tcpConn.Close()
+	}
 }
 
 func (this *MIMCConnection) Connect() bool {
-	if this.peerFetcher == nil {
-		logger.Warn("peerFetcher is nil.")
+	this.connLock.RLock()
+	peerFetcher := this.peerFetcher
+	this.connLock.RUnlock()
+	if peerFetcher == nil {
+		this.logger().Warn("peerFetcher is nil.")
 		return false
 	}
-	this.peer = this.peerFetcher.FetchPeer()
-	conn, err := net.Dial("tcp", this.peer.ToString())
+	this.configLock.Lock()
+	redirectPeer := this.redirectPeer
+	this.configLock.Unlock()
+	var peer *Peer
+	if redirectPeer != nil {
+		this.logger().Info("connect to redirected peer: %v.", redirectPeer.ToString())
+		peer = redirectPeer
+	} else {
+		peer = peerFetcher.FetchPeer()
+	}
+	// 限制连接时间，避免Close之后发送goroutine长时间阻塞在连接上
+	conn, err := net.DialTimeout("tcp", peer.ToString(), time.Duration(cnst.CONNECT_TIMEOUT)*time.Millisecond)
+	this.connLock.Lock()
+	this.peer = peer
 	if err == nil {
 		this.tcpConn = conn
-		return true
 	}
-	return false
+	this.connLock.Unlock()
+	if err != nil && redirectPeer != nil {
+		// 重定向的地址不可用，下次回退到peerFetcher
+		this.logger().Warn("connect to redirected peer fail: %v.", err)
+	}
+	// 重定向只用于紧接着的一次连接，之后的重连重新从peerFetcher获取地址
+	this.configLock.Lock()
+	this.redirectPeer = nil
+	if redirectPeer == nil {
+		this.redirectHost = ""
+	}
+	this.configLock.Unlock()
+	return err == nil
+}
+
+/**
+ * 应用CONN响应中服务端下发的配置。
+ * 若服务端要求连接到其他host，记录重定向地址并返回true，调用方需要重置连接。
+ * 一次登录中最多重定向cnst.MAX_REDIRECTS次，超过后忽略重定向，留在当前连接上。
+ */
+func (this *MIMCConnection) ApplyConnResp(host string, psc *protocol.PushServiceConfigMsg) bool {
+	peer := this.Peer()
+	this.configLock.Lock()
+	defer this.configLock.Unlock()
+	this.psc = mergePsc(this.psc, psc)
+	if len(host) == 0 || peer == nil || host == peer.Host() || host == peer.ToString() {
+		return false
+	}
+	redirectPeer := parseRedirectPeer(host, peer)
+	if redirectPeer == nil {
+		this.logger().Warn("ignore invalid redirect host: %v.", host)
+		return false
+	}
+	if this.redirects >= cnst.MAX_REDIRECTS {
+		this.logger().Warn("ignore redirect to %v, already redirected %v times.", host, this.redirects)
+		return false
+	}
+	this.redirects++
+	this.redirectHost = host
+	this.redirectPeer = redirectPeer
+	return true
+}
+
+// 登录成功后重新计算重定向次数
+func (this *MIMCConnection) ResetRedirects() {
+	this.configLock.Lock()
+	defer this.configLock.Unlock()
+	this.redirects = 0
+}
+
+// 登出时丢弃未使用的重定向地址
+func (this *MIMCConnection) ClearRedirect() {
+	this.configLock.Lock()
+	defer this.configLock.Unlock()
+	this.redirects = 0
+	this.redirectHost = ""
+	this.redirectPeer = nil
+}
+
+// 连接时回报给服务端的配置版本，没有收到过配置时返回nil
+func (this *MIMCConnection) PscToReport() *protocol.PushServiceConfigMsg {
+	this.configLock.Lock()
+	defer this.configLock.Unlock()
+	if this.psc == nil || this.psc.CloudVersion == nil {
+		return nil
+	}
+	clientVersion := *(this.psc.CloudVersion)
+	return &protocol.PushServiceConfigMsg{ClientVersion: &clientVersion}
+}
+
+func (this *MIMCConnection) Config() ConnConfig {
+	peer := this.Peer()
+	this.configLock.Lock()
+	defer this.configLock.Unlock()
+	return newConnConfig(peer, this.redirectHost, this.psc)
+}
+
+func (this *MIMCConnection) Peer() *Peer {
+	this.connLock.RLock()
+	defer this.connLock.RUnlock()
+	return this.peer
+}
+
+func (this *MIMCConnection) Connpt() string {
+	this.connLock.RLock()
+	defer this.connLock.RUnlock()
+	return this.connpt
+}
+
+// 重置连接会关闭旧的tcpConn，读写时使用取出的快照，出错后由调用方重置
+func (this *MIMCConnection) currentConn() net.Conn {
+	this.connLock.RLock()
+	defer this.connLock.RUnlock()
+	return this.tcpConn
 }
 
 func (this *MIMCConnection) Readn(buf *[]byte, length int) int {
-	if !this.check(buf, length) {
-		logger.Warn("check: buf len %v != length %v", len(*buf), length)
+	tcpConn := this.currentConn()
+	if !this.check(tcpConn, buf, length) {
+		this.logger().Warn("check: buf len %v != length %v", len(*buf), length)
 		return -1
 	}
 	left := length
 	for left > 0 {
 		tmpBuf := make([]byte, left)
-		nread, err := this.tcpConn.Read(tmpBuf)
+		nread, err := tcpConn.Read(tmpBuf)
 		if err != nil || nread < 0 {
-			logger.Error("read error. err: %v, nread: %v, length: %v", err, nread, length)
+			this.logger().Error("read error. err: %v, nread: %v, length: %v", err, nread, length)
 			return -1
 		}
 		if nread == 0 {
@@ -146,14 +324,15 @@ func (this *MIMCConnection) Readn(buf *[]byte, length int) int {
 		}
 		left = left - nread
 		if left < 0 {
-			logger.Debug("nread: %v, left: %v, length: %v, lenbuf: %v", nread, left, length, len(*buf))
+			this.logger().Debug("nread: %v, left: %v, length: %v, lenbuf: %v", nread, left, length, len(*buf))
 			return length
 		}
 	}
 	return length - left
 }
 func (this *MIMCConnection) Writen(buf *[]byte, length int) int {
-	if !this.check(buf, length) {
+	tcpConn := this.currentConn()
+	if !this.check(tcpConn, buf, length) {
 		return -1
 	}
 	left := length
@@ -163,9 +342,9 @@ func (this *MIMCConnection) Writen(buf *[]byte, length int) int {
 		for i := 0; i < left; i++ {
 			tmpBuf[i] = (*buf)[length-left+i]
 		}
-		nwrite, err := this.tcpConn.Write(tmpBuf)
+		nwrite, err := tcpConn.Write(tmpBuf)
 		if err != nil || nwrite < 0 {
-			logger.Error("write error.")
+			this.logger().Error("write error.")
 			return -1
 		}
 		if nwrite == 0 {
@@ -178,15 +357,17 @@ func (this *MIMCConnection) Writen(buf *[]byte, length int) int {
 
 }
 
-func (this *MIMCConnection) check(buf *[]byte, length int) bool {
-	if this.tcpConn == nil || buf == nil || len(*buf) < length {
-		logger.Debug("tcpConn: %v, buf:%v", this.tcpConn, buf)
+func (this *MIMCConnection) check(tcpConn net.Conn, buf *[]byte, length int) bool {
+	if tcpConn == nil || buf == nil || len(*buf) < length {
+		this.logger().Debug("tcpConn: %v, buf:%v", tcpConn, buf)
 		return false
 	}
 	return true
 }
 
 func (this *MIMCConnection) SetChallengeAndRc4Key(challenge string) {
+	this.connLock.Lock()
+	defer this.connLock.Unlock()
 	this.challenge = challenge
 	halfUdid := strutil.Substring(&this.udid, len(this.udid)/2)
 	halfChallenge := strutil.Substring(&this.challenge, len(this.challenge)/2)
@@ -200,6 +381,7 @@ func NewConn() *MIMCConnection {
 	return conn
 }
 
+// 调用方需要持有connLock，NewConn中还没有其他goroutine访问
 func (this *MIMCConnection) init() {
 	this.status = NOT_CONNECTED
 	this.rc4Key = nil
@@ -213,6 +395,9 @@ func (this *MIMCConnection) init() {
 	this.lastPingTimestamp = 0
 	this.nextResetSockTimestamp = -1
 	this.tryCreateConnCount = 0
-	this.peerFetcher = NewPeerFetcher()
+	// 重置连接时保留应用指定的peerFetcher
+	if this.peerFetcher == nil {
+		this.peerFetcher = NewPeerFetcher()
+	}
 
 }
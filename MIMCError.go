@@ -0,0 +1,104 @@
+package mimc
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// 已知的服务端错误，可以通过errors.Is判断ServerError的类型
+var (
+	ErrTokenExpired = errors.New("mimc: token expired")
+	ErrInvalidToken = errors.New("mimc: invalid token")
+	ErrInvalidSig   = errors.New("mimc: invalid signature")
+	ErrPacketReject = errors.New("mimc: packet rejected by server")
+	ErrNotEncrypted = errors.New("mimc: message is not end-to-end encrypted")
+
+	ErrE2EDisabled      = errors.New("mimc: end-to-end encryption is not enabled")
+	ErrNotTopicMember   = errors.New("mimc: sender is not a topic member")
+	ErrSenderKeyTimeout = errors.New("mimc: sender key distribution timeout")
+
+	ErrReceiptsDisabled = errors.New("mimc: receipts are not enabled")
+	ErrReceiptSendFail  = errors.New("mimc: send receipt fail")
+)
+
+var errorLock sync.RWMutex
+var errorCodes = map[int32]error{}
+var errorStrs = map[string]error{
+	"token-expired": ErrTokenExpired,
+	"invalid-token": ErrInvalidToken,
+	"invalid-sig":   ErrInvalidSig,
+}
+
+// 注册服务端错误码与错误类型的对应关系，ServerError会据此匹配Err
+func RegisterErrorCode(code int32, err error) {
+	errorLock.Lock()
+	defer errorLock.Unlock()
+	errorCodes[code] = err
+}
+
+/**
+ * 服务端返回的错误，来自NOTIFY消息、ClientHeader中的err_code/err_str
+ * 或者PacketAck中的errorMsg。Err为匹配到的已知错误，未知错误时为nil。
+ */
+type ServerError struct {
+	Cmd      string
+	Subcmd   string
+	PacketId string
+	Code     int32
+	Reason   string
+	Err      error
+}
+
+func newServerError(cmd, subcmd, packetId string, code int32, reason string) *ServerError {
+	serverError := &ServerError{Cmd: cmd, Subcmd: subcmd, PacketId: packetId, Code: code, Reason: reason}
+	errorLock.RLock()
+	defer errorLock.RUnlock()
+	if err, ok := errorCodes[code]; ok && code != 0 {
+		serverError.Err = err
+	} else if err, ok := errorStrs[reason]; ok {
+		serverError.Err = err
+	}
+	return serverError
+}
+
+func (this *ServerError) Error() string {
+	str := "mimc: server error, cmd: " + this.Cmd
+	if len(this.Subcmd) > 0 {
+		str += ", subcmd: " + this.Subcmd
+	}
+	if len(this.PacketId) > 0 {
+		str += ", packetId: " + this.PacketId
+	}
+	return str + ", code: " + strconv.Itoa(int(this.Code)) + ", reason: " + this.Reason
+}
+
+func (this *ServerError) Unwrap() error {
+	return this.Err
+}
+
+/**
+ * 端到端加密错误。发送时Account为接收方，接收时Account为发送方、PacketId为被丢弃的消息，
+ * 群聊消息的TopicId不为0。Err通常为e2e.ErrNoPublicKey、e2e.ErrNoSenderKey、e2e.ErrDecrypt或ErrNotEncrypted。
+ */
+type E2EError struct {
+	Account  string
+	TopicId  int64
+	PacketId string
+	Err      error
+}
+
+func (this *E2EError) Error() string {
+	str := "mimc: e2e error, account: " + this.Account
+	if this.TopicId != 0 {
+		str += ", topicId: " + strconv.FormatInt(this.TopicId, 10)
+	}
+	if len(this.PacketId) > 0 {
+		str += ", packetId: " + this.PacketId
+	}
+	return str + ": " + this.Err.Error()
+}
+
+func (this *E2EError) Unwrap() error {
+	return this.Err
+}
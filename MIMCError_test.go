@@ -0,0 +1,95 @@
+package mimc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestServerErrorMapping(t *testing.T) {
+	if err := newServerError("NOTIFY", "", "", 0, "token-expired"); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("token-expired should map to ErrTokenExpired: %v", err)
+	}
+	custom := errors.New("quota exceeded")
+	RegisterErrorCode(429, custom)
+	err := newServerError("SECMSG", "", "abc_1", 429, "too many requests")
+	if !errors.Is(err, custom) {
+		t.Errorf("registered code should map to custom error: %v", err)
+	}
+	if err := newServerError("NOTIFY", "", "", 1, "unknown"); err.Err != nil {
+		t.Errorf("unknown error should not be mapped: %v", err.Err)
+	}
+}
+
+type errorCounter struct {
+	nopDelegate
+	errs []error
+}
+
+func (this *errorCounter) HandleError(err error) {
+	this.errs = append(this.errs, err)
+}
+
+func TestHeaderErrorFailsPacketOnce(t *testing.T) {
+	log.SetLogger(log.NewNopLogger())
+	user := NewUser("Alice")
+	user.Replay(bytes.NewReader(nil), ReplayOptions{})
+	counter := new(errorCounter)
+	user.RegisterMessageDelegate(counter).RegisterErrorDelegate(counter)
+	sent := &MIMCPacket{PacketId: proto.String("p1"), Type: MIMC_MSG_TYPE_P2P_MESSAGE.Enum()}
+	user.messageToAck.Push("p1", packet.NewTimeoutPacket(CurrentTimeMillis()-cnst.CHECK_TIMEOUT_TIMEVAL_MS, sent))
+
+	header := &ClientHeader{Cmd: proto.String(cnst.CMD_SECMSG), Id: proto.String("p1"), ErrCode: proto.Int32(429), ErrStr: proto.String("too many requests")}
+	user.handleResponse(packet.NewV6Packet().Header(header))
+	if len(counter.errs) != 1 || user.messageToAck.Size() != 0 {
+		t.Fatalf("rejected packet should be reported once and removed, errors: %v, pending: %v", counter.errs, user.messageToAck.Size())
+	}
+	user.scanAndCallback()
+	if len(counter.errs) != 1 {
+		t.Errorf("rejected packet should not time out, errors: %v", counter.errs)
+	}
+
+	connResp, _ := proto.Marshal(&XMMsgConnResp{Challenge: proto.String("challenge")})
+	header = &ClientHeader{Cmd: proto.String(cnst.CMD_CONN), Id: proto.String("c1"), ErrStr: proto.String("warning")}
+	user.handleResponse(packet.NewV6Packet().Header(header).Payload(connResp))
+	if len(counter.errs) != 2 || user.conn.Status() != HANDSHAKE_CONNECTED {
+		t.Errorf("conn response with error should still complete handshake, errors: %v, status: %v", counter.errs, user.conn.Status())
+	}
+}
+
+type ackCounter struct {
+	errorCounter
+	acks int
+}
+
+func (this *ackCounter) HandleServerAck(packetId *string, sequence, timestamp *int64) {
+	this.acks++
+}
+
+// 带errorMsg的ack按发送失败处理，不回调HandleServerAck
+func TestRejectedAckIsNotAcked(t *testing.T) {
+	log.SetLogger(log.NewNopLogger())
+	user := NewUser("Alice")
+	user.Replay(bytes.NewReader(nil), ReplayOptions{})
+	counter := new(ackCounter)
+	user.RegisterMessageDelegate(counter).RegisterErrorDelegate(counter)
+	sent := &MIMCPacket{PacketId: proto.String("p1"), Type: MIMC_MSG_TYPE_P2P_MESSAGE.Enum()}
+	user.messageToAck.Push("p1", packet.NewTimeoutPacket(CurrentTimeMillis(), sent))
+
+	ack, _ := proto.Marshal(&MIMCPacketAck{PacketId: proto.String("p1"), ErrorMsg: proto.String("rejected")})
+	ackPacket, _ := proto.Marshal(&MIMCPacket{PacketId: proto.String("a1"), Type: MIMC_MSG_TYPE_PACKET_ACK.Enum(), Payload: ack})
+	user.handleResponse(packet.NewV6Packet().Header(&ClientHeader{Cmd: proto.String(cnst.CMD_SECMSG), Id: proto.String("h1")}).Payload(ackPacket))
+	if counter.acks != 0 || len(counter.errs) != 1 || !errors.Is(counter.errs[0], ErrPacketReject) {
+		t.Errorf("rejected packet should be reported as an error only, acks: %v, errors: %v", counter.acks, counter.errs)
+	}
+	if user.messageToAck.Size() != 0 {
+		t.Errorf("rejected packet should be removed, pending: %v", user.messageToAck.Size())
+	}
+}
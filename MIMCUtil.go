@@ -4,12 +4,12 @@ import (
 	"bytes"
 	"container/list"
 	"encoding/base64"
-	"fmt"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
 	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
 	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/util/id"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/util/string"
 	"github.com/golang/protobuf/proto"
 	"sort"
@@ -30,7 +30,7 @@ func Sleep(millis int64) {
 func Deserialize(data []byte, pb proto.Message) bool {
 	err := proto.Unmarshal(data, pb)
 	if err != nil {
-		fmt.Printf("deserialize error: %v\n", err)
+		log.GetLogger().Warn("deserialize error: %v", err)
 		return false
 	}
 	return true
@@ -102,6 +102,16 @@ func BuildConnectionPacket(udid string, mcUser *MCUser) *packet.MIMCV6Packet {
 	xMMsgConn.Sdk = &sdk
 	version := cnst.CONN_BIN_PROTO_VERSION
 	xMMsgConn.Version = &version
+	if conn := mcUser.Conn(); conn != nil {
+		if peer := conn.Peer(); peer != nil {
+			host := peer.Host()
+			xMMsgConn.Host = &host
+		}
+		if connpt := conn.Connpt(); len(connpt) > 0 {
+			xMMsgConn.Connpt = &connpt
+		}
+		xMMsgConn.Psc = conn.PscToReport()
+	}
 	v6Packet.PayloadType(cnst.PAYLOAD_TYPE)
 	v6Packet.ClientHeader(clientHeader)
 	payload, _ := proto.Marshal(xMMsgConn)
@@ -158,7 +168,7 @@ func BuildP2TMessagePacket(mcUser *MCUser, appTopic int64, msg []byte, isStore b
 	mimcPacket.Type = &msgType
 	payload, err := proto.Marshal(p2tMsg)
 	if err != nil {
-		fmt.Printf("serialize P2P msg fail.\n")
+		mcUser.Logger().Error("serialize P2T msg fail: %v", err)
 	}
 	mimcPacket.Payload = payload
 
@@ -167,7 +177,7 @@ func BuildP2TMessagePacket(mcUser *MCUser, appTopic int64, msg []byte, isStore b
 	v6Packet.ClientHeader(clientHeader)
 	payload, err = proto.Marshal(mimcPacket)
 	if err != nil {
-		fmt.Printf("serialize MIMCPackdet fail.\n")
+		mcUser.Logger().Error("serialize MIMCPacket fail: %v", err)
 	}
 	v6Packet.Payload(payload)
 	return v6Packet, mimcPacket
@@ -192,7 +202,7 @@ func BuildP2PMessagePacket(mcUser *MCUser, appAccount string, msg []byte, isStor
 	mimcPacket.Type = &msgType
 	payload, err := proto.Marshal(p2pMsg)
 	if err != nil {
-		fmt.Printf("serialize P2P msg fail.\n")
+		mcUser.Logger().Error("serialize P2P msg fail: %v", err)
 	}
 	mimcPacket.Payload = payload
 
@@ -201,7 +211,7 @@ func BuildP2PMessagePacket(mcUser *MCUser, appAccount string, msg []byte, isStor
 	v6Packet.ClientHeader(clientHeader)
 	payload, err = proto.Marshal(mimcPacket)
 	if err != nil {
-		fmt.Printf("serialize MIMCPackdet fail.\n")
+		mcUser.Logger().Error("serialize MIMCPacket fail: %v", err)
 	}
 	v6Packet.Payload(payload)
 	return v6Packet, mimcPacket
@@ -228,7 +238,13 @@ func buildMIMCGroup(appId, topicId int64) *MIMCGroup {
 }
 
 func BuildPingPacket(mcUser *MCUser) *packet.MIMCV6Packet {
+	clientHeader := createClientHeader(mcUser, cnst.CMD_PING, id.Generate(), cnst.CIPHER_NONE)
+	xmMsgPing := new(XMMsgPing)
 	v6Packet := packet.NewV6Packet()
+	v6Packet.PayloadType(cnst.PAYLOAD_TYPE)
+	v6Packet.ClientHeader(clientHeader)
+	payload, _ := proto.Marshal(xmMsgPing)
+	v6Packet.Payload(payload)
 	return v6Packet
 }
 
@@ -0,0 +1,135 @@
+package mimc
+
+import (
+	"container/list"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+)
+
+/**
+ * 设置本地消息存储，SDK会记录发送和收到的单聊、群聊消息以及发送状态。
+ * 不存储的消息(SendTransientMessage)和SDK内部的控制消息不会被记录。
+ */
+func (this *MCUser) SetMessageStore(messageStore store.MessageStore) *MCUser {
+	this.messageStore = messageStore
+	return this
+}
+
+func (this *MCUser) MessageStore() store.MessageStore {
+	return this.messageStore
+}
+
+// 查询本地存储的历史消息，没有设置消息存储时返回空结果
+func (this *MCUser) QueryMessages(query store.Query) ([]*store.Message, error) {
+	if this.messageStore == nil {
+		return []*store.Message{}, nil
+	}
+	return this.messageStore.Query(query)
+}
+
+// 记录发送的消息到消息存储和会话列表
+func (this *MCUser) recordSent(packetId string, msgType string, toAppAccount string, topicId int64, payload []byte) {
+	if this.messageStore == nil && this.conversations == nil || len(packetId) == 0 {
+		return
+	}
+	message := &store.Message{
+		PacketId:    packetId,
+		Type:        msgType,
+		FromAccount: this.appAccount,
+		ToAccount:   toAppAccount,
+		TopicId:     topicId,
+		Timestamp:   CurrentTimeMillis(),
+		Payload:     payload,
+		Outgoing:    true,
+		Status:      store.STATUS_SENDING,
+	}
+	if msgType == store.TYPE_P2P {
+		message.Conversation = store.P2PConversation(toAppAccount)
+	} else {
+		message.Conversation = store.P2TConversation(topicId)
+	}
+	this.record(message)
+}
+
+func (this *MCUser) record(message *store.Message) {
+	if this.messageStore != nil {
+		if err := this.messageStore.Save(message); err != nil {
+			this.Logger().Warn("[store] save message %v fail: %v", message.PacketId, err)
+		}
+	}
+	if this.conversations != nil {
+		this.notifyConversation(this.conversations.OnMessage(message))
+	}
+}
+
+// 内部消息、分片和不存储的消息没有记录，更新时忽略ErrNotFound
+func (this *MCUser) recordStatus(packetId string, status store.Status, sequence, timestamp int64) {
+	if this.messageStore != nil {
+		if err := this.messageStore.UpdateStatus(packetId, status, sequence, timestamp); err != nil && err != store.ErrNotFound {
+			this.Logger().Warn("[store] update message %v status fail: %v", packetId, err)
+		}
+	}
+	if this.conversations != nil {
+		this.notifyConversation(this.conversations.OnStatus(packetId, status, sequence, timestamp))
+	}
+}
+
+// 记录交给应用的消息列表，元素为*msg.P2PMessage或*msg.P2TMessage
+func (this *MCUser) recordReceived(msgList *list.List) {
+	if this.messageStore == nil && this.conversations == nil {
+		return
+	}
+	for ele := msgList.Front(); ele != nil; ele = ele.Next() {
+		var message *store.Message
+		switch received := ele.Value.(type) {
+		case *msg.P2PMessage:
+			if received.IsTransient() {
+				continue
+			}
+			message = &store.Message{
+				PacketId:     stringValue(received.PacketId()),
+				Conversation: store.P2PConversation(stringValue(received.FromAccount())),
+				Type:         store.TYPE_P2P,
+				FromAccount:  stringValue(received.FromAccount()),
+				ToAccount:    stringValue(received.ToAccount()),
+				Sequence:     int64Value(received.Sequence()),
+				Timestamp:    int64Value(received.Timestamp()),
+				Payload:      received.Payload(),
+				Status:       store.STATUS_RECEIVED,
+			}
+		case *msg.P2TMessage:
+			if received.IsTransient() {
+				continue
+			}
+			message = &store.Message{
+				PacketId:     stringValue(received.PacketId()),
+				Conversation: store.P2TConversation(int64Value(received.GroupId())),
+				Type:         store.TYPE_P2T,
+				FromAccount:  stringValue(received.FromAccount()),
+				TopicId:      int64Value(received.GroupId()),
+				Sequence:     int64Value(received.Sequence()),
+				Timestamp:    int64Value(received.Timestamp()),
+				Payload:      received.Payload(),
+				Status:       store.STATUS_RECEIVED,
+			}
+		default:
+			continue
+		}
+		this.record(message)
+	}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func int64Value(value *int64) int64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
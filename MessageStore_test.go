@@ -0,0 +1,76 @@
+package mimc
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/map"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/queue"
+)
+
+func TestMessageStoreHooks(t *testing.T) {
+	user := NewUser("Alice").SetMessageStore(store.NewMemoryStore())
+	user.messageToSend = que.NewConQueue()
+	user.messageToAck = cmap.NewConMap()
+	user.transientPackets = cmap.NewConMap()
+	packetId := user.SendMessage("Bob", []byte("hello"))
+	user.SendTransientMessage("Bob", []byte("typing"))
+	user.recordStatus(packetId, store.STATUS_ACKED, 7, CurrentTimeMillis())
+
+	packetIdReceived, from, to, sequence, timestamp := "p_received", "Bob", "Alice", int64(8), CurrentTimeMillis()+1
+	received := list.New()
+	received.PushBack(msg.NewP2pMsg(&packetIdReceived, &from, &to, &sequence, &timestamp, []byte("hi")))
+	user.recordReceived(received)
+
+	messages, err := user.QueryMessages(store.Query{Conversation: store.P2PConversation("Bob")})
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("expect 2 stored messages, got %v, %v", len(messages), err)
+	}
+	if messages[0].PacketId != packetId || messages[0].Status != store.STATUS_ACKED || messages[0].Sequence != 7 || string(messages[0].Payload) != "hello" {
+		t.Errorf("sent message mismatch: %+v", messages[0])
+	}
+	if messages[1].PacketId != packetIdReceived || messages[1].Status != store.STATUS_RECEIVED || messages[1].Outgoing {
+		t.Errorf("received message mismatch: %+v", messages[1])
+	}
+}
+
+// 在Save时检查发送队列，消息必须先存储再入队
+type orderCheckingStore struct {
+	store.MessageStore
+	user   *MCUser
+	queued []uint32
+}
+
+func (this *orderCheckingStore) Save(message *store.Message) error {
+	this.queued = append(this.queued, this.user.messageToSend.Size())
+	return this.MessageStore.Save(message)
+}
+
+func TestStoreBeforeSend(t *testing.T) {
+	user := NewUser("Alice").EnableFragmentation(FragmentConfig{FragmentSize: 4})
+	checking := &orderCheckingStore{MessageStore: store.NewMemoryStore(), user: user}
+	user.SetMessageStore(checking)
+	user.messageToSend = que.NewConQueue()
+	user.messageToAck = cmap.NewConMap()
+	topicId := int64(1)
+	sends := []func(){
+		func() { user.SendMessage("Bob", []byte("hi")) },
+		func() { user.SendMessage("Bob", []byte("hello world")) },
+		func() { user.SendGroupMessage(&topicId, []byte("hi")) },
+	}
+	var before []uint32
+	for _, send := range sends {
+		before = append(before, user.messageToSend.Size())
+		send()
+	}
+	if len(checking.queued) != len(before) {
+		t.Fatalf("expect %v saved messages, got %v", len(before), len(checking.queued))
+	}
+	for i := range before {
+		if checking.queued[i] != before[i] {
+			t.Errorf("message %v should be stored before it is queued, queue size at save: %v, before send: %v", i, checking.queued[i], before[i])
+		}
+	}
+}
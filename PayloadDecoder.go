@@ -0,0 +1,156 @@
+package mimc
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/compress"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/e2e"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/fragment"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/receipt"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+type PayloadKind int
+
+const (
+	// 需要交给应用的消息
+	PAYLOAD_MESSAGE PayloadKind = iota
+	// 分片还没有收齐
+	PAYLOAD_INCOMPLETE
+	// 送达、已读回执
+	PAYLOAD_RECEIPT
+	// 群聊sender key分发消息
+	PAYLOAD_SENDER_KEY
+	// 解密或验证失败，Err为原因
+	PAYLOAD_DROPPED
+)
+
+/**
+ * 解码后的payload。PacketId在分片消息收齐后为消息级别的id，否则为包的packetId。
+ * Kind为PAYLOAD_DROPPED时Payload为出错前的数据，例如等待sender key的群聊密文。
+ */
+type DecodedPayload struct {
+	Kind      PayloadKind
+	PacketId  string
+	Payload   []byte
+	Encrypted bool
+	Err       error
+}
+
+/**
+ * 接收端payload的解码流程，MCUser的接收路径和callback包共用。
+ * 按与发送时相反的顺序处理：分片重组、端到端解密、解压，最后识别回执和sender key分发等SDK内部消息。
+ * Reassembler为nil时不重组，分片原样作为消息交出。
+ * Decrypt、DecryptGroup对每条消息都会调用，未加密的消息需要原样返回；为nil时加密的消息被丢弃，Err为ErrE2EDisabled。
+ * HandleFailure在分片重组失败时回调，Logger为nil时使用全局Logger。
+ */
+type PayloadDecoder struct {
+	Reassembler   *fragment.Reassembler
+	Decrypt       func(fromAccount string, payload []byte) ([]byte, error)
+	DecryptGroup  func(topicId int64, fromAccount string, payload []byte) ([]byte, error)
+	HandleFailure func(failure *fragment.Failure)
+	Logger        log.Logger
+}
+
+// 解码单聊消息的payload
+func (this *PayloadDecoder) DecodeP2P(fromAccount string, packetId string, payload []byte) DecodedPayload {
+	decoded := this.reassemble(p2pFragmentKey(fromAccount), packetId, payload)
+	if decoded.Kind != PAYLOAD_MESSAGE {
+		return decoded
+	}
+	decoded.Encrypted = e2e.IsEncrypted(decoded.Payload)
+	if this.Decrypt == nil {
+		if decoded.Encrypted {
+			return this.drop(decoded, ErrE2EDisabled)
+		}
+	} else if data, err := this.Decrypt(fromAccount, decoded.Payload); err != nil {
+		return this.drop(decoded, err)
+	} else {
+		decoded.Payload = data
+	}
+	decoded.Payload = this.decompress(decoded.Payload)
+	if receipt.IsReceipt(decoded.Payload) {
+		decoded.Kind = PAYLOAD_RECEIPT
+	} else if e2e.IsDistribution(decoded.Payload) {
+		decoded.Kind = PAYLOAD_SENDER_KEY
+	}
+	return decoded
+}
+
+// 解码群聊消息的payload，群聊中不会有回执和sender key分发，Kind不会是PAYLOAD_RECEIPT、PAYLOAD_SENDER_KEY
+func (this *PayloadDecoder) DecodeP2T(topicId int64, fromAccount string, packetId string, payload []byte) DecodedPayload {
+	decoded := this.reassemble(p2tFragmentKey(topicId, fromAccount), packetId, payload)
+	if decoded.Kind != PAYLOAD_MESSAGE {
+		return decoded
+	}
+	return this.openGroup(topicId, fromAccount, decoded)
+}
+
+// 解密并解压已经重组的群聊payload，等待sender key的消息收到分发后从这里继续
+func (this *PayloadDecoder) openGroup(topicId int64, fromAccount string, decoded DecodedPayload) DecodedPayload {
+	decoded.Encrypted = e2e.IsGroupEncrypted(decoded.Payload)
+	if this.DecryptGroup == nil {
+		if decoded.Encrypted {
+			return this.drop(decoded, ErrE2EDisabled)
+		}
+	} else if data, err := this.DecryptGroup(topicId, fromAccount, decoded.Payload); err != nil {
+		return this.drop(decoded, err)
+	} else {
+		decoded.Payload = data
+	}
+	decoded.Payload = this.decompress(decoded.Payload)
+	return decoded
+}
+
+func (this *PayloadDecoder) reassemble(key string, packetId string, payload []byte) DecodedPayload {
+	decoded := DecodedPayload{Kind: PAYLOAD_MESSAGE, PacketId: packetId, Payload: payload}
+	if this.Reassembler == nil {
+		return decoded
+	}
+	fragmentPacket := fragment.Decode(payload)
+	if fragmentPacket == nil {
+		return decoded
+	}
+	data, failure := this.Reassembler.Add(key, fragmentPacket, CurrentTimeMillis())
+	if failure != nil && this.HandleFailure != nil {
+		this.HandleFailure(failure)
+	}
+	if data == nil {
+		return DecodedPayload{Kind: PAYLOAD_INCOMPLETE, PacketId: packetId}
+	}
+	decoded.PacketId = fragmentPacket.MsgId
+	decoded.Payload = data
+	return decoded
+}
+
+func (this *PayloadDecoder) drop(decoded DecodedPayload, err error) DecodedPayload {
+	decoded.Kind = PAYLOAD_DROPPED
+	decoded.Err = err
+	return decoded
+}
+
+// 解压失败时认为不是SDK压缩的消息，原样返回
+func (this *PayloadDecoder) decompress(payload []byte) []byte {
+	data, _, err := compress.Decode(payload)
+	if err != nil {
+		this.logger().Warn("decompress payload fail, deliver it as is: %v", err)
+		return payload
+	}
+	return data
+}
+
+func (this *PayloadDecoder) logger() log.Logger {
+	if this.Logger == nil {
+		return log.GetLogger()
+	}
+	return this.Logger
+}
+
+// MCUser接收路径使用的解码器，端到端解密的错误由调用方上报
+func (this *MCUser) payloadDecoder() *PayloadDecoder {
+	return &PayloadDecoder{
+		Reassembler:   this.reassembler,
+		Decrypt:       this.openPayload,
+		DecryptGroup:  this.openGroupPayload,
+		HandleFailure: this.handleReassembleFailure,
+		Logger:        this.Logger(),
+	}
+}
@@ -0,0 +1,224 @@
+package mimc
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/receipt"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+)
+
+type sentMessage struct {
+	toAccount string
+	delivered bool
+	element   *list.Element
+}
+
+type receivedMessage struct {
+	packetId string
+	sequence int64
+}
+
+/**
+ * 发送端记录等待回执的消息，超过RECEIPT_TRACK_LIMIT时丢弃最早的记录；
+ * 接收端记录已送达未读的消息，供MarkRead按sequence查找。
+ */
+type receiptTracker struct {
+	mu        sync.Mutex
+	sent      map[string]*sentMessage
+	sentOrder *list.List
+	unread    map[string][]receivedMessage
+}
+
+func newReceiptTracker() *receiptTracker {
+	this := new(receiptTracker)
+	this.sent = make(map[string]*sentMessage)
+	this.sentOrder = list.New()
+	this.unread = make(map[string][]receivedMessage)
+	return this
+}
+
+func (this *receiptTracker) track(toAccount, packetId string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.sent[packetId] = &sentMessage{toAccount: toAccount, element: this.sentOrder.PushBack(packetId)}
+	for this.sentOrder.Len() > cnst.RECEIPT_TRACK_LIMIT {
+		this.remove(this.sentOrder.Front().Value.(string))
+	}
+}
+
+func (this *receiptTracker) remove(packetId string) {
+	message := this.sent[packetId]
+	delete(this.sent, packetId)
+	this.sentOrder.Remove(message.element)
+}
+
+// 处理对方的回执，返回需要回调的送达和已读消息
+func (this *receiptTracker) resolve(fromAccount string, r *receipt.Receipt) (delivered, read []string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, packetId := range r.PacketIds {
+		message, ok := this.sent[packetId]
+		if !ok || message.toAccount != fromAccount {
+			continue
+		}
+		if !message.delivered {
+			message.delivered = true
+			delivered = append(delivered, packetId)
+		}
+		if r.Type == receipt.TYPE_READ {
+			read = append(read, packetId)
+			this.remove(packetId)
+		}
+	}
+	return
+}
+
+func (this *receiptTracker) received(fromAccount string, packetId string, sequence int64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	unread := append(this.unread[fromAccount], receivedMessage{packetId, sequence})
+	if len(unread) > cnst.RECEIPT_UNREAD_LIMIT {
+		unread = unread[len(unread)-cnst.RECEIPT_UNREAD_LIMIT:]
+	}
+	this.unread[fromAccount] = unread
+}
+
+func (this *receiptTracker) read(fromAccount string, upToSequence int64) []string {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	var packetIds []string
+	var remain []receivedMessage
+	for _, message := range this.unread[fromAccount] {
+		if message.sequence <= upToSequence {
+			packetIds = append(packetIds, message.packetId)
+		} else {
+			remain = append(remain, message)
+		}
+	}
+	if len(remain) == 0 {
+		delete(this.unread, fromAccount)
+	} else {
+		this.unread[fromAccount] = remain
+	}
+	return packetIds
+}
+
+/**
+ * 开启单聊消息的送达和已读回执，发送端和接收端都需要开启。
+ * 回执通过不存储的单聊消息发送，开启端到端加密时回执同样加密。
+ */
+func (this *MCUser) EnableReceipts() *MCUser {
+	this.receipts = newReceiptTracker()
+	return this
+}
+
+func (this *MCUser) RegisterReceiptDelegate(receiptDelegate ReceiptDelegate) *MCUser {
+	this.receiptDelegate = receiptDelegate
+	return this
+}
+
+/**
+ * 把会话中sequence不超过upToSequence的消息标记为已读。
+ * conversation为store.P2PConversation/P2TConversation返回的会话id，也可以直接传单聊对方的appAccount。
+ * 开启会话列表时更新未读数；开启回执时向单聊对方发送已读回执，没有需要标记的消息时不发送。
+ * 回执和会话列表都没有开启时返回ErrReceiptsDisabled。
+ */
+func (this *MCUser) MarkRead(conversation string, upToSequence int64) error {
+	if this.receipts == nil && this.conversations == nil {
+		return ErrReceiptsDisabled
+	}
+	isP2T := strings.HasPrefix(conversation, store.TYPE_P2T+"/")
+	peer := strings.TrimPrefix(conversation, store.TYPE_P2P+"/")
+	if this.conversations != nil {
+		id := conversation
+		if !isP2T {
+			id = store.P2PConversation(peer)
+		}
+		this.notifyConversation(this.conversations.MarkRead(id, upToSequence))
+	}
+	if this.receipts == nil || isP2T {
+		return nil
+	}
+	packetIds := this.receipts.read(peer, upToSequence)
+	if len(packetIds) == 0 {
+		return nil
+	}
+	return this.sendReceipt(peer, &receipt.Receipt{Type: receipt.TYPE_READ, UpToSequence: upToSequence, PacketIds: packetIds})
+}
+
+func (this *MCUser) trackReceipt(toAppAccount string, packetId string) {
+	if this.receipts == nil || len(packetId) == 0 {
+		return
+	}
+	this.receipts.track(toAppAccount, packetId)
+}
+
+// 消息交给应用后按发送方合并发送送达回执，不存储的消息不发送回执
+func (this *MCUser) sendDeliveryReceipts(p2pMsgList *list.List) {
+	if this.receipts == nil {
+		return
+	}
+	packetIds := make(map[string][]string)
+	for ele := p2pMsgList.Front(); ele != nil; ele = ele.Next() {
+		p2pMsg := ele.Value.(*msg.P2PMessage)
+		if p2pMsg.IsTransient() || p2pMsg.FromAccount() == nil || p2pMsg.PacketId() == nil {
+			continue
+		}
+		fromAccount := *p2pMsg.FromAccount()
+		var sequence int64
+		if p2pMsg.Sequence() != nil {
+			sequence = *p2pMsg.Sequence()
+		}
+		this.receipts.received(fromAccount, *p2pMsg.PacketId(), sequence)
+		packetIds[fromAccount] = append(packetIds[fromAccount], *p2pMsg.PacketId())
+	}
+	for fromAccount, ids := range packetIds {
+		this.sendReceipt(fromAccount, &receipt.Receipt{Type: receipt.TYPE_DELIVERED, PacketIds: ids})
+	}
+}
+
+func (this *MCUser) sendReceipt(toAppAccount string, r *receipt.Receipt) error {
+	payload, err := receipt.Encode(r)
+	if err != nil {
+		return err
+	}
+	// 回执的ack由resolveInternal处理，不回调HandleServerAck
+	packetId := this.sendTransientP2PMessage(toAppAccount, payload, func(packetId string) {
+		this.internalPackets.Push(packetId, toAppAccount)
+	})
+	if len(packetId) == 0 {
+		return ErrReceiptSendFail
+	}
+	return nil
+}
+
+// 处理收到的回执，返回true表示payload是回执，不需要交给应用
+func (this *MCUser) handleReceipt(fromAppAccount string, payload []byte) bool {
+	if !receipt.IsReceipt(payload) {
+		return false
+	}
+	r, err := receipt.Decode(payload)
+	if err != nil {
+		this.Logger().Warn("[handle packet] decode receipt from %v fail: %v", fromAppAccount, err)
+		return true
+	}
+	if this.receipts == nil {
+		return true
+	}
+	delivered, read := this.receipts.resolve(fromAppAccount, r)
+	if this.receiptDelegate == nil {
+		this.Logger().Debug("[handle packet] receipt from %v, delivered: %v, read: %v.", fromAppAccount, len(delivered), len(read))
+		return true
+	}
+	for _, packetId := range delivered {
+		this.receiptDelegate.HandleDelivered(fromAppAccount, packetId)
+	}
+	for _, packetId := range read {
+		this.receiptDelegate.HandleRead(fromAppAccount, packetId)
+	}
+	return true
+}
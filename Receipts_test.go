@@ -0,0 +1,147 @@
+package mimc
+
+import (
+	"container/list"
+	"fmt"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/receipt"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/map"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/queue"
+	"github.com/golang/protobuf/proto"
+)
+
+type receiptCollector struct {
+	delivered []string
+	read      []string
+}
+
+func (this *receiptCollector) HandleDelivered(toAccount, packetId string) {
+	this.delivered = append(this.delivered, toAccount+"/"+packetId)
+}
+
+func (this *receiptCollector) HandleRead(toAccount, packetId string) {
+	this.read = append(this.read, toAccount+"/"+packetId)
+}
+
+func TestReceipts(t *testing.T) {
+	alice, bob := NewUser("Alice"), NewUser("Bob")
+	for _, user := range []*MCUser{alice, bob} {
+		user.messageToSend = que.NewConQueue()
+		user.messageToAck = cmap.NewConMap()
+		user.transientPackets = cmap.NewConMap()
+		user.internalPackets = cmap.NewConMap()
+		user.EnableReceipts()
+	}
+	collector := new(receiptCollector)
+	alice.RegisterReceiptDelegate(collector)
+	first := alice.SendMessage("Bob", []byte("first"))
+	second := alice.SendMessage("Bob", []byte("second"))
+
+	// Bob收到消息后自动发送送达回执
+	from, to := "Alice", "Bob"
+	messages := list.New()
+	for i, packetId := range []string{first, second} {
+		id, sequence := packetId, int64(i+1)
+		messages.PushBack(msg.NewP2pMsg(&id, &from, &to, &sequence, new(int64), nil))
+	}
+	bob.sendDeliveryReceipts(messages)
+	if bob.transientPackets.Size() != 1 {
+		t.Fatalf("one delivery receipt should be sent, got %v", bob.transientPackets.Size())
+	}
+
+	delivered, _ := receipt.Encode(&receipt.Receipt{Type: receipt.TYPE_DELIVERED, PacketIds: []string{first, second}})
+	if !alice.handleReceipt("Bob", delivered) || len(collector.delivered) != 2 {
+		t.Fatalf("expect 2 delivered callbacks, got %v", collector.delivered)
+	}
+	alice.handleReceipt("Bob", delivered)
+	alice.handleReceipt("Carol", delivered)
+	if len(collector.delivered) != 2 {
+		t.Errorf("duplicate or foreign receipts should be ignored, got %v", collector.delivered)
+	}
+
+	if err := bob.MarkRead("Alice", 1); err != nil || bob.transientPackets.Size() != 2 {
+		t.Fatalf("read receipt should be sent: %v", err)
+	}
+	if packetIds := bob.receipts.read("Alice", 2); len(packetIds) != 1 || packetIds[0] != second {
+		t.Errorf("only the second message should remain unread, got %v", packetIds)
+	}
+	read, _ := receipt.Encode(&receipt.Receipt{Type: receipt.TYPE_READ, UpToSequence: 1, PacketIds: []string{first}})
+	alice.handleReceipt("Bob", read)
+	if len(collector.read) != 1 || collector.read[0] != "Bob/"+first {
+		t.Errorf("expect read callback for the first message, got %v", collector.read)
+	}
+	// 回执的ack不回调HandleServerAck
+	acks := new(ackCounter)
+	bob.RegisterMessageDelegate(acks)
+	var receiptIds []string
+	for receiptId := range bob.internalPackets.KVs() {
+		receiptIds = append(receiptIds, receiptId.(string))
+	}
+	for _, receiptId := range receiptIds {
+		ack, _ := proto.Marshal(&MIMCPacketAck{PacketId: proto.String(receiptId), Sequence: proto.Int64(1), Timestamp: proto.Int64(1)})
+		ackPacket, _ := proto.Marshal(&MIMCPacket{PacketId: proto.String("a_" + receiptId), Type: MIMC_MSG_TYPE_PACKET_ACK.Enum(), Payload: ack})
+		bob.handleSecMsg(packet.NewV6Packet().Payload(ackPacket))
+	}
+	if acks.acks != 0 || bob.internalPackets.Size() != 0 || bob.transientPackets.Size() != 0 {
+		t.Errorf("acks of receipts should be absorbed, acks: %v, internal: %v, transient: %v", acks.acks, bob.internalPackets.Size(), bob.transientPackets.Size())
+	}
+	if err := NewUser("Carol").MarkRead("Alice", 1); err != ErrReceiptsDisabled {
+		t.Errorf("expect ErrReceiptsDisabled, got %v", err)
+	}
+}
+
+type p2pCollector struct {
+	nopDelegate
+	messages []*msg.P2PMessage
+}
+
+func (this *p2pCollector) HandleMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.messages = append(this.messages, ele.Value.(*msg.P2PMessage))
+	}
+}
+
+// 回执超过压缩阈值时会被压缩，接收端需要先解压再识别
+func TestCompressedReceipt(t *testing.T) {
+	alice, bob := NewUser("Alice"), NewUser("Bob")
+	for _, user := range []*MCUser{alice, bob} {
+		user.messageToSend = que.NewConQueue()
+		user.messageToAck = cmap.NewConMap()
+		user.transientPackets = cmap.NewConMap()
+		user.internalPackets = cmap.NewConMap()
+		user.EnableReceipts()
+		user.EnableCompression(CompressionConfig{Codec: DefaultCompressionConfig().Codec, Threshold: 64})
+	}
+	collector, messages := new(receiptCollector), new(p2pCollector)
+	alice.RegisterReceiptDelegate(collector).RegisterMessageDelegate(messages)
+	packetIds := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		packetIds = append(packetIds, alice.SendMessage("Bob", []byte(fmt.Sprintf("message %v", i))))
+	}
+	from, to := "Alice", "Bob"
+	received := list.New()
+	for i := range packetIds {
+		sequence := int64(i + 1)
+		received.PushBack(msg.NewP2pMsg(&packetIds[i], &from, &to, &sequence, new(int64), nil))
+	}
+	bob.sendDeliveryReceipts(received)
+	if bob.messageToSend.Size() != 1 {
+		t.Fatalf("one delivery receipt should be sent, got %v", bob.messageToSend.Size())
+	}
+
+	receiptPacket := new(MIMCPacket)
+	if !Deserialize(bob.messageToSend.Pop().(*msg.MsgPacket).Packet().GetPayload(), receiptPacket) {
+		t.Fatalf("parse receipt packet fail")
+	}
+	receiptPacket.Sequence = proto.Int64(1)
+	packetList, _ := proto.Marshal(&MIMCPacketList{Resource: proto.String(alice.resource), Packets: []*MIMCPacket{receiptPacket}})
+	compound, _ := proto.Marshal(&MIMCPacket{PacketId: proto.String("c1"), Type: MIMC_MSG_TYPE_COMPOUND.Enum(), Payload: packetList})
+	alice.handleSecMsg(packet.NewV6Packet().Payload(compound))
+	if len(collector.delivered) != len(packetIds) || len(messages.messages) != 0 {
+		t.Errorf("compressed receipt should be handled, delivered: %v, messages: %v", len(collector.delivered), len(messages.messages))
+	}
+}
@@ -0,0 +1,124 @@
+package mimc
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/inspect"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/recording"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/map"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/queue"
+	"github.com/golang/protobuf/proto"
+)
+
+// 设置会话录制器，记录之后收发的所有帧，传nil停止录制
+func (this *MCUser) SetRecorder(recorder *recording.Recorder) *MCUser {
+	this.recorderLock.Lock()
+	defer this.recorderLock.Unlock()
+	this.recorder = recorder
+	return this
+}
+
+func (this *MCUser) recordFrame(direction string, v6Packet *packet.MIMCV6Packet) {
+	this.recorderLock.RLock()
+	recorder := this.recorder
+	this.recorderLock.RUnlock()
+	if recorder == nil {
+		return
+	}
+	header := v6Packet.GetHeader()
+	// BIND时resource和uuid已经确定，回放需要resource匹配COMPOUND消息
+	if direction == inspect.DIRECTION_OUT && header.GetCmd() == cnst.CMD_BIND {
+		recorder.Account(inspect.Account{AppId: this.AppId(), AppAccount: this.appAccount, Uuid: this.Uuid(), Resource: this.Resource()})
+	}
+	if err := recorder.Record(direction, header, v6Packet.GetPayload()); err != nil {
+		this.Logger().Warn("[record] record frame fail: %v", err)
+	}
+}
+
+/**
+ * Realtime为true时按录制时的时间间隔回放，否则依次立即回放。
+ * Cmd不为空时只回放ClientHeader中cmd为此值的帧，为空时回放全部收到的帧。
+ */
+type ReplayOptions struct {
+	Realtime bool
+	Cmd      string
+}
+
+/**
+ * 把录制的会话中收到的帧依次重新处理，触发与录制时相同的回调。
+ * 回放不做握手、重定向、重新登录，也不修改登录状态。
+ * 应该在没有登录的用户上回放，回放产生的待发送包(如sequence ack)不会发出。
+ * 录制中的用户信息会覆盖当前用户的resource、uuid和appId。
+ */
+func (this *MCUser) Replay(reader io.Reader, options ReplayOptions) error {
+	if this.messageToSend == nil {
+		this.messageToSend = que.NewConQueue()
+		this.messageToAck = cmap.NewConMap()
+		this.internalPackets = cmap.NewConMap()
+		this.transientPackets = cmap.NewConMap()
+	}
+	if this.heartbeat == nil {
+		this.heartbeat = newHeartbeat()
+	}
+	if this.conn == nil {
+		this.conn = NewConn().User(this)
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*inspect.MAX_BODY_LENGTH)
+	var lastTime int64
+	for scanner.Scan() {
+		record := new(inspect.Record)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			this.Logger().Warn("[replay] skip invalid record: %v", err)
+			continue
+		}
+		if record.Account != nil {
+			this.applyAccount(record.Account)
+			continue
+		}
+		if !record.Decoded || record.Direction != inspect.DIRECTION_IN {
+			continue
+		}
+		v6Packet := packet.NewV6Packet()
+		if len(record.Header) > 0 {
+			header := new(ClientHeader)
+			if err := proto.Unmarshal(record.Header, header); err != nil {
+				this.Logger().Warn("[replay] skip invalid header: %v", err)
+				continue
+			}
+			if options.Cmd != "" && header.GetCmd() != options.Cmd {
+				continue
+			}
+			v6Packet.Header(header).Payload(record.Payload)
+		} else if options.Cmd != "" && options.Cmd != cnst.CMD_PING {
+			continue
+		}
+		if options.Realtime && lastTime > 0 && record.Time > lastTime {
+			time.Sleep(time.Duration(record.Time-lastTime) * time.Millisecond)
+		}
+		lastTime = record.Time
+		this.dispatchResponse(v6Packet, true)
+	}
+	return scanner.Err()
+}
+
+func (this *MCUser) applyAccount(account *inspect.Account) {
+	if account.Resource != "" {
+		this.SetResource(account.Resource)
+	}
+	if account.Uuid != 0 {
+		this.SetUuid(account.Uuid)
+	}
+	if account.AppId != 0 {
+		this.SetAppId(account.AppId)
+	}
+	if this.appAccount == "" {
+		this.appAccount = account.AppAccount
+	}
+}
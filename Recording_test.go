@@ -0,0 +1,112 @@
+package mimc
+
+import (
+	"bytes"
+	"container/list"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/inspect"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	. "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/recording"
+	"github.com/golang/protobuf/proto"
+)
+
+type p2pMessageCollector struct {
+	MessageHandlerDelegate
+	messages []*msg.P2PMessage
+	acks     []string
+}
+
+func (this *p2pMessageCollector) HandleMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.messages = append(this.messages, ele.Value.(*msg.P2PMessage))
+	}
+}
+
+func (this *p2pMessageCollector) HandleServerAck(packetId *string, sequence, timestamp *int64) {
+	this.acks = append(this.acks, *packetId)
+}
+
+func compoundPacket(resource string, payload string) *packet.MIMCV6Packet {
+	p2p, _ := proto.Marshal(&MIMCP2PMessage{From: &MIMCUser{AppAccount: proto.String("Bob")}, To: &MIMCUser{AppAccount: proto.String("Alice")}, Payload: []byte(payload)})
+	packetList, _ := proto.Marshal(&MIMCPacketList{
+		Uuid:        proto.Int64(1),
+		Resource:    proto.String(resource),
+		MaxSequence: proto.Int64(5),
+		Packets:     []*MIMCPacket{{PacketId: proto.String("p1"), Sequence: proto.Int64(5), Timestamp: proto.Int64(1000), Type: MIMC_MSG_TYPE_P2P_MESSAGE.Enum(), Payload: p2p}},
+	})
+	mimcPacket, _ := proto.Marshal(&MIMCPacket{PacketId: proto.String("c1"), Type: MIMC_MSG_TYPE_COMPOUND.Enum(), Payload: packetList})
+	return packet.NewV6Packet().Header(&ClientHeader{Cmd: proto.String(cnst.CMD_SECMSG), Id: proto.String("h1")}).Payload(mimcPacket)
+}
+
+func recordSession(t *testing.T, options recording.Options) *bytes.Buffer {
+	buffer := new(bytes.Buffer)
+	alice := NewUser("Alice").SetResource("res1").SetRecorder(recording.NewRecorder(buffer, options))
+	alice.recordFrame(inspect.DIRECTION_OUT, packet.NewV6Packet().Header(&ClientHeader{Cmd: proto.String(cnst.CMD_BIND), Id: proto.String("b1")}).Payload([]byte{}))
+	alice.recordFrame(inspect.DIRECTION_IN, compoundPacket("res1", "hello"))
+	ack, _ := proto.Marshal(&MIMCPacketAck{PacketId: proto.String("s1"), Sequence: proto.Int64(6), Timestamp: proto.Int64(2000)})
+	ackPacket, _ := proto.Marshal(&MIMCPacket{PacketId: proto.String("a1"), Type: MIMC_MSG_TYPE_PACKET_ACK.Enum(), Payload: ack})
+	alice.recordFrame(inspect.DIRECTION_IN, packet.NewV6Packet().Header(&ClientHeader{Cmd: proto.String(cnst.CMD_SECMSG), Id: proto.String("h2")}).Payload(ackPacket))
+	return buffer
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	collector := new(p2pMessageCollector)
+	replayed := NewUser("Alice").RegisterMessageDelegate(collector)
+	if err := replayed.Replay(recordSession(t, recording.Options{}), ReplayOptions{}); err != nil {
+		t.Fatalf("replay fail: %v", err)
+	}
+	if len(collector.messages) != 1 || string(collector.messages[0].Payload()) != "hello" || replayed.Resource() != "res1" {
+		t.Fatalf("replayed messages mismatch: %v, resource %v", len(collector.messages), replayed.Resource())
+	}
+	if len(collector.acks) != 1 || collector.acks[0] != "s1" {
+		t.Errorf("replayed acks mismatch: %v", collector.acks)
+	}
+
+	collector = new(p2pMessageCollector)
+	replayed = NewUser("Alice").RegisterMessageDelegate(collector)
+	replayed.Replay(recordSession(t, recording.Options{RedactPayloads: true}), ReplayOptions{Cmd: cnst.CMD_SECMSG})
+	if len(collector.messages) != 1 || string(collector.messages[0].Payload()) != recording.REDACTED {
+		t.Errorf("payload should be redacted: %v", len(collector.messages))
+	}
+}
+
+type countingToken struct {
+	fetches int
+}
+
+func (this *countingToken) FetchToken() *string {
+	this.fetches++
+	return nil
+}
+
+// 回放CONN、BIND、KICK只触发回调，不重连、不重新登录、不修改登录状态
+func TestReplayWithoutSideEffects(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	recorder := NewUser("Alice").SetRecorder(recording.NewRecorder(buffer, recording.Options{}))
+	connResp, _ := proto.Marshal(&XMMsgConnResp{Challenge: proto.String("challenge"), Host: proto.String("10.0.0.1:5222")})
+	recorder.recordFrame(inspect.DIRECTION_IN, packet.NewV6Packet().Header(&ClientHeader{Cmd: proto.String(cnst.CMD_CONN), Id: proto.String("c1")}).Payload(connResp))
+	bindResp, _ := proto.Marshal(&XMMsgBindResp{Result: proto.Bool(false), ErrorType: proto.String(cnst.MIMC_TOKEN_EXPIRE)})
+	recorder.recordFrame(inspect.DIRECTION_IN, packet.NewV6Packet().Header(&ClientHeader{Cmd: proto.String(cnst.CMD_BIND), Id: proto.String("b1")}).Payload(bindResp))
+	recorder.recordFrame(inspect.DIRECTION_IN, packet.NewV6Packet().Header(&ClientHeader{Cmd: proto.String(cnst.CMD_KICK), Id: proto.String("k1")}).Payload([]byte{}))
+
+	token, counter := new(countingToken), new(changeCounter)
+	replayed := NewUser("Alice").RegisterTokenDelegate(token).RegisterStatusDelegate(counter)
+	status := replayed.Status()
+	if err := replayed.Replay(buffer, ReplayOptions{}); err != nil {
+		t.Fatalf("replay fail: %v", err)
+	}
+	if token.fetches != 0 || replayed.Status() != status {
+		t.Errorf("replay should not log in again or change status, fetches: %v, status: %v", token.fetches, replayed.Status())
+	}
+	if replayed.conn.Status() != NOT_CONNECTED || replayed.ConnConfig().RedirectHost != "" {
+		t.Errorf("replay should not touch the connection: %v, %+v", replayed.conn.Status(), replayed.ConnConfig())
+	}
+	if counter.changes != 2 {
+		t.Errorf("status callbacks should be replayed, got %v", counter.changes)
+	}
+}
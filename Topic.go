@@ -0,0 +1,31 @@
+package mimc
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/topic"
+)
+
+// 设置MIMC REST接口地址，默认为线上环境
+func (this *MCUser) SetRestUrl(restUrl string) *MCUser {
+	this.restUrl = restUrl
+	return this
+}
+
+func (this *MCUser) RestUrl() string {
+	return this.restUrl
+}
+
+/**
+ * 返回使用当前用户token的群管理客户端，需要先登录获取token。
+ * 客户端每次请求时读取最新的token，token过期重新登录后可以继续使用。
+ */
+func (this *MCUser) TopicClient() *topic.Client {
+	return topic.NewClient(this.restUrl, this.AppId(), this.currentToken)
+}
+
+func (this *MCUser) currentToken() string {
+	token := this.Token()
+	if token == nil {
+		return ""
+	}
+	return *token
+}
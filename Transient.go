@@ -0,0 +1,83 @@
+package mimc
+
+import (
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+/**
+ * 发送不存储的单聊消息(IsStore=false)，适用于正在输入、在线状态等信令。
+ * 服务端不做离线存储，SDK不做超时重发也不回调HandleSendMessageTimeout，大消息不分片。
+ */
+func (this *MCUser) SendTransientMessage(toAppAccount string, msgByte []byte) string {
+	return this.sendTransientP2PMessage(toAppAccount, msgByte, nil)
+}
+
+// register在包进入发送队列前调用，与sendP2PMessage相同
+func (this *MCUser) sendTransientP2PMessage(toAppAccount string, msgByte []byte, register func(packetId string)) string {
+	if len(msgByte) == 0 {
+		return ""
+	}
+	payload, ok := this.encryptPayload(toAppAccount, this.compressPayload(msgByte))
+	if !ok {
+		return ""
+	}
+	v6Packet, mimcPacket := BuildP2PMessagePacket(this, toAppAccount, payload, false)
+	this.Logger().With(log.FieldPacketId, *(mimcPacket.PacketId)).Debug("[Send Transient P2P Msg]%v -> %v.", this.appAccount, toAppAccount)
+	if register != nil {
+		register(*(mimcPacket.PacketId))
+	}
+	return this.sendTransient(msg.NewMsgPacket(cnst.MIMC_C2S_DOUBLE_DIRECTION, v6Packet), *(mimcPacket.PacketId))
+}
+
+// 发送不存储的群聊消息(IsStore=false)，与SendTransientMessage相同不做超时跟踪
+func (this *MCUser) SendTransientGroupMessage(topicId *int64, msgByte []byte) string {
+	if topicId == nil || len(msgByte) == 0 {
+		return ""
+	}
+	payload, ok := this.encryptGroupPayload(*topicId, this.compressPayload(msgByte))
+	if !ok {
+		return ""
+	}
+	v6Packet, mimcPacket := BuildP2TMessagePacket(this, *topicId, payload, false)
+	this.Logger().With(log.FieldPacketId, *(mimcPacket.PacketId)).Debug("[Send Transient P2T Msg]%v -> %v.", this.appAccount, *topicId)
+	return this.sendTransient(msg.NewMsgPacket(cnst.MIMC_C2S_DOUBLE_DIRECTION, v6Packet), *(mimcPacket.PacketId))
+}
+
+// 只记录发送时间用于识别服务端的ack，不进入messageToAck
+func (this *MCUser) sendTransient(msgPacket *msg.MsgPacket, packetId string) string {
+	// 先登记再入队，否则ack可能先于登记到达而被当作未知的ack
+	this.transientPackets.Push(packetId, CurrentTimeMillis())
+	this.messageToSend.Push(msgPacket)
+	return packetId
+}
+
+func (this *MCUser) resolveTransient(packetId string) bool {
+	sentAt := this.transientPackets.Pop(packetId)
+	if sentAt == nil {
+		return false
+	}
+	this.metrics.ObserveAckLatency(this.appAccount, time.Duration(CurrentTimeMillis()-sentAt.(int64))*time.Millisecond)
+	return true
+}
+
+// 清理一直没有收到ack的不存储消息，不做回调，回执等SDK内部消息的登记同时移除
+func (this *MCUser) expireTransients() {
+	now := CurrentTimeMillis()
+	var expired []string
+	this.transientPackets.Lock()
+	kvs := this.transientPackets.KVs()
+	for packetId, sentAt := range kvs {
+		if now-sentAt.(int64) >= cnst.CHECK_TIMEOUT_TIMEVAL_MS {
+			delete(kvs, packetId)
+			expired = append(expired, packetId.(string))
+		}
+	}
+	this.transientPackets.Unlock()
+	for _, packetId := range expired {
+		this.internalPackets.Pop(packetId)
+	}
+}
@@ -0,0 +1,33 @@
+package mimc
+
+import (
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/map"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/queue"
+)
+
+func TestTransientMessage(t *testing.T) {
+	user := NewUser("Alice")
+	user.messageToSend = que.NewConQueue()
+	user.messageToAck = cmap.NewConMap()
+	user.transientPackets = cmap.NewConMap()
+	user.internalPackets = cmap.NewConMap()
+	packetId := user.SendTransientMessage("Bob", []byte("typing"))
+	if len(packetId) == 0 || user.messageToSend.Size() != 1 {
+		t.Fatalf("transient message should be sent")
+	}
+	if user.messageToAck.Size() != 0 {
+		t.Errorf("transient message should not wait for ack")
+	}
+	if !user.resolveTransient(packetId) || user.resolveTransient(packetId) {
+		t.Errorf("ack of transient message should be resolved once")
+	}
+
+	user.transientPackets.Push("stale", CurrentTimeMillis()-cnst.CHECK_TIMEOUT_TIMEVAL_MS)
+	user.expireTransients()
+	if user.transientPackets.Size() != 0 {
+		t.Errorf("stale transient packet should be expired")
+	}
+}
@@ -0,0 +1,152 @@
+package callback
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+)
+
+const (
+	MSG_TYPE_P2P = "P2P_MESSAGE"
+	MSG_TYPE_P2T = "P2T_MESSAGE"
+
+	HEADER_TIMESTAMP = "X-MIMC-Timestamp"
+	HEADER_SIGNATURE = "X-MIMC-Signature"
+)
+
+var (
+	ErrInvalidConfig    = errors.New("callback: invalid config")
+	ErrInvalidSignature = errors.New("callback: invalid signature")
+	ErrExpired          = errors.New("callback: timestamp out of range")
+	ErrInvalidBody      = errors.New("callback: invalid body")
+	ErrAppMismatch      = errors.New("callback: appId does not match")
+)
+
+/**
+ * 回调中的一条消息。MIMC没有公开MsgCallbackUrl/OfflineMsgCallbackUrl的请求格式，
+ * 这里的JSON格式和签名方式是本SDK自己约定的，需要由转发回调的一方按此格式签名后POST。
+ * Payload在JSON中为base64编码，是发送端SDK处理后的原始数据，可能经过分片、压缩或端到端加密；
+ * P2P消息有ToAccount，P2T消息有TopicId。
+ */
+type Callback struct {
+	MsgType      string `json:"msgType"`
+	AppId        int64  `json:"appId"`
+	PacketId     string `json:"packetId"`
+	Sequence     int64  `json:"sequence"`
+	Timestamp    int64  `json:"timestamp"`
+	FromAccount  string `json:"fromAccount"`
+	FromResource string `json:"fromResource,omitempty"`
+	ToAccount    string `json:"toAccount,omitempty"`
+	TopicId      int64  `json:"topicId,omitempty"`
+	Payload      []byte `json:"payload"`
+	BizType      string `json:"bizType,omitempty"`
+	IsStore      *bool  `json:"isStore,omitempty"`
+}
+
+/**
+ * 解析回调的请求体，请求体为一条消息或消息数组。
+ * 缺少packetId、fromAccount，或P2P消息缺少toAccount、P2T消息缺少topicId时返回ErrInvalidBody，
+ * 未知的msgType原样返回，由调用方决定是否忽略。
+ */
+func Parse(body []byte) ([]*Callback, error) {
+	body = bytes.TrimSpace(body)
+	var callbacks []*Callback
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &callbacks); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+		}
+	} else {
+		callback := new(Callback)
+		if err := json.Unmarshal(body, callback); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+		}
+		callbacks = append(callbacks, callback)
+	}
+	for index, callback := range callbacks {
+		if err := callback.validate(); err != nil {
+			return nil, fmt.Errorf("%w: message %d: %v", ErrInvalidBody, index, err)
+		}
+	}
+	return callbacks, nil
+}
+
+func (this *Callback) validate() error {
+	if this == nil {
+		return errors.New("null message")
+	}
+	if this.PacketId == "" || this.FromAccount == "" {
+		return errors.New("missing packetId or fromAccount")
+	}
+	if this.MsgType == MSG_TYPE_P2P && this.ToAccount == "" {
+		return errors.New("P2P message without toAccount")
+	}
+	if this.MsgType == MSG_TYPE_P2T && this.TopicId == 0 {
+		return errors.New("P2T message without topicId")
+	}
+	return nil
+}
+
+// 是否为不存储的消息，回调中没有isStore时按存储处理
+func (this *Callback) IsTransient() bool {
+	return this.IsStore != nil && !*this.IsStore
+}
+
+// 转换为MCUser回调使用的P2P消息，Payload不做解码，msgType不是P2P_MESSAGE时返回nil
+func (this *Callback) P2PMessage() *msg.P2PMessage {
+	if this.MsgType != MSG_TYPE_P2P {
+		return nil
+	}
+	packetId, fromAccount, toAccount := this.PacketId, this.FromAccount, this.ToAccount
+	sequence, timestamp := this.Sequence, this.Timestamp
+	return msg.NewP2pMsg(&packetId, &fromAccount, &toAccount, &sequence, &timestamp, this.Payload).SetTransient(this.IsTransient())
+}
+
+// 转换为MCUser回调使用的P2T消息，Payload不做解码，msgType不是P2T_MESSAGE时返回nil
+func (this *Callback) P2TMessage() *msg.P2TMessage {
+	if this.MsgType != MSG_TYPE_P2T {
+		return nil
+	}
+	packetId, fromAccount := this.PacketId, this.FromAccount
+	sequence, timestamp, topicId := this.Sequence, this.Timestamp, this.TopicId
+	return msg.NewP2tMsg(&packetId, &fromAccount, &sequence, &timestamp, &topicId, this.Payload).SetTransient(this.IsTransient())
+}
+
+/**
+ * 本SDK约定的回调签名，不是MIMC服务端的签名方式：以appSecret为密钥，对"timestamp.body"做HMAC-SHA256，十六进制小写。
+ * timestamp为毫秒时间戳，与签名分别放在X-MIMC-Timestamp和X-MIMC-Signature请求头中。
+ * 对接其它签名方式时使用Config.Verify。
+ */
+func Sign(appSecret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 校验签名，timestamp与now相差超过maxSkew时返回ErrExpired，maxSkew为0时不校验时间
+func Verify(appSecret, timestamp, signature string, body []byte, now time.Time, maxSkew time.Duration) error {
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: timestamp %q", ErrInvalidSignature, timestamp)
+	}
+	expected := Sign(appSecret, millis, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if maxSkew > 0 {
+		skew := now.Sub(time.Unix(0, millis*int64(time.Millisecond)))
+		if skew > maxSkew || skew < -maxSkew {
+			return fmt.Errorf("%w: %v", ErrExpired, skew)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package callback
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/fragment"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+const (
+	DEFAULT_MAX_SKEW      = 5 * time.Minute
+	DEFAULT_MAX_BODY_SIZE = 4 * 1024 * 1024
+)
+
+/**
+ * 自定义签名校验，替换默认的HMAC-SHA256校验，返回错误时响应401。
+ * body为已经读出的请求体。
+ */
+type Verifier func(request *http.Request, body []byte) error
+
+/**
+ * AppId不为0时，拒绝其它应用的回调。AppSecret用于默认的签名校验，设置Verify时可以为空。
+ * MaxSkew为回调时间戳允许的偏差，小于0时不校验时间。
+ * Delegate与MCUser的MessageHandlerDelegate相同，只会回调HandleMessage和HandleGroupMessage。
+ * Fragment中为0的分片大小、重组超时和缓存上限使用默认值。
+ * Decrypt、DecryptGroup与mimc.PayloadDecoder相同，为nil时端到端加密的消息被丢弃。
+ * ErrDelegate接收解密失败等错误，可以为nil。
+ */
+type Config struct {
+	AppId        int64
+	AppSecret    string
+	Verify       Verifier
+	Delegate     mimc.MessageHandlerDelegate
+	MaxSkew      time.Duration
+	MaxBodySize  int64
+	Fragment     mimc.FragmentConfig
+	Decrypt      func(fromAccount string, payload []byte) ([]byte, error)
+	DecryptGroup func(topicId int64, fromAccount string, payload []byte) ([]byte, error)
+	ErrDelegate  mimc.ErrorDelegate
+}
+
+/**
+ * 接收MIMC消息回调的http.Handler，校验签名后把消息交给Delegate。
+ * payload与MCUser的接收路径一样经过mimc.PayloadDecoder解码：分片收齐后才回调，
+ * 解密、解压后交给应用，回执和sender key分发等SDK内部消息不回调。
+ * 同一请求中的P2P、P2T消息分别合并为一次HandleMessage、HandleGroupMessage回调。
+ * 回调返回后才响应200，MIMC在非200时会重试，Delegate需要按packetId去重。
+ * MsgCallbackUrl和OfflineMsgCallbackUrl可以分别使用不同Delegate的Handler。
+ */
+type Handler struct {
+	config      Config
+	now         func() time.Time
+	reassembler *fragment.Reassembler
+	decoder     *mimc.PayloadDecoder
+}
+
+func NewHandler(config Config) (*Handler, error) {
+	if config.Delegate == nil || (config.Verify == nil && config.AppSecret == "") {
+		return nil, ErrInvalidConfig
+	}
+	if config.MaxSkew == 0 {
+		config.MaxSkew = DEFAULT_MAX_SKEW
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DEFAULT_MAX_BODY_SIZE
+	}
+	if config.Fragment.FragmentSize <= 0 {
+		config.Fragment.FragmentSize = cnst.FRAGMENT_SIZE
+	}
+	if config.Fragment.ReassembleTimeoutMs <= 0 {
+		config.Fragment.ReassembleTimeoutMs = cnst.REASSEMBLE_TIMEOUT_MS
+	}
+	if config.Fragment.MaxReassembleBytes <= 0 {
+		config.Fragment.MaxReassembleBytes = cnst.MAX_REASSEMBLE_BYTES
+	}
+	handler := &Handler{config: config, now: time.Now}
+	handler.reassembler = fragment.NewReassembler(config.Fragment.ReassembleTimeoutMs, config.Fragment.MaxReassembleBytes).SetFragmentSize(config.Fragment.FragmentSize)
+	handler.decoder = &mimc.PayloadDecoder{
+		Reassembler:   handler.reassembler,
+		Decrypt:       config.Decrypt,
+		DecryptGroup:  config.DecryptGroup,
+		HandleFailure: handleReassembleFailure,
+	}
+	return handler, nil
+}
+
+func (this *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeResult(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, this.config.MaxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeResult(w, http.StatusRequestEntityTooLarge, "body too large")
+		} else {
+			writeResult(w, http.StatusBadRequest, "read body fail")
+		}
+		return
+	}
+	if err := this.verify(r, body); err != nil {
+		log.GetLogger().Warn("[callback] verify fail: %v", err)
+		writeResult(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	callbacks, err := Parse(body)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := this.Dispatch(callbacks); err != nil {
+		writeResult(w, http.StatusForbidden, err.Error())
+		return
+	}
+	writeResult(w, http.StatusOK, "success")
+}
+
+func (this *Handler) verify(r *http.Request, body []byte) error {
+	if this.config.Verify != nil {
+		return this.config.Verify(r, body)
+	}
+	maxSkew := this.config.MaxSkew
+	if maxSkew < 0 {
+		maxSkew = 0
+	}
+	return Verify(this.config.AppSecret, r.Header.Get(HEADER_TIMESTAMP), r.Header.Get(HEADER_SIGNATURE), body, this.now(), maxSkew)
+}
+
+/**
+ * 解码已经解析的消息并交给Delegate，不是本应用的消息返回ErrAppMismatch且不回调。
+ * 未知msgType的消息、SDK内部消息和解密失败的消息被忽略。
+ */
+func (this *Handler) Dispatch(callbacks []*Callback) error {
+	if this.config.AppId != 0 {
+		for _, callback := range callbacks {
+			if callback.AppId != 0 && callback.AppId != this.config.AppId {
+				return ErrAppMismatch
+			}
+		}
+	}
+	// 分片可能分散在多次回调中，每次回调时丢弃超时未收齐的消息
+	for _, failure := range this.reassembler.Expire(this.now().UnixNano() / int64(time.Millisecond)) {
+		handleReassembleFailure(failure)
+	}
+	p2pMsgList := list.New()
+	p2tMsgList := list.New()
+	for _, callback := range callbacks {
+		switch callback.MsgType {
+		case MSG_TYPE_P2P:
+			decoded := this.decoder.DecodeP2P(callback.FromAccount, callback.PacketId, callback.Payload)
+			if this.deliverable(callback, decoded) {
+				p2pMsgList.PushBack(withPayload(callback, decoded).P2PMessage())
+			}
+		case MSG_TYPE_P2T:
+			decoded := this.decoder.DecodeP2T(callback.TopicId, callback.FromAccount, callback.PacketId, callback.Payload)
+			if this.deliverable(callback, decoded) {
+				p2tMsgList.PushBack(withPayload(callback, decoded).P2TMessage())
+			}
+		default:
+			log.GetLogger().Warn("[callback] ignore packetId: %v, unknown msgType: %v", callback.PacketId, callback.MsgType)
+		}
+	}
+	if p2pMsgList.Len() > 0 {
+		this.config.Delegate.HandleMessage(p2pMsgList)
+	}
+	if p2tMsgList.Len() > 0 {
+		this.config.Delegate.HandleGroupMessage(p2tMsgList)
+	}
+	return nil
+}
+
+// 只有应用消息交给Delegate，解密失败时通知ErrDelegate
+func (this *Handler) deliverable(callback *Callback, decoded mimc.DecodedPayload) bool {
+	switch decoded.Kind {
+	case mimc.PAYLOAD_MESSAGE:
+		return true
+	case mimc.PAYLOAD_DROPPED:
+		log.GetLogger().Warn("[callback] drop packetId: %v from %v: %v", decoded.PacketId, callback.FromAccount, decoded.Err)
+		if this.config.ErrDelegate != nil {
+			this.config.ErrDelegate.HandleError(&mimc.E2EError{Account: callback.FromAccount, TopicId: callback.TopicId, PacketId: decoded.PacketId, Err: decoded.Err})
+		}
+	case mimc.PAYLOAD_INCOMPLETE:
+		log.GetLogger().Debug("[callback] packetId: %v is a fragment, wait for the rest.", callback.PacketId)
+	default:
+		log.GetLogger().Debug("[callback] skip internal message packetId: %v from %v.", callback.PacketId, callback.FromAccount)
+	}
+	return false
+}
+
+// 使用解码后的payload，分片消息使用消息级别的id
+func withPayload(callback *Callback, decoded mimc.DecodedPayload) *Callback {
+	copied := *callback
+	copied.PacketId = decoded.PacketId
+	copied.Payload = decoded.Payload
+	return &copied
+}
+
+func handleReassembleFailure(failure *fragment.Failure) {
+	log.GetLogger().Warn("[callback] reassemble message %v from %v fail: %v, received: %v/%v.", failure.MsgId, failure.Key, failure.Err, failure.Received, failure.Total)
+}
+
+// 与MIMC接口相同的{"code","message"}响应
+func writeResult(w http.ResponseWriter, statusCode int, message string) {
+	body, _ := json.Marshal(map[string]interface{}{"code": statusCode, "message": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
@@ -0,0 +1,184 @@
+package callback
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/compress"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/e2e"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/fragment"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/receipt"
+)
+
+type collector struct {
+	messages      []*msg.P2PMessage
+	groupMessages []*msg.P2TMessage
+}
+
+func (this *collector) HandleMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.messages = append(this.messages, ele.Value.(*msg.P2PMessage))
+	}
+}
+
+func (this *collector) HandleGroupMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.groupMessages = append(this.groupMessages, ele.Value.(*msg.P2TMessage))
+	}
+}
+
+func (this *collector) HandleServerAck(packetId *string, sequence, timestamp *int64) {}
+func (this *collector) HandleSendMessageTimeout(message *msg.P2PMessage)             {}
+func (this *collector) HandleSendGroupMessageTimeout(message *msg.P2TMessage)        {}
+
+const testBody = `[
+	{"msgType":"P2P_MESSAGE","appId":7,"packetId":"p1","sequence":3,"timestamp":1700000000000,"fromAccount":"Alice","toAccount":"Bob","payload":"aGVsbG8="},
+	{"msgType":"P2T_MESSAGE","appId":7,"packetId":"p2","sequence":4,"timestamp":1700000000001,"fromAccount":"Alice","topicId":9,"payload":"aGk=","isStore":false},
+	{"msgType":"UC_MESSAGE","appId":7,"packetId":"p3","fromAccount":"Alice"}
+]`
+
+func post(t *testing.T, handler http.Handler, body string, timestamp int64, signature string) int {
+	request := httptest.NewRequest(http.MethodPost, "/mimc/callback", bytes.NewReader([]byte(body)))
+	request.Header.Set(HEADER_TIMESTAMP, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HEADER_SIGNATURE, signature)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestHandler(t *testing.T) {
+	delegate := new(collector)
+	handler, err := NewHandler(Config{AppId: 7, AppSecret: "secret", Delegate: delegate})
+	if err != nil {
+		t.Fatalf("new handler fail: %v", err)
+	}
+	now := time.Now()
+	handler.now = func() time.Time { return now }
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+
+	if code := post(t, handler, testBody, timestamp, Sign("secret", timestamp, []byte(testBody))); code != http.StatusOK {
+		t.Fatalf("expect 200, got %v", code)
+	}
+	if len(delegate.messages) != 1 || len(delegate.groupMessages) != 1 {
+		t.Fatalf("expect 1 p2p and 1 p2t message, got %v, %v", len(delegate.messages), len(delegate.groupMessages))
+	}
+	p2p := delegate.messages[0]
+	if *p2p.PacketId() != "p1" || *p2p.FromAccount() != "Alice" || *p2p.ToAccount() != "Bob" || *p2p.Sequence() != 3 || string(p2p.Payload()) != "hello" || p2p.IsTransient() {
+		t.Errorf("p2p message mismatch: %v", p2p)
+	}
+	p2t := delegate.groupMessages[0]
+	if *p2t.PacketId() != "p2" || *p2t.GroupId() != 9 || string(p2t.Payload()) != "hi" || !p2t.IsTransient() {
+		t.Errorf("p2t message mismatch: %v", p2t)
+	}
+
+	cases := []struct {
+		name      string
+		body      string
+		timestamp int64
+		signature string
+		code      int
+	}{
+		{"wrong secret", testBody, timestamp, Sign("other", timestamp, []byte(testBody)), http.StatusUnauthorized},
+		{"tampered body", testBody + " ", timestamp, Sign("secret", timestamp, []byte(testBody)), http.StatusUnauthorized},
+		{"expired", testBody, timestamp - 10*60*1000, Sign("secret", timestamp-10*60*1000, []byte(testBody)), http.StatusUnauthorized},
+		{"missing toAccount", `{"msgType":"P2P_MESSAGE","packetId":"p1","fromAccount":"Alice"}`, timestamp, "", http.StatusBadRequest},
+		{"other app", `{"msgType":"P2P_MESSAGE","appId":8,"packetId":"p1","fromAccount":"Alice","toAccount":"Bob"}`, timestamp, "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		signature := c.signature
+		if signature == "" {
+			signature = Sign("secret", c.timestamp, []byte(c.body))
+		}
+		if code := post(t, handler, c.body, c.timestamp, signature); code != c.code {
+			t.Errorf("%v: expect %v, got %v", c.name, c.code, code)
+		}
+	}
+	if len(delegate.messages) != 1 {
+		t.Errorf("rejected callbacks should not be dispatched, got %v messages", len(delegate.messages))
+	}
+}
+
+func TestCustomVerify(t *testing.T) {
+	delegate := new(collector)
+	if _, err := NewHandler(Config{Delegate: delegate}); err != ErrInvalidConfig {
+		t.Errorf("expect ErrInvalidConfig without secret or verifier, got %v", err)
+	}
+	handler, _ := NewHandler(Config{Delegate: delegate, Verify: func(request *http.Request, body []byte) error {
+		if request.Header.Get("Authorization") != "Bearer ok" {
+			return errors.New("unauthorized")
+		}
+		return nil
+	}})
+	body := `{"msgType":"P2P_MESSAGE","packetId":"p1","fromAccount":"Alice","toAccount":"Bob"}`
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body)))
+	request.Header.Set("Authorization", "Bearer ok")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK || len(delegate.messages) != 1 || *delegate.messages[0].Sequence() != 0 {
+		t.Errorf("expect dispatched message, got %v, %v", recorder.Code, len(delegate.messages))
+	}
+	if code := post(t, handler, body, 0, ""); code != http.StatusUnauthorized {
+		t.Errorf("expect 401, got %v", code)
+	}
+}
+
+type errorCollector struct {
+	errs []error
+}
+
+func (this *errorCollector) HandleError(err error) {
+	this.errs = append(this.errs, err)
+}
+
+func TestDispatchDecodesPayload(t *testing.T) {
+	delegate := new(collector)
+	errDelegate := new(errorCollector)
+	errDecrypt := errors.New("decrypt fail")
+	handler, _ := NewHandler(Config{AppSecret: "secret", Delegate: delegate, ErrDelegate: errDelegate,
+		Decrypt: func(fromAccount string, payload []byte) ([]byte, error) {
+			if fromAccount == "Mallory" {
+				return nil, errDecrypt
+			}
+			return payload, nil
+		}})
+
+	text := bytes.Repeat([]byte("hello mimc "), 100)
+	compressed, _, _ := compress.Encode(compress.CODEC_GZIP, text)
+	fragments, err := fragment.Split("m1", compressed, len(compressed)/2+1)
+	if err != nil || len(fragments) != 2 {
+		t.Fatalf("split fail: %v, %v", err, len(fragments))
+	}
+	receiptPayload, _ := receipt.Encode(&receipt.Receipt{Type: receipt.TYPE_DELIVERED, PacketIds: []string{"p0"}})
+	handler.Dispatch([]*Callback{
+		{MsgType: MSG_TYPE_P2P, PacketId: "f1", FromAccount: "Alice", ToAccount: "Bob", Payload: fragments[0]},
+		{MsgType: MSG_TYPE_P2P, PacketId: "r1", FromAccount: "Alice", ToAccount: "Bob", Payload: receiptPayload},
+		{MsgType: MSG_TYPE_P2P, PacketId: "x1", FromAccount: "Mallory", ToAccount: "Bob", Payload: []byte("hi")},
+	})
+	if len(delegate.messages) != 0 {
+		t.Fatalf("fragments, receipts and undecryptable messages should not be dispatched, got %v", len(delegate.messages))
+	}
+	if len(errDelegate.errs) != 1 || !errors.Is(errDelegate.errs[0], errDecrypt) {
+		t.Errorf("expect decrypt error, got %v", errDelegate.errs)
+	}
+	// 第二个分片在另一次回调中到达
+	handler.Dispatch([]*Callback{{MsgType: MSG_TYPE_P2P, PacketId: "f2", FromAccount: "Alice", ToAccount: "Bob", Payload: fragments[1]}})
+	if len(delegate.messages) != 1 || *delegate.messages[0].PacketId() != "m1" || !bytes.Equal(delegate.messages[0].Payload(), text) {
+		t.Fatalf("expect reassembled and decompressed message, got %v", delegate.messages)
+	}
+
+	// 没有DecryptGroup时端到端加密的群聊消息被丢弃
+	plain, _ := NewHandler(Config{AppSecret: "secret", Delegate: delegate})
+	senderKey, _ := e2e.NewSenderKey()
+	sealed, _ := senderKey.Seal(9, "Alice", []byte("secret"))
+	plain.Dispatch([]*Callback{{MsgType: MSG_TYPE_P2T, PacketId: "g1", FromAccount: "Alice", TopicId: 9, Payload: sealed}})
+	if len(delegate.groupMessages) != 0 {
+		t.Errorf("encrypted group message should be dropped without DecryptGroup")
+	}
+}
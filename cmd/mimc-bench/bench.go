@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend/fake"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/token"
+)
+
+type config struct {
+	users        int
+	prefix       string
+	duration     time.Duration
+	p2pRate      float64
+	p2tRate      float64
+	topicId      int64
+	size         int
+	loginTimeout time.Duration
+	drain        time.Duration
+	interval     time.Duration
+	format       string
+	logPath      string
+
+	// 连接真实的前端
+	peer      string
+	tokenUrl  string
+	appId     int64
+	appKey    string
+	appSecret string
+
+	// 内置的fake前端
+	ackDelay    time.Duration
+	dropAckRate float64
+	maxConnAge  time.Duration
+}
+
+func (this *config) validate() error {
+	if this.users < 1 {
+		return errors.New("-users must be at least 1")
+	}
+	if this.size < 1 {
+		return errors.New("-size must be at least 1")
+	}
+	if this.p2pRate < 0 || this.p2tRate < 0 || this.p2pRate+this.p2tRate == 0 {
+		return errors.New("one of -p2p-rate or -p2t-rate must be positive")
+	}
+	if this.format != "text" && this.format != "json" {
+		return fmt.Errorf("unknown format %q", this.format)
+	}
+	if this.tokenUrl == "" {
+		if this.peer != "" {
+			return errors.New("-peer requires -token-url, the fake frontend issues its own tokens")
+		}
+		return nil
+	}
+	if this.appKey == "" || this.appSecret == "" {
+		return errors.New("-app-key and -app-secret are required with -token-url")
+	}
+	if this.p2tRate > 0 && this.topicId == 0 {
+		return errors.New("-topic is required for -p2t-rate against a real frontend")
+	}
+	if this.peer != "" {
+		if _, _, err := net.SplitHostPort(this.peer); err != nil {
+			return fmt.Errorf("-peer: %v", err)
+		}
+	}
+	return nil
+}
+
+// -peer指定的前端地址
+type staticPeer struct {
+	peer *frontend.Peer
+}
+
+func (this staticPeer) FetchPeer() *frontend.Peer {
+	return this.peer
+}
+
+func (this *config) peerFetcher() frontend.IFrontendPeerFetcher {
+	if this.peer == "" {
+		return frontend.NewPeerFetcher()
+	}
+	host, port, _ := net.SplitHostPort(this.peer)
+	portNum, _ := strconv.Atoi(port)
+	return staticPeer{new(frontend.Peer).SetHost(host).SetPort(portNum)}
+}
+
+type bench struct {
+	config   *config
+	stats    *stats
+	server   *fake.Server
+	users    []*mimc.MCUser
+	handlers []*handler
+	accounts []string
+	payload  []byte
+	progress io.Writer
+}
+
+func newBench(config *config, progress io.Writer) *bench {
+	this := &bench{config: config, stats: newStats(), progress: progress}
+	for i := 0; i < config.users; i++ {
+		this.accounts = append(this.accounts, config.prefix+strconv.Itoa(i))
+	}
+	this.payload = make([]byte, config.size)
+	for i := range this.payload {
+		this.payload[i] = 'a' + byte(rand.Intn(26))
+	}
+	return this
+}
+
+// 登录、按速率发送、等待未确认的消息，返回最终报告
+func (this *bench) run() (*Report, error) {
+	if err := this.login(); err != nil {
+		return nil, err
+	}
+	defer this.logout()
+	online := this.onlineUsers()
+	if len(online) == 0 {
+		return nil, errors.New("no user is online")
+	}
+
+	stop := make(chan struct{})
+	start := time.Now()
+	var senders sync.WaitGroup
+	for _, index := range online {
+		if this.config.p2pRate > 0 {
+			senders.Add(1)
+			go this.send(index, false, this.config.p2pRate, stop, &senders)
+		}
+		if this.config.p2tRate > 0 {
+			senders.Add(1)
+			go this.send(index, true, this.config.p2tRate, stop, &senders)
+		}
+	}
+	var ticker <-chan time.Time
+	if this.config.interval > 0 {
+		progressTicker := time.NewTicker(this.config.interval)
+		defer progressTicker.Stop()
+		ticker = progressTicker.C
+	}
+	deadline := time.After(this.config.duration)
+	for running := true; running; {
+		select {
+		case <-ticker:
+			fmt.Fprintln(this.progress, this.report(time.Since(start)).progress())
+		case <-deadline:
+			running = false
+		}
+	}
+	close(stop)
+	senders.Wait()
+	elapsed := time.Since(start)
+
+	// SDK在发送超过CHECK_TIMEOUT_TIMEVAL_MS后回调超时，drain应大于该值才能统计到全部超时
+	drainDeadline := time.Now().Add(this.config.drain)
+	for this.draining() && time.Now().Before(drainDeadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	return this.report(elapsed), nil
+}
+
+// 还有消息没有ack，或者fake前端已经投递的消息还没有全部回调到用户
+func (this *bench) draining() bool {
+	if this.stats.outstanding() > 0 {
+		return true
+	}
+	return this.server != nil && atomic.LoadInt64(&this.stats.received) < this.server.Stats().Delivered
+}
+
+func (this *bench) report(elapsed time.Duration) *Report {
+	report := this.stats.report(elapsed)
+	report.Users = this.config.users
+	report.Online = len(this.onlineUsers())
+	report.LoginFailures = report.Users - report.Online
+	if this.server != nil {
+		frontendStats := this.server.Stats()
+		report.Frontend = &frontendStats
+	}
+	return report
+}
+
+func (this *bench) login() error {
+	fetcher := this.config.peerFetcher()
+	if this.config.tokenUrl == "" {
+		this.server = fake.NewServer(fake.Options{
+			AppId:       this.config.appId,
+			AckDelay:    this.config.ackDelay,
+			DropAckRate: this.config.dropAckRate,
+			MaxConnAge:  this.config.maxConnAge,
+		})
+		if err := this.server.Start(""); err != nil {
+			return fmt.Errorf("start fake frontend: %v", err)
+		}
+		if this.config.topicId == 0 {
+			this.config.topicId = 1
+		}
+		this.server.SetTopicMembers(this.config.topicId, this.accounts)
+		fetcher = this.server
+	}
+	// Login会读写可执行文件旁的缓存文件，并发调用不安全，因此逐个登录
+	for _, account := range this.accounts {
+		tokenDelegate, err := this.token(account)
+		if err != nil {
+			return err
+		}
+		handler := &handler{stats: this.stats}
+		user := mimc.NewUser(account).SetMetrics(benchMetrics{this.stats}).SetPeerFetcher(fetcher)
+		user.RegisterTokenDelegate(tokenDelegate).RegisterStatusDelegate(handler).RegisterMessageDelegate(handler).RegisterErrorDelegate(handler)
+		user.InitAndSetup()
+		user.Login()
+		this.users = append(this.users, user)
+		this.handlers = append(this.handlers, handler)
+	}
+	deadline := time.Now().Add(this.config.loginTimeout)
+	for len(this.onlineUsers()) < len(this.users) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
+
+func (this *bench) token(account string) (mimc.Token, error) {
+	if this.server != nil {
+		return this.server.Token(account), nil
+	}
+	provider, err := token.NewProvider(token.Config{
+		Url:        this.config.tokenUrl,
+		AppId:      this.config.appId,
+		AppKey:     this.config.appKey,
+		AppSecret:  this.config.appSecret,
+		AppAccount: account,
+		Timeout:    this.config.loginTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("token provider: %v", err)
+	}
+	return provider, nil
+}
+
+func (this *bench) onlineUsers() []int {
+	var online []int
+	for index, handler := range this.handlers {
+		if handler.isOnline() {
+			online = append(online, index)
+		}
+	}
+	return online
+}
+
+func (this *bench) logout() {
+	for _, user := range this.users {
+		user.Logout()
+	}
+	mimc.Sleep(500)
+	if this.server != nil {
+		this.server.Close()
+	}
+}
+
+// 按rate(条/秒)发送，首条在一个周期内随机延迟，避免所有用户同时发送
+func (this *bench) send(index int, p2t bool, rate float64, stop <-chan struct{}, senders *sync.WaitGroup) {
+	defer senders.Done()
+	period := time.Duration(float64(time.Second) / rate)
+	select {
+	case <-time.After(time.Duration(rand.Int63n(int64(period)))):
+	case <-stop:
+		return
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	user := this.users[index]
+	for {
+		start := time.Now()
+		if p2t {
+			this.stats.sent(user.SendGroupMessage(&this.config.topicId, this.payload), true, start)
+		} else {
+			this.stats.sent(user.SendMessage(this.accounts[this.peerOf(index)], this.payload), false, start)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// 随机选择另一个用户，只有一个用户时发给自己
+func (this *bench) peerOf(index int) int {
+	if len(this.accounts) == 1 {
+		return index
+	}
+	peer := rand.Intn(len(this.accounts) - 1)
+	if peer >= index {
+		peer += 1
+	}
+	return peer
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+const usage = `mimc-bench drives simulated MIMC users and reports throughput, ack latency,
+timeouts and reconnects.
+
+Usage:
+  mimc-bench [flags]
+
+By default the users log in to an in-process fake frontend, which measures the
+SDK itself. With -token-url, -app-id, -app-key and -app-secret the users fetch
+real tokens and connect to the production frontend, or to -peer. Against a
+real frontend, -topic must name an existing topic whose members include the
+bench accounts.
+
+Flags:
+`
+
+func main() {
+	config := new(config)
+	flags := flag.NewFlagSet("mimc-bench", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.IntVar(&config.users, "users", 10, "number of simulated users")
+	flags.StringVar(&config.prefix, "prefix", "bench-", "appAccount prefix, users are named prefix0, prefix1, ...")
+	flags.DurationVar(&config.duration, "duration", 30*time.Second, "how long to send")
+	flags.Float64Var(&config.p2pRate, "p2p-rate", 1, "P2P messages per second per user, sent to random other users")
+	flags.Float64Var(&config.p2tRate, "p2t-rate", 0, "P2T messages per second per user")
+	flags.Int64Var(&config.topicId, "topic", 0, "topic for P2T messages, the fake frontend uses 1 with all users as members")
+	flags.IntVar(&config.size, "size", 128, "payload size in bytes")
+	flags.DurationVar(&config.loginTimeout, "login-timeout", 30*time.Second, "time to wait for all users to be online")
+	flags.DurationVar(&config.drain, "drain", 15*time.Second, "time to wait for outstanding acks after sending stops")
+	flags.DurationVar(&config.interval, "interval", 5*time.Second, "progress report interval on stderr, 0 disables it")
+	flags.StringVar(&config.format, "format", "text", "report format: text or json")
+	flags.StringVar(&config.logPath, "log", "", "write SDK logs to this file, logs are discarded by default")
+	flags.StringVar(&config.peer, "peer", "", "frontend host:port, requires -token-url")
+	flags.StringVar(&config.tokenUrl, "token-url", os.Getenv("MIMC_TOKEN_URL"), "MIMC token API, enables a real frontend (env MIMC_TOKEN_URL)")
+	flags.Int64Var(&config.appId, "app-id", 0, "appId")
+	flags.StringVar(&config.appKey, "app-key", "", "appKey for the MIMC token API")
+	flags.StringVar(&config.appSecret, "app-secret", os.Getenv("MIMC_APP_SECRET"), "appSecret for the MIMC token API (env MIMC_APP_SECRET)")
+	flags.DurationVar(&config.ackDelay, "ack-delay", 0, "fake frontend: delay before acking a message")
+	flags.Float64Var(&config.dropAckRate, "drop-ack-rate", 0, "fake frontend: probability of never acking a message")
+	flags.DurationVar(&config.maxConnAge, "max-conn-age", 0, "fake frontend: close connections after this long to force reconnects")
+	flags.Parse(os.Args[1:])
+
+	if err := config.validate(); err != nil {
+		fail(err)
+	}
+	if config.logPath != "" {
+		if err := log.SetLogPath(config.logPath); err != nil {
+			fail(err)
+		}
+	} else {
+		log.SetLogger(log.NewNopLogger())
+	}
+	report, err := newBench(config, os.Stderr).run()
+	if err != nil {
+		fail(err)
+	}
+	if err := report.write(os.Stdout, config.format); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "mimc-bench: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+func TestHistogram(t *testing.T) {
+	histogram := newHistogram()
+	if histogram.quantile(0.5) != 0 {
+		t.Errorf("empty histogram should report 0")
+	}
+	for i := 1; i <= 100; i++ {
+		histogram.observe(time.Duration(i) * time.Millisecond)
+	}
+	within := func(got, want time.Duration) bool {
+		return got >= want && float64(got) <= float64(want)*histogramGrowth
+	}
+	if p50, p99 := histogram.quantile(0.5), histogram.quantile(0.99); !within(p50, 50*time.Millisecond) || !within(p99, 99*time.Millisecond) {
+		t.Errorf("unexpected quantiles: p50 %v, p99 %v", p50, p99)
+	}
+	if histogram.quantile(1) != 100*time.Millisecond || histogram.max != 100*time.Millisecond {
+		t.Errorf("p100 should be the max, got %v", histogram.quantile(1))
+	}
+}
+
+func TestStatsEarlyAck(t *testing.T) {
+	stats := newStats()
+	stats.ack("p1")
+	stats.sent("p1", false, time.Now().Add(-time.Second))
+	stats.sent("p2", true, time.Now())
+	stats.timeout("p2")
+	stats.sent("", false, time.Now())
+	report := stats.report(time.Second)
+	if report.Acked != 1 || report.Timeouts != 1 || report.SendFailures != 1 || report.Unacked != 0 || report.AckLatency.Max < 900*time.Millisecond {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := config{users: 1, size: 1, p2pRate: 1, format: "text"}
+	if err := valid.validate(); err != nil {
+		t.Errorf("config should be valid: %v", err)
+	}
+	invalid := []config{
+		{users: 1, size: 1, format: "text"},
+		{users: 1, size: 1, p2pRate: 1, format: "text", peer: "127.0.0.1:5222"},
+		{users: 1, size: 1, p2tRate: 1, format: "text", tokenUrl: "http://token", appKey: "k", appSecret: "s"},
+	}
+	for _, config := range invalid {
+		if err := config.validate(); err == nil {
+			t.Errorf("config should be invalid: %+v", config)
+		}
+	}
+}
+
+func TestBenchWithFakeFrontend(t *testing.T) {
+	log.SetLogger(log.NewNopLogger())
+	config := &config{users: 3, prefix: "bench-test-", duration: time.Second, p2pRate: 10, p2tRate: 2, size: 32, loginTimeout: 20 * time.Second, drain: 5 * time.Second, format: "text"}
+	report, err := newBench(config, io.Discard).run()
+	if err != nil {
+		t.Fatalf("bench fail: %v", err)
+	}
+	if report.Online != 3 || report.SentP2P == 0 || report.SentP2T == 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Acked != report.SentP2P+report.SentP2T || report.Timeouts != 0 || report.Unacked != 0 {
+		t.Errorf("all messages should be acked: %+v", report)
+	}
+	// 每条P2T消息投递给另外两个成员
+	if report.Received != report.SentP2P+2*report.SentP2T || report.Frontend == nil || report.Frontend.Binds != 3 {
+		t.Errorf("unexpected deliveries: %+v, frontend %+v", report, report.Frontend)
+	}
+}
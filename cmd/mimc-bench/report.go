@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend/fake"
+)
+
+type Latency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+type Report struct {
+	Users         int           `json:"users"`
+	Online        int           `json:"online"`
+	LoginFailures int           `json:"loginFailures"`
+	Elapsed       time.Duration `json:"elapsed"`
+
+	SentP2P      int64   `json:"sentP2P"`
+	SentP2T      int64   `json:"sentP2T"`
+	SendFailures int64   `json:"sendFailures"`
+	Acked        int64   `json:"acked"`
+	AckRate      float64 `json:"ackRate"`
+	AckLatency   Latency `json:"ackLatency"`
+	Timeouts     int64   `json:"timeouts"`
+	Unacked      int     `json:"unacked"`
+
+	Received      int64   `json:"received"`
+	ReceiveRate   float64 `json:"receiveRate"`
+	ReceivedBytes int64   `json:"receivedBytes"`
+
+	Reconnects    int64 `json:"reconnects"`
+	BindFailures  int64 `json:"bindFailures"`
+	CrcFailures   int64 `json:"crcFailures"`
+	Errors        int64 `json:"errors"`
+	BytesSent     int64 `json:"bytesSent"`
+	BytesReceived int64 `json:"bytesReceived"`
+
+	Frontend *fake.Stats `json:"frontend,omitempty"`
+}
+
+// elapsed为发送阶段的时长，用于计算速率
+func (this *stats) report(elapsed time.Duration) *Report {
+	this.mu.Lock()
+	report := &Report{
+		Elapsed:      elapsed,
+		SentP2P:      this.sentP2P,
+		SentP2T:      this.sentP2T,
+		SendFailures: this.sendFailures,
+		Acked:        this.acked,
+		AckLatency: Latency{
+			P50: this.latency.quantile(0.5),
+			P90: this.latency.quantile(0.9),
+			P99: this.latency.quantile(0.99),
+			Max: this.latency.max,
+		},
+		Timeouts: this.timeouts,
+		Unacked:  len(this.pending),
+	}
+	this.mu.Unlock()
+	report.Received = atomic.LoadInt64(&this.received)
+	report.ReceivedBytes = atomic.LoadInt64(&this.receivedBytes)
+	report.Reconnects = atomic.LoadInt64(&this.reconnects)
+	report.BindFailures = atomic.LoadInt64(&this.bindFailures)
+	report.CrcFailures = atomic.LoadInt64(&this.crcFailures)
+	report.Errors = atomic.LoadInt64(&this.errors)
+	report.BytesSent = atomic.LoadInt64(&this.bytesSent)
+	report.BytesReceived = atomic.LoadInt64(&this.bytesReceived)
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		report.AckRate = float64(report.Acked) / seconds
+		report.ReceiveRate = float64(report.Received) / seconds
+	}
+	return report
+}
+
+// 运行中定期输出的单行进度
+func (this *Report) progress() string {
+	return fmt.Sprintf("%v online=%d sent=%d acked=%d (%.1f/s) p99=%v timeouts=%d received=%d reconnects=%d",
+		this.Elapsed.Truncate(time.Second), this.Online, this.SentP2P+this.SentP2T, this.Acked, this.AckRate,
+		this.AckLatency.P99.Round(time.Microsecond), this.Timeouts, this.Received, this.Reconnects)
+}
+
+func (this *Report) write(out io.Writer, format string) error {
+	if format == "json" {
+		return json.NewEncoder(out).Encode(this)
+	}
+	lines := []string{
+		fmt.Sprintf("users         %d (%d online, %d login failures)", this.Users, this.Online, this.LoginFailures),
+		fmt.Sprintf("duration      %v", this.Elapsed.Truncate(time.Millisecond)),
+		fmt.Sprintf("sent          p2p %d, p2t %d, failed %d", this.SentP2P, this.SentP2T, this.SendFailures),
+		fmt.Sprintf("acked         %d (%.1f/s)", this.Acked, this.AckRate),
+		fmt.Sprintf("ack latency   p50 %v  p90 %v  p99 %v  max %v", this.AckLatency.P50.Round(time.Microsecond), this.AckLatency.P90.Round(time.Microsecond), this.AckLatency.P99.Round(time.Microsecond), this.AckLatency.Max.Round(time.Microsecond)),
+		fmt.Sprintf("timeouts      %d", this.Timeouts),
+		fmt.Sprintf("unacked       %d", this.Unacked),
+		fmt.Sprintf("received      %d (%.1f/s, %d bytes)", this.Received, this.ReceiveRate, this.ReceivedBytes),
+		fmt.Sprintf("reconnects    %d", this.Reconnects),
+		fmt.Sprintf("bind failures %d", this.BindFailures),
+		fmt.Sprintf("crc failures  %d", this.CrcFailures),
+		fmt.Sprintf("errors        %d", this.Errors),
+		fmt.Sprintf("traffic       %d bytes sent, %d bytes received", this.BytesSent, this.BytesReceived),
+	}
+	if this.Frontend != nil {
+		lines = append(lines, fmt.Sprintf("fake frontend %d connections, %d accepted, %d binds, %d messages, %d delivered, %d acks dropped, %d conns expired",
+			this.Frontend.Connections, this.Frontend.Accepted, this.Frontend.Binds, this.Frontend.Messages, this.Frontend.Delivered, this.Frontend.DroppedAcks, this.Frontend.Expired))
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
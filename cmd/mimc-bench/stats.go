@@ -0,0 +1,231 @@
+package main
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+)
+
+// 对数分桶的延迟直方图，相对误差约5%，内存与样本数无关，适合长时间运行
+type histogram struct {
+	counts []int64
+	total  int64
+	max    time.Duration
+}
+
+const histogramGrowth = 1.05
+
+func newHistogram() *histogram {
+	// 1µs到约1小时
+	return &histogram{counts: make([]int64, 450)}
+}
+
+func (this *histogram) observe(latency time.Duration) {
+	index := 0
+	if micros := float64(latency) / float64(time.Microsecond); micros > 1 {
+		index = int(math.Ceil(math.Log(micros) / math.Log(histogramGrowth)))
+	}
+	if index >= len(this.counts) {
+		index = len(this.counts) - 1
+	}
+	this.counts[index] += 1
+	this.total += 1
+	if latency > this.max {
+		this.max = latency
+	}
+}
+
+// 第q分位所在桶的上界，没有样本时返回0
+func (this *histogram) quantile(q float64) time.Duration {
+	if this.total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(this.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for index, count := range this.counts {
+		seen += count
+		if seen >= rank {
+			upper := time.Duration(math.Pow(histogramGrowth, float64(index)) * float64(time.Microsecond))
+			if upper > this.max {
+				return this.max
+			}
+			return upper
+		}
+	}
+	return this.max
+}
+
+/**
+ * 所有模拟用户共享的统计。
+ * 发送时间按packetId记录，收到服务端确认时计算确认延迟；
+ * 确认可能早于SendMessage返回，此时先记下确认时间。
+ */
+type stats struct {
+	mu       sync.Mutex
+	pending  map[string]time.Time
+	early    map[string]time.Time
+	latency  *histogram
+	sentP2P  int64
+	sentP2T  int64
+	acked    int64
+	timeouts int64
+
+	sendFailures  int64
+	received      int64
+	receivedBytes int64
+	errors        int64
+
+	reconnects    int64
+	bindFailures  int64
+	crcFailures   int64
+	bytesSent     int64
+	bytesReceived int64
+}
+
+func newStats() *stats {
+	return &stats{pending: make(map[string]time.Time), early: make(map[string]time.Time), latency: newHistogram()}
+}
+
+func (this *stats) sent(packetId string, p2t bool, start time.Time) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if packetId == "" {
+		this.sendFailures += 1
+		return
+	}
+	if p2t {
+		this.sentP2T += 1
+	} else {
+		this.sentP2P += 1
+	}
+	if ackTime, ok := this.early[packetId]; ok {
+		delete(this.early, packetId)
+		this.acked += 1
+		this.latency.observe(ackTime.Sub(start))
+		return
+	}
+	this.pending[packetId] = start
+}
+
+func (this *stats) ack(packetId string) {
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	start, ok := this.pending[packetId]
+	if !ok {
+		this.early[packetId] = now
+		return
+	}
+	delete(this.pending, packetId)
+	this.acked += 1
+	this.latency.observe(now.Sub(start))
+}
+
+func (this *stats) timeout(packetId string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if _, ok := this.pending[packetId]; ok {
+		delete(this.pending, packetId)
+		this.timeouts += 1
+	}
+}
+
+func (this *stats) outstanding() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return len(this.pending)
+}
+
+func (this *stats) receive(count, bytes int) {
+	atomic.AddInt64(&this.received, int64(count))
+	atomic.AddInt64(&this.receivedBytes, int64(bytes))
+}
+
+// 模拟用户的回调，计入共享的统计
+type handler struct {
+	stats  *stats
+	online int32
+}
+
+func (this *handler) HandleMessage(packets *list.List) {
+	bytes := 0
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		bytes += len(ele.Value.(*msg.P2PMessage).Payload())
+	}
+	this.stats.receive(packets.Len(), bytes)
+}
+
+func (this *handler) HandleGroupMessage(packets *list.List) {
+	bytes := 0
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		bytes += len(ele.Value.(*msg.P2TMessage).Payload())
+	}
+	this.stats.receive(packets.Len(), bytes)
+}
+
+func (this *handler) HandleServerAck(packetId *string, sequence, timestamp *int64) {
+	if packetId != nil {
+		this.stats.ack(*packetId)
+	}
+}
+
+func (this *handler) HandleSendMessageTimeout(message *msg.P2PMessage) {
+	this.stats.timeout(*message.PacketId())
+}
+
+func (this *handler) HandleSendGroupMessageTimeout(message *msg.P2TMessage) {
+	this.stats.timeout(*message.PacketId())
+}
+
+func (this *handler) HandleChange(isOnline bool, errType, errReason, errDescription *string) {
+	if isOnline {
+		atomic.StoreInt32(&this.online, 1)
+	} else {
+		atomic.StoreInt32(&this.online, 0)
+	}
+}
+
+func (this *handler) HandleError(err error) {
+	atomic.AddInt64(&this.stats.errors, 1)
+}
+
+func (this *handler) isOnline() bool {
+	return atomic.LoadInt32(&this.online) == 1
+}
+
+// 实现metrics.Metrics，只记录压测报告需要的计数
+type benchMetrics struct {
+	stats *stats
+}
+
+func (this benchMetrics) SetQueueDepth(appAccount, queue string, depth int)          {}
+func (this benchMetrics) ObserveAckLatency(appAccount string, latency time.Duration) {}
+func (this benchMetrics) IncSendTimeout(appAccount, msgType string)                  {}
+func (this benchMetrics) ObservePingRtt(appAccount string, rtt time.Duration)        {}
+
+func (this benchMetrics) IncReconnect(appAccount string) {
+	atomic.AddInt64(&this.stats.reconnects, 1)
+}
+
+func (this benchMetrics) IncBindFailure(appAccount, errorType string) {
+	atomic.AddInt64(&this.stats.bindFailures, 1)
+}
+
+func (this benchMetrics) IncCrcFailure(appAccount string) {
+	atomic.AddInt64(&this.stats.crcFailures, 1)
+}
+
+func (this benchMetrics) AddBytesSent(appAccount string, n int) {
+	atomic.AddInt64(&this.stats.bytesSent, int64(n))
+}
+
+func (this benchMetrics) AddBytesReceived(appAccount string, n int) {
+	atomic.AddInt64(&this.stats.bytesReceived, int64(n))
+}
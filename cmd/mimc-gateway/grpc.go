@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/gateway"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// gateway.GatewayServer的实现，与HTTP接口共用pool
+type grpcServer struct {
+	pool *pool
+}
+
+func newGrpcServer(pool *pool, authToken string) *grpc.Server {
+	var options []grpc.ServerOption
+	if authToken != "" {
+		options = append(options,
+			grpc.UnaryInterceptor(func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				if err := authorize(ctx, authToken); err != nil {
+					return nil, err
+				}
+				return handler(ctx, request)
+			}),
+			grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := authorize(stream.Context(), authToken); err != nil {
+					return err
+				}
+				return handler(srv, stream)
+			}))
+	}
+	server := grpc.NewServer(options...)
+	gateway.RegisterGatewayServer(server, &grpcServer{pool})
+	return server
+}
+
+// 与HTTP接口相同，校验metadata中的"authorization: Bearer <token>"
+func authorize(ctx context.Context, authToken string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, header := range md.Get("authorization") {
+		if authorized(header, authToken) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func grpcError(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, errInvalidRequest):
+		code = codes.InvalidArgument
+	case errors.Is(err, errUnknownAccount):
+		code = codes.NotFound
+	case errors.Is(err, errLoginTimeout):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, errSendFail), errors.Is(err, errClosed):
+		code = codes.Unavailable
+	default:
+		code = codes.Unknown
+	}
+	return status.Error(code, err.Error())
+}
+
+func (this *grpcServer) Login(ctx context.Context, request *gateway.AccountRequest) (*gateway.UserStatus, error) {
+	response, err := this.pool.login(request.GetAccount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return response, nil
+}
+
+func (this *grpcServer) Logout(ctx context.Context, request *gateway.AccountRequest) (*gateway.UserStatus, error) {
+	response, err := this.pool.logout(request.GetAccount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return response, nil
+}
+
+func (this *grpcServer) Status(ctx context.Context, request *gateway.AccountRequest) (*gateway.StatusResponse, error) {
+	response, err := this.pool.status(request.GetAccount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return response, nil
+}
+
+func (this *grpcServer) Send(ctx context.Context, request *gateway.SendRequest) (*gateway.SendResponse, error) {
+	response, err := this.pool.send(request)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return response, nil
+}
+
+// 订阅者处理不过来被断开时返回ResourceExhausted，客户端需要重新订阅
+func (this *grpcServer) Subscribe(request *gateway.SubscribeRequest, stream gateway.Gateway_SubscribeServer) error {
+	current, err := this.pool.subscribe(request.GetAccounts())
+	if err != nil {
+		return grpcError(err)
+	}
+	defer this.pool.unsubscribe(current)
+	// 先发送header，客户端收到后即可确认订阅已生效
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-current.events:
+			if !ok {
+				if current.overflow {
+					return status.Error(codes.ResourceExhausted, "subscriber is too slow, events were dropped")
+				}
+				return status.Error(codes.Unavailable, errClosed.Error())
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
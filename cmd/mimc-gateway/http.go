@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/gateway"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	maxRequestSize = 16 * 1024 * 1024
+	// SSE连接空闲时发送注释行，避免被代理断开
+	keepAliveInterval = 15 * time.Second
+)
+
+var marshaler = jsonpb.Marshaler{}
+
+/**
+ * HTTP接口，请求和响应为gateway.proto中消息的protobuf JSON编码，
+ * bytes字段为base64，int64字段为字符串。
+ *
+ *   POST /v1/login      AccountRequest -> UserStatus
+ *   POST /v1/logout     AccountRequest -> UserStatus
+ *   GET  /v1/status     ?account=      -> StatusResponse
+ *   POST /v1/send       SendRequest    -> SendResponse
+ *   GET  /v1/subscribe  ?account=...   -> text/event-stream，event为EventType，data为Event
+ *
+ * 错误时响应{"code","message"}。
+ */
+func newHttpHandler(pool *pool, authToken string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/login", func(w http.ResponseWriter, r *http.Request) {
+		request := new(gateway.AccountRequest)
+		if readRequest(w, r, request) {
+			response, err := pool.login(request.GetAccount())
+			writeResponse(w, response, err)
+		}
+	})
+	mux.HandleFunc("/v1/logout", func(w http.ResponseWriter, r *http.Request) {
+		request := new(gateway.AccountRequest)
+		if readRequest(w, r, request) {
+			response, err := pool.logout(request.GetAccount())
+			writeResponse(w, response, err)
+		}
+	})
+	mux.HandleFunc("/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		response, err := pool.status(r.URL.Query().Get("account"))
+		writeResponse(w, response, err)
+	})
+	mux.HandleFunc("/v1/send", func(w http.ResponseWriter, r *http.Request) {
+		request := new(gateway.SendRequest)
+		if readRequest(w, r, request) {
+			response, err := pool.send(request)
+			writeResponse(w, response, err)
+		}
+	})
+	mux.HandleFunc("/v1/subscribe", func(w http.ResponseWriter, r *http.Request) {
+		serveEvents(w, r, pool)
+	})
+	if authToken == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r.Header.Get("Authorization"), authToken) {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// 校验"Bearer <token>"
+func authorized(header, authToken string) bool {
+	expected := "Bearer " + authToken
+	return subtle.ConstantTimeCompare([]byte(header), []byte(expected)) == 1
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request, request proto.Message) bool {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "read body fail")
+		return false
+	}
+	if err := jsonpb.Unmarshal(bytes.NewReader(body), request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeResponse(w http.ResponseWriter, response proto.Message, err error) {
+	// 登录超时时仍返回用户状态
+	if err != nil && !errors.Is(err, errLoginTimeout) {
+		writeError(w, httpStatus(err), err.Error())
+		return
+	}
+	text, marshalErr := marshaler.MarshalToString(response)
+	if marshalErr != nil {
+		writeError(w, http.StatusInternalServerError, marshalErr.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(httpStatus(err))
+	}
+	io.WriteString(w, text)
+}
+
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, errUnknownAccount):
+		return http.StatusNotFound
+	case errors.Is(err, errLoginTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errSendFail), errors.Is(err, errClosed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	body, _ := json.Marshal(map[string]interface{}{"code": statusCode, "message": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// Server-Sent Events，订阅者处理不过来被断开时发送overflow事件后关闭连接
+func serveEvents(w http.ResponseWriter, r *http.Request, pool *pool) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	current, err := pool.subscribe(r.URL.Query()["account"])
+	if err != nil {
+		writeError(w, httpStatus(err), err.Error())
+		return
+	}
+	defer pool.unsubscribe(current)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, ": subscribed\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case event, ok := <-current.events:
+			if !ok {
+				if current.overflow {
+					io.WriteString(w, "event: overflow\ndata: {}\n\n")
+					flusher.Flush()
+				}
+				return
+			}
+			text, err := marshaler.MarshalToString(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %v\ndata: %v\n\n", event.GetType(), text)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend/fake"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/token"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+	"google.golang.org/grpc"
+)
+
+const usage = `mimc-gateway keeps MIMC users logged in and exposes them to other services
+over HTTP (JSON and Server-Sent Events) and gRPC.
+
+Usage:
+  mimc-gateway [flags]
+
+The API is defined in protobuf/gateway/gateway.proto. gRPC clients generate
+stubs from it; the HTTP API uses the protobuf JSON form of the same messages:
+
+  POST /v1/login      {"account":"alice"}
+  POST /v1/logout     {"account":"alice"}
+  GET  /v1/status     [?account=alice]
+  POST /v1/send       {"account":"alice","toAccount":"bob","payload":"<base64>"}
+                      {"account":"alice","topicId":"123","payload":"<base64>"}
+  GET  /v1/subscribe  [?account=alice&account=...]   (text/event-stream)
+
+Subscribers receive MESSAGE, GROUP_MESSAGE, SERVER_ACK, SEND_TIMEOUT,
+SEND_GROUP_TIMEOUT, STATUS_CHANGE and ERROR events. A subscriber that falls
+more than -buffer events behind is disconnected and must subscribe again.
+
+Without -token-url the gateway starts an in-process fake frontend for local
+development, where topic 1 contains all logged-in users.
+
+Flags:
+`
+
+type config struct {
+	httpAddr     string
+	grpcAddr     string
+	accounts     string
+	authToken    string
+	loginTimeout time.Duration
+	buffer       int
+	logPath      string
+
+	peer      string
+	tokenUrl  string
+	appId     int64
+	appKey    string
+	appSecret string
+}
+
+func main() {
+	config := new(config)
+	flags := flag.NewFlagSet("mimc-gateway", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&config.httpAddr, "http", "127.0.0.1:8090", "HTTP listen address, empty disables HTTP")
+	flags.StringVar(&config.grpcAddr, "grpc", "127.0.0.1:8091", "gRPC listen address, empty disables gRPC")
+	flags.StringVar(&config.accounts, "accounts", "", "comma separated appAccounts to log in at startup")
+	flags.StringVar(&config.authToken, "auth-token", os.Getenv("MIMC_GATEWAY_TOKEN"), "require \"Authorization: Bearer <token>\" on every request (env MIMC_GATEWAY_TOKEN)")
+	flags.DurationVar(&config.loginTimeout, "login-timeout", 30*time.Second, "time to wait for a user to be online")
+	flags.IntVar(&config.buffer, "buffer", 1024, "events buffered per subscriber")
+	flags.StringVar(&config.logPath, "log", "", "write SDK logs to this file instead of stderr")
+	flags.StringVar(&config.peer, "peer", "", "frontend host:port, requires -token-url")
+	flags.StringVar(&config.tokenUrl, "token-url", os.Getenv("MIMC_TOKEN_URL"), "MIMC token API, enables a real frontend (env MIMC_TOKEN_URL)")
+	flags.Int64Var(&config.appId, "app-id", 0, "appId")
+	flags.StringVar(&config.appKey, "app-key", "", "appKey for the MIMC token API")
+	flags.StringVar(&config.appSecret, "app-secret", os.Getenv("MIMC_APP_SECRET"), "appSecret for the MIMC token API (env MIMC_APP_SECRET)")
+	flags.Parse(os.Args[1:])
+
+	if err := config.validate(); err != nil {
+		fail(err)
+	}
+	if config.logPath != "" {
+		if err := log.SetLogPath(config.logPath); err != nil {
+			fail(err)
+		}
+	}
+	if err := run(config); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "mimc-gateway: %v\n", err)
+	os.Exit(1)
+}
+
+func (this *config) validate() error {
+	if this.httpAddr == "" && this.grpcAddr == "" {
+		return errors.New("one of -http or -grpc is required")
+	}
+	if this.buffer < 1 {
+		return errors.New("-buffer must be at least 1")
+	}
+	if this.tokenUrl == "" {
+		if this.peer != "" {
+			return errors.New("-peer requires -token-url, the fake frontend issues its own tokens")
+		}
+		return nil
+	}
+	if this.appKey == "" || this.appSecret == "" {
+		return errors.New("-app-key and -app-secret are required with -token-url")
+	}
+	if this.peer != "" {
+		if _, _, err := net.SplitHostPort(this.peer); err != nil {
+			return fmt.Errorf("-peer: %v", err)
+		}
+	}
+	return nil
+}
+
+// -peer指定的前端地址
+type staticPeer struct {
+	peer *frontend.Peer
+}
+
+func (this staticPeer) FetchPeer() *frontend.Peer {
+	return this.peer
+}
+
+// 根据配置创建pool，没有-token-url时返回启动的fake前端，由调用方关闭
+func (this *config) newPool() (*pool, *fake.Server, error) {
+	poolConfig := poolConfig{loginTimeout: this.loginTimeout, buffer: this.buffer}
+	if this.tokenUrl == "" {
+		server := fake.NewServer(fake.Options{AppId: this.appId})
+		if err := server.Start(""); err != nil {
+			return nil, nil, fmt.Errorf("start fake frontend: %v", err)
+		}
+		poolConfig.fetcher = server
+		poolConfig.token = func(account string) (mimc.Token, error) {
+			return server.Token(account), nil
+		}
+		poolConfig.onLogin = func(accounts []string) {
+			server.SetTopicMembers(1, accounts)
+		}
+		return newPool(poolConfig), server, nil
+	}
+	if this.peer != "" {
+		host, port, _ := net.SplitHostPort(this.peer)
+		portNum, _ := strconv.Atoi(port)
+		poolConfig.fetcher = staticPeer{new(frontend.Peer).SetHost(host).SetPort(portNum)}
+	}
+	poolConfig.token = func(account string) (mimc.Token, error) {
+		provider, err := token.NewProvider(token.Config{
+			Url:        this.tokenUrl,
+			AppId:      this.appId,
+			AppKey:     this.appKey,
+			AppSecret:  this.appSecret,
+			AppAccount: account,
+			Timeout:    this.loginTimeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("token provider: %v", err)
+		}
+		return provider, nil
+	}
+	return newPool(poolConfig), nil, nil
+}
+
+func run(config *config) error {
+	pool, fakeServer, err := config.newPool()
+	if err != nil {
+		return err
+	}
+	if fakeServer != nil {
+		defer fakeServer.Close()
+		fmt.Fprintf(os.Stderr, "mimc-gateway: using fake frontend at %v\n", fakeServer.Addr())
+	}
+
+	errs := make(chan error, 2)
+	var httpServer *http.Server
+	if config.httpAddr != "" {
+		listener, err := net.Listen("tcp", config.httpAddr)
+		if err != nil {
+			return err
+		}
+		httpServer = &http.Server{Handler: newHttpHandler(pool, config.authToken)}
+		go func() { errs <- httpServer.Serve(listener) }()
+		fmt.Fprintf(os.Stderr, "mimc-gateway: HTTP listening on %v\n", listener.Addr())
+	}
+	var grpcServer *grpc.Server
+	if config.grpcAddr != "" {
+		listener, err := net.Listen("tcp", config.grpcAddr)
+		if err != nil {
+			return err
+		}
+		grpcServer = newGrpcServer(pool, config.authToken)
+		go func() { errs <- grpcServer.Serve(listener) }()
+		fmt.Fprintf(os.Stderr, "mimc-gateway: gRPC listening on %v\n", listener.Addr())
+	}
+
+	for _, account := range strings.Split(config.accounts, ",") {
+		if account = strings.TrimSpace(account); account == "" {
+			continue
+		}
+		if _, err := pool.login(account); err != nil {
+			fmt.Fprintf(os.Stderr, "mimc-gateway: login %v: %v\n", account, err)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-signals:
+	case err = <-errs:
+	}
+	// 先断开订阅者，否则流式请求会阻塞GracefulStop和Shutdown
+	pool.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if httpServer != nil {
+		httpServer.Shutdown(ctx)
+	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	mimc.Sleep(500)
+	return err
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/gateway"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func postJSON(t *testing.T, url, body string) (int, string) {
+	response, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("post %v fail: %v", url, err)
+	}
+	defer response.Body.Close()
+	data, _ := io.ReadAll(response.Body)
+	return response.StatusCode, string(data)
+}
+
+// 读取SSE流中的事件，忽略注释行
+func readEvents(t *testing.T, reader *bufio.Reader, events chan<- *gateway.Event) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			close(events)
+			return
+		}
+		if data := strings.TrimPrefix(strings.TrimSpace(line), "data: "); data != strings.TrimSpace(line) && data != "{}" {
+			event := new(gateway.Event)
+			if err := jsonpb.UnmarshalString(data, event); err != nil {
+				t.Errorf("invalid event %q: %v", data, err)
+				continue
+			}
+			events <- event
+		}
+	}
+}
+
+// 等待满足match的事件，跳过其它事件
+func waitEvent(t *testing.T, events <-chan *gateway.Event, match func(event *gateway.Event) bool) *gateway.Event {
+	timeout := time.After(15 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("event stream closed")
+			}
+			if match(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("wait event timeout")
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	log.SetLogger(log.NewNopLogger())
+	config := &config{loginTimeout: 20 * time.Second, buffer: 64}
+	pool, fakeServer, err := config.newPool()
+	if err != nil {
+		t.Fatalf("new pool fail: %v", err)
+	}
+	defer fakeServer.Close()
+	defer pool.close()
+	httpServer := httptest.NewServer(newHttpHandler(pool, ""))
+	defer httpServer.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail: %v", err)
+	}
+	grpcServer := newGrpcServer(pool, "")
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	for _, account := range []string{"alice", "bob"} {
+		if code, body := postJSON(t, httpServer.URL+"/v1/login", `{"account":"`+account+`"}`); code != http.StatusOK || !strings.Contains(body, `"online":true`) {
+			t.Fatalf("login %v fail: %v %v", account, code, body)
+		}
+	}
+
+	// bob通过SSE订阅
+	response, err := http.Get(httpServer.URL + "/v1/subscribe?account=bob")
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("subscribe fail: %v, %v", err, response)
+	}
+	defer response.Body.Close()
+	reader := bufio.NewReader(response.Body)
+	if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, ": subscribed") {
+		t.Fatalf("unexpected first line %q", line)
+	}
+	bobEvents := make(chan *gateway.Event, 64)
+	go readEvents(t, reader, bobEvents)
+
+	// alice通过gRPC订阅
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial fail: %v", err)
+	}
+	defer conn.Close()
+	client := gateway.NewGatewayClient(conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Subscribe(ctx, &gateway.SubscribeRequest{Accounts: []string{"alice"}})
+	if err != nil {
+		t.Fatalf("grpc subscribe fail: %v", err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("grpc subscribe header fail: %v", err)
+	}
+	aliceEvents := make(chan *gateway.Event, 64)
+	go func() {
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				close(aliceEvents)
+				return
+			}
+			aliceEvents <- event
+		}
+	}()
+
+	code, body := postJSON(t, httpServer.URL+"/v1/send", `{"account":"alice","toAccount":"bob","payload":"aGVsbG8="}`)
+	sendResponse := new(gateway.SendResponse)
+	if code != http.StatusOK || jsonpb.UnmarshalString(body, sendResponse) != nil || sendResponse.GetPacketId() == "" {
+		t.Fatalf("send fail: %v %v", code, body)
+	}
+	message := waitEvent(t, bobEvents, func(event *gateway.Event) bool { return event.GetType() == gateway.EventType_MESSAGE })
+	if message.GetAccount() != "bob" || message.GetFromAccount() != "alice" || string(message.GetPayload()) != "hello" || message.GetPacketId() != sendResponse.GetPacketId() {
+		t.Errorf("message event mismatch: %v", message)
+	}
+	ack := waitEvent(t, aliceEvents, func(event *gateway.Event) bool { return event.GetType() == gateway.EventType_SERVER_ACK })
+	if ack.GetPacketId() != sendResponse.GetPacketId() || ack.GetSequence() == 0 {
+		t.Errorf("ack event mismatch: %v", ack)
+	}
+
+	groupResponse, err := client.Send(ctx, &gateway.SendRequest{Account: proto.String("bob"), TopicId: proto.Int64(1), Payload: []byte("hi")})
+	if err != nil {
+		t.Fatalf("grpc send fail: %v", err)
+	}
+	group := waitEvent(t, aliceEvents, func(event *gateway.Event) bool { return event.GetType() == gateway.EventType_GROUP_MESSAGE })
+	if group.GetTopicId() != 1 || group.GetFromAccount() != "bob" || group.GetPacketId() != groupResponse.GetPacketId() || string(group.GetPayload()) != "hi" {
+		t.Errorf("group event mismatch: %v", group)
+	}
+
+	statusResponse, err := client.Status(ctx, &gateway.AccountRequest{})
+	if err != nil || len(statusResponse.GetUsers()) != 2 || !statusResponse.GetUsers()[0].GetOnline() {
+		t.Errorf("status mismatch: %v, %v", statusResponse, err)
+	}
+	if _, err := client.Send(ctx, &gateway.SendRequest{Account: proto.String("alice"), ToAccount: proto.String("bob"), TopicId: proto.Int64(1)}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expect InvalidArgument, got %v", err)
+	}
+	if code, _ := postJSON(t, httpServer.URL+"/v1/send", `{"account":"carol","toAccount":"bob"}`); code != http.StatusNotFound {
+		t.Errorf("expect 404 for unknown account, got %v", code)
+	}
+	if _, err := client.Logout(ctx, &gateway.AccountRequest{Account: proto.String("bob")}); err != nil {
+		t.Errorf("logout fail: %v", err)
+	}
+	if _, err := client.Status(ctx, &gateway.AccountRequest{Account: proto.String("bob")}); status.Code(err) != codes.NotFound {
+		t.Errorf("expect NotFound after logout, got %v", err)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	pool := newPool(poolConfig{buffer: 1})
+	slow, _ := pool.subscribe(nil)
+	other, _ := pool.subscribe([]string{"bob"})
+	for i := 0; i < 2; i++ {
+		pool.publish(&gateway.Event{Type: gateway.EventType_MESSAGE.Enum(), Account: proto.String("alice")})
+	}
+	if event := <-slow.events; event.GetAccount() != "alice" {
+		t.Errorf("expect buffered event, got %v", event)
+	}
+	if _, ok := <-slow.events; ok || !slow.overflow {
+		t.Errorf("slow subscriber should be disconnected")
+	}
+	if len(other.events) != 0 {
+		t.Errorf("subscriber of bob should not receive alice's events")
+	}
+	pool.close()
+	if _, ok := <-other.events; ok {
+		t.Errorf("close should disconnect subscribers")
+	}
+	if _, err := pool.subscribe(nil); err != errClosed {
+		t.Errorf("expect errClosed, got %v", err)
+	}
+}
+
+// 指向无人监听的端口，登录会一直超时
+type deadFetcher struct{}
+
+func (this deadFetcher) FetchPeer() *frontend.Peer {
+	return new(frontend.Peer).SetHost("127.0.0.1").SetPort(1)
+}
+
+type emptyToken struct{}
+
+func (this emptyToken) FetchToken() *string {
+	return nil
+}
+
+func TestLogoutAfterLoginTimeout(t *testing.T) {
+	log.SetLogger(log.NewNopLogger())
+	pool := newPool(poolConfig{
+		token:        func(account string) (mimc.Token, error) { return emptyToken{}, nil },
+		fetcher:      deadFetcher{},
+		loginTimeout: 200 * time.Millisecond,
+		buffer:       1,
+	})
+	defer pool.close()
+	baseline := runtime.NumGoroutine()
+	if _, err := pool.login("alice"); err != errLoginTimeout {
+		t.Fatalf("expect errLoginTimeout, got %v", err)
+	}
+	if response, err := pool.logout("alice"); err != nil || response.GetOnline() {
+		t.Fatalf("logout after login timeout fail: %v, %v", response, err)
+	}
+	if _, err := pool.logout("alice"); err != errUnknownAccount {
+		t.Errorf("expect errUnknownAccount after logout, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("routines of the logged out user should exit, %v > %v", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err := pool.login("alice"); err != errLoginTimeout {
+		t.Fatalf("expect errLoginTimeout, got %v", err)
+	}
+	pool.mu.Lock()
+	accounts := pool.accounts()
+	pool.mu.Unlock()
+	if len(accounts) != 1 || accounts[0] != "alice" {
+		t.Errorf("login again should create exactly one session, got %v", accounts)
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	server := httptest.NewServer(newHttpHandler(newPool(poolConfig{buffer: 1}), "secret"))
+	defer server.Close()
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/status", nil)
+	if response, err := http.DefaultClient.Do(request); err != nil || response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expect 401 without token, got %v, %v", response, err)
+	}
+	request.Header.Set("Authorization", "Bearer secret")
+	if response, err := http.DefaultClient.Do(request); err != nil || response.StatusCode != http.StatusOK {
+		t.Errorf("expect 200 with token, got %v, %v", response, err)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/gateway"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	errInvalidRequest = errors.New("invalid request")
+	errUnknownAccount = errors.New("account is not logged in")
+	errLoginTimeout   = errors.New("login timeout, the gateway keeps retrying")
+	errSendFail       = errors.New("send fail, the account is offline or the message is invalid")
+	errClosed         = errors.New("gateway is closing")
+)
+
+type poolConfig struct {
+	token        func(account string) (mimc.Token, error)
+	fetcher      frontend.IFrontendPeerFetcher
+	loginTimeout time.Duration
+	// 每个订阅者缓存的事件数，写满时断开该订阅者
+	buffer int
+	// 用户登录后调用，fake前端用来更新topic成员
+	onLogin func(accounts []string)
+}
+
+/**
+ * 网关中已登录的MCUser，以及事件的订阅者。
+ * 每个用户的回调转换为gateway.Event，推送给订阅了该用户(或全部用户)的订阅者。
+ */
+type pool struct {
+	config poolConfig
+	// Login会读写可执行文件旁的缓存文件，并发调用不安全，因此逐个登录；
+	// 登出也持有loginMu，避免同一account在登出过程中被重新登录
+	loginMu sync.Mutex
+
+	mu          sync.Mutex
+	sessions    map[string]*session
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+func newPool(config poolConfig) *pool {
+	return &pool{config: config, sessions: make(map[string]*session), subscribers: make(map[*subscriber]struct{})}
+}
+
+/**
+ * 登录account并等待上线，已登录时直接返回状态。
+ * 超时返回errLoginTimeout，用户仍保留在网关中，SDK会继续重试，需要时调用logout移除并停止重试。
+ */
+func (this *pool) login(account string) (*gateway.UserStatus, error) {
+	if account == "" {
+		return nil, errInvalidRequest
+	}
+	this.loginMu.Lock()
+	this.mu.Lock()
+	if this.closed {
+		this.mu.Unlock()
+		this.loginMu.Unlock()
+		return nil, errClosed
+	}
+	current, ok := this.sessions[account]
+	this.mu.Unlock()
+	if !ok {
+		var err error
+		if current, err = this.newSession(account); err != nil {
+			this.loginMu.Unlock()
+			return nil, err
+		}
+		this.mu.Lock()
+		this.sessions[account] = current
+		accounts := this.accounts()
+		this.mu.Unlock()
+		if this.config.onLogin != nil {
+			this.config.onLogin(accounts)
+		}
+		current.user.Login()
+	}
+	this.loginMu.Unlock()
+
+	deadline := time.Now().Add(this.config.loginTimeout)
+	for current.user.Status() != mimc.Online {
+		if time.Now().After(deadline) {
+			return current.status(), errLoginTimeout
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return current.status(), nil
+}
+
+func (this *pool) newSession(account string) (*session, error) {
+	token, err := this.config.token(account)
+	if err != nil {
+		return nil, err
+	}
+	current := &session{account: account, pool: this}
+	current.user = mimc.NewUser(account)
+	if this.config.fetcher != nil {
+		current.user.SetPeerFetcher(this.config.fetcher)
+	}
+	current.user.RegisterTokenDelegate(token).RegisterStatusDelegate(current).RegisterMessageDelegate(current).RegisterErrorDelegate(current)
+	current.user.InitAndSetup()
+	return current, nil
+}
+
+/**
+ * 登出并移除account，订阅者保持订阅。
+ * 使用Close而不是Logout，登录超时仍在重试的用户同样会停止重连并退出goroutine。
+ */
+func (this *pool) logout(account string) (*gateway.UserStatus, error) {
+	this.loginMu.Lock()
+	defer this.loginMu.Unlock()
+	this.mu.Lock()
+	current, ok := this.sessions[account]
+	this.mu.Unlock()
+	if !ok {
+		return nil, errUnknownAccount
+	}
+	closed := current.user.Close()
+	this.mu.Lock()
+	delete(this.sessions, account)
+	this.mu.Unlock()
+	if !closed {
+		// 已经被关闭的用户等同于不在网关中
+		return nil, errUnknownAccount
+	}
+	return &gateway.UserStatus{Account: proto.String(account), Online: proto.Bool(false)}, nil
+}
+
+// account为空时返回所有用户的状态
+func (this *pool) status(account string) (*gateway.StatusResponse, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	response := new(gateway.StatusResponse)
+	if account != "" {
+		current, ok := this.sessions[account]
+		if !ok {
+			return nil, errUnknownAccount
+		}
+		response.Users = append(response.Users, current.status())
+		return response, nil
+	}
+	for _, name := range this.accounts() {
+		response.Users = append(response.Users, this.sessions[name].status())
+	}
+	return response, nil
+}
+
+func (this *pool) send(request *gateway.SendRequest) (*gateway.SendResponse, error) {
+	if (request.GetToAccount() == "") == (request.TopicId == nil) {
+		return nil, errInvalidRequest
+	}
+	this.mu.Lock()
+	current, ok := this.sessions[request.GetAccount()]
+	this.mu.Unlock()
+	if !ok {
+		return nil, errUnknownAccount
+	}
+	var packetId string
+	user := current.user
+	if request.TopicId != nil {
+		topicId := request.GetTopicId()
+		if request.GetTransient() {
+			packetId = user.SendTransientGroupMessage(&topicId, request.Payload)
+		} else {
+			packetId = user.SendGroupMessage(&topicId, request.Payload)
+		}
+	} else if request.GetTransient() {
+		packetId = user.SendTransientMessage(request.GetToAccount(), request.Payload)
+	} else {
+		packetId = user.SendMessage(request.GetToAccount(), request.Payload)
+	}
+	if packetId == "" {
+		return nil, errSendFail
+	}
+	return &gateway.SendResponse{PacketId: proto.String(packetId)}, nil
+}
+
+// 已登录的account，按名称排序，调用方持有mu
+func (this *pool) accounts() []string {
+	accounts := make([]string, 0, len(this.sessions))
+	for account := range this.sessions {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+/**
+ * 订阅accounts的事件，accounts为空时订阅所有用户，包括之后登录的用户。
+ * 返回的订阅者写满时被断开，events被关闭且overflow为true。
+ */
+func (this *pool) subscribe(accounts []string) (*subscriber, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.closed {
+		return nil, errClosed
+	}
+	current := &subscriber{events: make(chan *gateway.Event, this.config.buffer)}
+	if len(accounts) > 0 {
+		current.accounts = make(map[string]bool)
+		for _, account := range accounts {
+			current.accounts[account] = true
+		}
+	}
+	this.subscribers[current] = struct{}{}
+	return current, nil
+}
+
+func (this *pool) unsubscribe(current *subscriber) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if _, ok := this.subscribers[current]; ok {
+		delete(this.subscribers, current)
+		close(current.events)
+	}
+}
+
+func (this *pool) publish(event *gateway.Event) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for current := range this.subscribers {
+		if current.accounts != nil && !current.accounts[event.GetAccount()] {
+			continue
+		}
+		select {
+		case current.events <- event:
+		default:
+			// 不阻塞SDK的回调，断开处理不过来的订阅者
+			current.overflow = true
+			delete(this.subscribers, current)
+			close(current.events)
+		}
+	}
+}
+
+// 断开所有订阅者并关闭所有用户
+func (this *pool) close() {
+	this.loginMu.Lock()
+	defer this.loginMu.Unlock()
+	this.mu.Lock()
+	this.closed = true
+	for current := range this.subscribers {
+		delete(this.subscribers, current)
+		close(current.events)
+	}
+	sessions := this.sessions
+	this.sessions = make(map[string]*session)
+	this.mu.Unlock()
+	for account, current := range sessions {
+		if !current.user.Close() {
+			log.GetLogger().Warn("[gateway] close %v fail, it was already closed.", account)
+		}
+	}
+}
+
+type subscriber struct {
+	accounts map[string]bool
+	events   chan *gateway.Event
+	// 在events关闭前写入，读到events关闭后可以读取
+	overflow bool
+}
+
+// 网关中的一个用户，把MCUser的回调转换为事件
+type session struct {
+	account string
+	user    *mimc.MCUser
+	pool    *pool
+}
+
+func (this *session) status() *gateway.UserStatus {
+	return &gateway.UserStatus{
+		Account:  proto.String(this.account),
+		Online:   proto.Bool(this.user.Status() == mimc.Online),
+		Uuid:     proto.Int64(this.user.Uuid()),
+		Resource: proto.String(this.user.Resource()),
+	}
+}
+
+func (this *session) p2pEvent(eventType gateway.EventType, message *msg.P2PMessage) *gateway.Event {
+	return &gateway.Event{
+		Type:        eventType.Enum(),
+		Account:     proto.String(this.account),
+		PacketId:    message.PacketId(),
+		Sequence:    message.Sequence(),
+		Timestamp:   message.Timestamp(),
+		FromAccount: message.FromAccount(),
+		ToAccount:   message.ToAccount(),
+		Payload:     message.Payload(),
+		Transient:   proto.Bool(message.IsTransient()),
+	}
+}
+
+func (this *session) p2tEvent(eventType gateway.EventType, message *msg.P2TMessage) *gateway.Event {
+	return &gateway.Event{
+		Type:        eventType.Enum(),
+		Account:     proto.String(this.account),
+		PacketId:    message.PacketId(),
+		Sequence:    message.Sequence(),
+		Timestamp:   message.Timestamp(),
+		FromAccount: message.FromAccount(),
+		TopicId:     message.GroupId(),
+		Payload:     message.Payload(),
+		Transient:   proto.Bool(message.IsTransient()),
+	}
+}
+
+func (this *session) HandleMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.pool.publish(this.p2pEvent(gateway.EventType_MESSAGE, ele.Value.(*msg.P2PMessage)))
+	}
+}
+
+func (this *session) HandleGroupMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.pool.publish(this.p2tEvent(gateway.EventType_GROUP_MESSAGE, ele.Value.(*msg.P2TMessage)))
+	}
+}
+
+func (this *session) HandleServerAck(packetId *string, sequence, timestamp *int64) {
+	this.pool.publish(&gateway.Event{
+		Type:      gateway.EventType_SERVER_ACK.Enum(),
+		Account:   proto.String(this.account),
+		PacketId:  packetId,
+		Sequence:  sequence,
+		Timestamp: timestamp,
+	})
+}
+
+func (this *session) HandleSendMessageTimeout(message *msg.P2PMessage) {
+	this.pool.publish(this.p2pEvent(gateway.EventType_SEND_TIMEOUT, message))
+}
+
+func (this *session) HandleSendGroupMessageTimeout(message *msg.P2TMessage) {
+	this.pool.publish(this.p2tEvent(gateway.EventType_SEND_GROUP_TIMEOUT, message))
+}
+
+func (this *session) HandleChange(isOnline bool, errType, errReason, errDescription *string) {
+	this.pool.publish(&gateway.Event{
+		Type:        gateway.EventType_STATUS_CHANGE.Enum(),
+		Account:     proto.String(this.account),
+		Online:      proto.Bool(isOnline),
+		ErrorType:   errType,
+		ErrorReason: errReason,
+		ErrorDesc:   errDescription,
+	})
+}
+
+func (this *session) HandleError(err error) {
+	this.pool.publish(&gateway.Event{
+		Type:      gateway.EventType_ERROR.Enum(),
+		Account:   proto.String(this.account),
+		ErrorDesc: proto.String(err.Error()),
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/inspect"
+)
+
+const usage = `mimc-inspect decodes captured MIMC V6 traffic.
+
+Usage:
+  mimc-inspect [flags] [file]
+
+The input is read from file, or stdin when no file is given. It is a raw TCP
+byte stream of one direction, a hex dump of such a stream with -hex, or a
+session file (JSON lines) with -session. The body key is learned from CONN
+frames when -udid and -challenge are not given.
+
+Flags:
+`
+
+func main() {
+	var keys inspect.Keys
+	var hexInput, session bool
+	var direction, format string
+	flags := flag.NewFlagSet("mimc-inspect", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&keys.Udid, "udid", "", "udid of the connection")
+	flags.StringVar(&keys.Challenge, "challenge", "", "challenge from the CONN response")
+	flags.StringVar(&keys.SecKey, "seckey", os.Getenv("MIMC_SECKEY"), "miUserSecurityKey from the token response, decrypts SECMSG payloads (env MIMC_SECKEY)")
+	flags.BoolVar(&hexInput, "hex", false, "input is a hex dump")
+	flags.BoolVar(&session, "session", false, "input is a session file")
+	flags.StringVar(&direction, "dir", "", "direction of a raw stream: in (server to client) or out")
+	flags.StringVar(&format, "format", "text", "output format: text or json")
+	flags.Parse(os.Args[1:])
+
+	if direction != "" && direction != inspect.DIRECTION_IN && direction != inspect.DIRECTION_OUT {
+		fail(fmt.Errorf("unknown direction %q", direction))
+	}
+	if format != "text" && format != "json" {
+		fail(fmt.Errorf("unknown format %q", format))
+	}
+	var input io.Reader = os.Stdin
+	if flags.NArg() > 0 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fail(err)
+		}
+		defer file.Close()
+		input = file
+	}
+	if hexInput {
+		text, err := io.ReadAll(input)
+		if err == nil {
+			var data []byte
+			if data, err = inspect.ParseHex(text); err == nil {
+				input = bytes.NewReader(data)
+			}
+		}
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	output := func(frame *inspect.Frame) error {
+		if format == "json" {
+			return encoder.Encode(frame)
+		}
+		_, err := fmt.Println(frame.Text())
+		return err
+	}
+	decoder := inspect.NewDecoder(keys)
+	var err error
+	if session {
+		err = inspect.ReadSession(input, decoder, output)
+	} else {
+		err = decoder.DecodeStream(input, direction, output)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "mimc-inspect: %v\n", err)
+	os.Exit(1)
+}
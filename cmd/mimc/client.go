@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/token"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+// 所有子命令共用的参数
+type options struct {
+	account   string
+	tokenFile string
+	tokenUrl  string
+	auth      string
+	appId     int64
+	appKey    string
+	appSecret string
+	timeout   time.Duration
+	format    string
+	logPath   string
+}
+
+func (this *options) register(flags *flag.FlagSet) {
+	flags.StringVar(&this.account, "account", os.Getenv("MIMC_ACCOUNT"), "appAccount to log in as (env MIMC_ACCOUNT)")
+	flags.StringVar(&this.tokenFile, "token-file", "", "file containing a token response, as saved by \"mimc login -save\"")
+	flags.StringVar(&this.tokenUrl, "token-url", os.Getenv("MIMC_TOKEN_URL"), "token endpoint: the MIMC token API with -app-key, or an app token server (env MIMC_TOKEN_URL)")
+	flags.StringVar(&this.auth, "auth", os.Getenv("MIMC_AUTH"), "Authorization header sent to an app token server (env MIMC_AUTH)")
+	flags.Int64Var(&this.appId, "app-id", 0, "appId, required with -app-key")
+	flags.StringVar(&this.appKey, "app-key", "", "appKey for the MIMC token API")
+	flags.StringVar(&this.appSecret, "app-secret", os.Getenv("MIMC_APP_SECRET"), "appSecret for the MIMC token API (env MIMC_APP_SECRET)")
+	flags.DurationVar(&this.timeout, "timeout", 15*time.Second, "time to wait for login")
+	flags.StringVar(&this.format, "format", "text", "output format: text or json")
+	flags.StringVar(&this.logPath, "log", "", "write SDK logs to this file, logs are discarded by default")
+}
+
+func (this *options) validate() error {
+	if this.account == "" {
+		return errors.New("-account is required")
+	}
+	if this.tokenFile == "" && this.tokenUrl == "" {
+		return errors.New("one of -token-file or -token-url is required")
+	}
+	if this.format != "text" && this.format != "json" {
+		return fmt.Errorf("unknown format %q", this.format)
+	}
+	return nil
+}
+
+type tokenSource interface {
+	mimc.Token
+	lastError() error
+}
+
+// 从文件读取token响应
+type fileToken struct {
+	path string
+	err  error
+}
+
+func (this *fileToken) FetchToken() *string {
+	data, err := os.ReadFile(this.path)
+	if this.err = err; err != nil {
+		return nil
+	}
+	token := strings.TrimSpace(string(data))
+	return &token
+}
+
+func (this *fileToken) lastError() error {
+	return this.err
+}
+
+// 从应用自己的token服务获取token响应，appSecret不需要出现在客户端
+type serverToken struct {
+	url        string
+	auth       string
+	account    string
+	httpClient *http.Client
+	err        error
+}
+
+func (this *serverToken) FetchToken() *string {
+	body, _ := json.Marshal(map[string]string{"appAccount": this.account})
+	request, err := http.NewRequest(http.MethodPost, this.url, bytes.NewReader(body))
+	if err != nil {
+		this.err = err
+		return nil
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if this.auth != "" {
+		request.Header.Set("Authorization", this.auth)
+	}
+	response, err := this.httpClient.Do(request)
+	if err != nil {
+		this.err = err
+		return nil
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err == nil && response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("token server returned %v: %v", response.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if this.err = err; err != nil {
+		return nil
+	}
+	token := string(data)
+	return &token
+}
+
+func (this *serverToken) lastError() error {
+	return this.err
+}
+
+type providerToken struct {
+	*token.Provider
+}
+
+func (this providerToken) lastError() error {
+	return this.LastError()
+}
+
+func (this *options) tokenSource() (tokenSource, error) {
+	if this.tokenFile != "" {
+		return &fileToken{path: this.tokenFile}, nil
+	}
+	if this.appKey == "" {
+		return &serverToken{url: this.tokenUrl, auth: this.auth, account: this.account, httpClient: &http.Client{Timeout: this.timeout}}, nil
+	}
+	provider, err := token.NewProvider(token.Config{
+		Url:        this.tokenUrl,
+		AppId:      this.appId,
+		AppKey:     this.appKey,
+		AppSecret:  this.appSecret,
+		AppAccount: this.account,
+		Timeout:    this.timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("token provider: %v", err)
+	}
+	return providerToken{provider}, nil
+}
+
+type ack struct {
+	packetId string
+	sequence int64
+	timeout  bool
+}
+
+// 把收到的消息交给printer，发送结果写入acks
+type handler struct {
+	printer  *printer
+	acks     chan ack
+	received chan struct{}
+}
+
+func newHandler(printer *printer) *handler {
+	return &handler{printer: printer, acks: make(chan ack, 1024), received: make(chan struct{}, 1024)}
+}
+
+func (this *handler) HandleMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.printer.printP2P(ele.Value.(*msg.P2PMessage))
+		this.notifyReceived()
+	}
+}
+
+func (this *handler) HandleGroupMessage(packets *list.List) {
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.printer.printP2T(ele.Value.(*msg.P2TMessage))
+		this.notifyReceived()
+	}
+}
+
+func (this *handler) notifyReceived() {
+	select {
+	case this.received <- struct{}{}:
+	default:
+	}
+}
+
+func (this *handler) HandleServerAck(packetId *string, sequence, timestamp *int64) {
+	if packetId == nil {
+		return
+	}
+	result := ack{packetId: *packetId}
+	if sequence != nil {
+		result.sequence = *sequence
+	}
+	this.sendAck(result)
+}
+
+func (this *handler) HandleSendMessageTimeout(message *msg.P2PMessage) {
+	this.sendAck(ack{packetId: *message.PacketId(), timeout: true})
+}
+
+func (this *handler) HandleSendGroupMessageTimeout(message *msg.P2TMessage) {
+	this.sendAck(ack{packetId: *message.PacketId(), timeout: true})
+}
+
+// 不阻塞SDK的回调goroutine，acks写满时丢弃结果并提示
+func (this *handler) sendAck(result ack) {
+	select {
+	case this.acks <- result:
+	default:
+		this.printer.status("dropped send result of %v, too many results pending", result.packetId)
+	}
+}
+
+func (this *handler) HandleChange(isOnline bool, errType, errReason, errDescription *string) {
+	if !isOnline && errType != nil {
+		this.printer.status("login failed: %v %v", *errType, value(errDescription))
+	}
+}
+
+func (this *handler) HandleError(err error) {
+	this.printer.status("error: %v", err)
+}
+
+func value(str *string) string {
+	if str == nil {
+		return ""
+	}
+	return *str
+}
+
+// 创建用户并登录，等待连接建立后返回
+func login(opts *options, printer *printer) (*mimc.MCUser, *handler, error) {
+	if opts.logPath != "" {
+		if err := log.SetLogPath(opts.logPath); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		log.SetLogger(log.NewNopLogger())
+	}
+	source, err := opts.tokenSource()
+	if err != nil {
+		return nil, nil, err
+	}
+	handler := newHandler(printer)
+	user := mimc.NewUser(opts.account)
+	user.RegisterTokenDelegate(source).RegisterStatusDelegate(handler).RegisterMessageDelegate(handler).RegisterErrorDelegate(handler).InitAndSetup()
+	if !user.Login() {
+		if err := source.lastError(); err != nil {
+			return nil, nil, fmt.Errorf("fetch token: %v", err)
+		}
+		return nil, nil, errors.New("login failed, check the token response")
+	}
+	deadline := time.Now().Add(opts.timeout)
+	for user.Status() != mimc.Online {
+		if time.Now().After(deadline) {
+			user.Logout()
+			return nil, nil, fmt.Errorf("not online after %v", opts.timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return user, handler, nil
+}
+
+func logout(user *mimc.MCUser) {
+	user.Logout()
+	mimc.Sleep(500)
+}
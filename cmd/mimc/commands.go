@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+)
+
+func parse(name string, args []string, opts *options, register func(flags *flag.FlagSet)) (*flag.FlagSet, error) {
+	flags := flag.NewFlagSet("mimc "+name, flag.ContinueOnError)
+	opts.register(flags)
+	if register != nil {
+		register(flags)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	return flags, opts.validate()
+}
+
+func runLogin(args []string) error {
+	opts := new(options)
+	var save string
+	if _, err := parse("login", args, opts, func(flags *flag.FlagSet) {
+		flags.StringVar(&save, "save", "", "save the token response to this file for -token-file")
+	}); err != nil {
+		return err
+	}
+	printer := newPrinter(os.Stdout, os.Stderr, opts.format)
+	user, _, err := login(opts, printer)
+	if err != nil {
+		return err
+	}
+	defer logout(user)
+	if save != "" {
+		if err := os.WriteFile(save, []byte(value(user.Token())), 0600); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("online as %v, appId: %v, uuid: %v, resource: %v\n", user.AppAccount(), user.AppId(), user.Uuid(), user.Resource())
+	return nil
+}
+
+// 发送目标，to和topic只能指定一个
+type target struct {
+	to    string
+	topic int64
+}
+
+func (this *target) register(flags *flag.FlagSet) {
+	flags.StringVar(&this.to, "to", "", "send P2P messages to this appAccount")
+	flags.Int64Var(&this.topic, "topic", 0, "send P2T messages to this topicId")
+}
+
+func (this *target) validate() error {
+	if (this.to == "") == (this.topic == 0) {
+		return errors.New("exactly one of -to or -topic is required")
+	}
+	return nil
+}
+
+func (this *target) send(user *mimc.MCUser, text string) string {
+	if this.to != "" {
+		return user.SendMessage(this.to, []byte(text))
+	}
+	return user.SendGroupMessage(&this.topic, []byte(text))
+}
+
+func runSend(args []string) error {
+	opts, target := new(options), new(target)
+	var wait time.Duration
+	flags, err := parse("send", args, opts, func(flags *flag.FlagSet) {
+		target.register(flags)
+		flags.DurationVar(&wait, "wait", 10*time.Second, "time to wait for server acks")
+	})
+	if err != nil {
+		return err
+	}
+	if err := target.validate(); err != nil {
+		return err
+	}
+	var texts []string
+	if flags.NArg() > 0 {
+		texts = []string{strings.Join(flags.Args(), " ")}
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			texts = append(texts, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	printer := newPrinter(os.Stdout, os.Stderr, opts.format)
+	user, handler, err := login(opts, printer)
+	if err != nil {
+		return err
+	}
+	defer logout(user)
+
+	pending := make(map[string]bool)
+	for _, text := range texts {
+		if packetId := target.send(user, text); packetId != "" {
+			pending[packetId] = true
+		} else {
+			printer.status("send fail: %q", text)
+		}
+	}
+	failed := len(texts) - len(pending)
+	deadline := time.After(wait)
+	for len(pending) > 0 {
+		select {
+		case result := <-handler.acks:
+			if !pending[result.packetId] {
+				continue
+			}
+			delete(pending, result.packetId)
+			if result.timeout {
+				failed += 1
+				printer.status("timeout: %v", result.packetId)
+			} else {
+				printer.status("sent: %v, sequence: %v", result.packetId, result.sequence)
+			}
+		case <-deadline:
+			failed += len(pending)
+			for packetId := range pending {
+				printer.status("no ack: %v", packetId)
+			}
+			pending = nil
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v of %v messages failed", failed, len(texts))
+	}
+	return nil
+}
+
+func runListen(args []string) error {
+	opts := new(options)
+	var count int
+	if _, err := parse("listen", args, opts, func(flags *flag.FlagSet) {
+		flags.IntVar(&count, "count", 0, "exit after receiving this many messages, 0 means forever")
+	}); err != nil {
+		return err
+	}
+	printer := newPrinter(os.Stdout, os.Stderr, opts.format)
+	user, handler, err := login(opts, printer)
+	if err != nil {
+		return err
+	}
+	defer logout(user)
+	printer.status("listening as %v, press Ctrl-C to exit", opts.account)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	for received := 0; count <= 0 || received < count; {
+		select {
+		case <-handler.received:
+			received += 1
+		case <-interrupt:
+			return nil
+		}
+	}
+	return nil
+}
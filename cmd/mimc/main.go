@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `mimc is a command-line client for MIMC.
+
+Usage:
+  mimc <command> [flags] [arguments]
+
+Commands:
+  login    fetch a token, log in and print the account information
+  send     send P2P or P2T messages from arguments or stdin
+  listen   print incoming messages until interrupted
+  repl     interactive chat
+
+Run "mimc <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "login":
+		err = runLogin(args)
+	case "send":
+		err = runSend(args)
+	case "listen":
+		err = runListen(args)
+	case "repl":
+		err = runRepl(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "mimc: unknown command %q\n\n%v", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mimc: %v\n", err)
+		os.Exit(1)
+	}
+}
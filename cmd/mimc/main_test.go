@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/envelope"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+)
+
+func TestPrinter(t *testing.T) {
+	packetId, from, to, sequence, timestamp := "p1", "Bob", "Alice", int64(3), int64(1700000000000)
+	payload, _ := envelope.Encode(envelope.NewText("hello"))
+	out := new(bytes.Buffer)
+	newPrinter(out, out, "json").printP2P(msg.NewP2pMsg(&packetId, &from, &to, &sequence, &timestamp, payload))
+	if line := out.String(); !strings.Contains(line, `"text":"hello"`) || !strings.Contains(line, `"contentType":"text/plain"`) || !strings.HasSuffix(line, "}\n") {
+		t.Errorf("unexpected json line: %v", line)
+	}
+
+	out.Reset()
+	topicId := int64(100)
+	newPrinter(out, out, "text").printP2T(msg.NewP2tMsg(&packetId, &from, &sequence, &timestamp, &topicId, []byte{0xff, 0xfe}))
+	if line := out.String(); !strings.Contains(line, "Bob -> topic 100: <2 bytes binary>") {
+		t.Errorf("unexpected text line: %v", line)
+	}
+}
+
+func TestReplCommands(t *testing.T) {
+	out := new(bytes.Buffer)
+	r := &repl{printer: newPrinter(out, out, "text")}
+	r.handle("hello")
+	r.handle("/topic 42")
+	if r.target.topic != 42 || r.target.to != "" {
+		t.Errorf("unexpected target: %+v", r.target)
+	}
+	r.handle("/to Bob")
+	if r.target.to != "Bob" || r.target.topic != 0 {
+		t.Errorf("unexpected target: %+v", r.target)
+	}
+	if r.handle("/quit") {
+		t.Errorf("/quit should exit")
+	}
+	if !strings.Contains(out.String(), "no chat selected") {
+		t.Errorf("unexpected output: %v", out.String())
+	}
+}
+
+func TestStatusAndDroppedAcks(t *testing.T) {
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	handler := &handler{printer: newPrinter(out, errOut, "text"), acks: make(chan ack, 1)}
+	handler.sendAck(ack{packetId: "p1"})
+	handler.sendAck(ack{packetId: "p2"})
+	if out.Len() != 0 || !strings.Contains(errOut.String(), "dropped send result of p2") {
+		t.Errorf("dropped ack should be reported on errOut, out: %q, errOut: %q", out.String(), errOut.String())
+	}
+	if result := <-handler.acks; result.packetId != "p1" {
+		t.Errorf("first ack should be kept, got %v", result.packetId)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+)
+
+// 收到的消息的JSON输出格式，每条消息一行
+type record struct {
+	Type        string `json:"type"`
+	PacketId    string `json:"packetId"`
+	From        string `json:"from"`
+	To          string `json:"to,omitempty"`
+	TopicId     int64  `json:"topicId,omitempty"`
+	Sequence    int64  `json:"sequence"`
+	Timestamp   int64  `json:"timestamp"`
+	ContentType string `json:"contentType,omitempty"`
+	Text        string `json:"text,omitempty"`
+	Base64      []byte `json:"base64,omitempty"`
+}
+
+// 按text或json格式把消息输出到out，状态信息总是输出到errOut
+type printer struct {
+	mu     sync.Mutex
+	out    io.Writer
+	errOut io.Writer
+	json   bool
+}
+
+func newPrinter(out, errOut io.Writer, format string) *printer {
+	return &printer{out: out, errOut: errOut, json: format == "json"}
+}
+
+func (this *printer) printP2P(message *msg.P2PMessage) {
+	this.print(&record{
+		Type:        "p2p",
+		PacketId:    value(message.PacketId()),
+		From:        value(message.FromAccount()),
+		To:          value(message.ToAccount()),
+		Sequence:    int64Value(message.Sequence()),
+		Timestamp:   int64Value(message.Timestamp()),
+		ContentType: message.ContentType(),
+	}, message.Body())
+}
+
+func (this *printer) printP2T(message *msg.P2TMessage) {
+	this.print(&record{
+		Type:        "p2t",
+		PacketId:    value(message.PacketId()),
+		From:        value(message.FromAccount()),
+		TopicId:     int64Value(message.GroupId()),
+		Sequence:    int64Value(message.Sequence()),
+		Timestamp:   int64Value(message.Timestamp()),
+		ContentType: message.ContentType(),
+	}, message.Body())
+}
+
+func (this *printer) print(r *record, body []byte) {
+	if utf8.Valid(body) {
+		r.Text = string(body)
+	} else {
+		r.Base64 = body
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	fmt.Fprintln(this.out, this.format(r))
+}
+
+func (this *printer) format(r *record) string {
+	if this.json {
+		data, _ := json.Marshal(r)
+		return string(data)
+	}
+	target := r.To
+	if r.Type == "p2t" {
+		target = fmt.Sprintf("topic %v", r.TopicId)
+	}
+	content := r.Text
+	if r.Base64 != nil {
+		content = fmt.Sprintf("<%v bytes binary>", len(r.Base64))
+	}
+	return fmt.Sprintf("%v %v -> %v: %v", time.UnixMilli(r.Timestamp).Format("2006-01-02 15:04:05"), r.From, target, content)
+}
+
+// 状态信息输出到errOut，避免混入消息流
+func (this *printer) status(format string, args ...interface{}) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	fmt.Fprintf(this.errOut, format+"\n", args...)
+}
+
+func int64Value(value *int64) int64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+)
+
+const replHelp = `commands:
+  /to <appAccount>   chat with an account
+  /topic <topicId>   chat in a topic
+  /help              show this help
+  /quit              log out and exit
+other lines are sent to the current chat`
+
+// 交互式聊天，收到的消息和发送结果异步输出
+type repl struct {
+	user    *mimc.MCUser
+	printer *printer
+	target  target
+}
+
+// 处理一行输入，返回false表示退出
+func (this *repl) handle(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	if !strings.HasPrefix(line, "/") {
+		if this.target.validate() != nil {
+			this.printer.status("no chat selected, use /to or /topic")
+			return true
+		}
+		if this.target.send(this.user, line) == "" {
+			this.printer.status("send fail")
+		}
+		return true
+	}
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "/to":
+		if len(fields) != 2 {
+			this.printer.status("usage: /to <appAccount>")
+			break
+		}
+		this.target = target{to: fields[1]}
+		this.printer.status("chatting with %v", fields[1])
+	case "/topic":
+		topicId, err := strconv.ParseInt(strings.Join(fields[1:], ""), 10, 64)
+		if err != nil || topicId == 0 {
+			this.printer.status("usage: /topic <topicId>")
+			break
+		}
+		this.target = target{topic: topicId}
+		this.printer.status("chatting in topic %v", topicId)
+	case "/help":
+		this.printer.status(replHelp)
+	case "/quit", "/exit":
+		return false
+	default:
+		this.printer.status("unknown command %v, try /help", fields[0])
+	}
+	return true
+}
+
+func (this *repl) run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !this.handle(scanner.Text()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func runRepl(args []string) error {
+	opts := new(options)
+	if _, err := parse("repl", args, opts, nil); err != nil {
+		return err
+	}
+	printer := newPrinter(os.Stdout, os.Stderr, opts.format)
+	user, handler, err := login(opts, printer)
+	if err != nil {
+		return err
+	}
+	defer logout(user)
+	go func() {
+		for result := range handler.acks {
+			if result.timeout {
+				printer.status("timeout: %v", result.packetId)
+			}
+		}
+	}()
+	fmt.Printf("online as %v\n%v\n", opts.account, replHelp)
+	return (&repl{user: user, printer: printer}).run(os.Stdin)
+}
@@ -15,6 +15,7 @@ const (
 	V6_HEADERLEN_OFFSET   int  = 2
 	V6_PAYLOADLEN_OFFSET  int  = 4
 	V6_CRC_LENGTH         int  = 4
+	V6_MAX_BODY_LENGTH    int  = 16 * 1024 * 1024
 
 	CMD_CONN   string = "CONN"
 	CMD_BIND   string = "BIND"
@@ -22,6 +23,7 @@ const (
 	CMD_UNBIND string = "UBND"
 	CMD_SECMSG string = "SECMSG"
 	CMD_KICK   string = "KICK"
+	CMD_NOTIFY string = "NOTIFY"
 
 	MIMC_SERVER string = "xiaomi.com"
 
@@ -40,10 +42,27 @@ const (
 	NO_KICK     string = "0"
 
 	PING_TIMEVAL_MS                 int64 = 3000 //15s
+	PING_MIN_TIMEVAL_MS             int64 = 1000
+	PING_MAX_TIMEVAL_MS             int64 = 30000
+	PONG_TIMEOUT_MS                 int64 = 5000
+	PING_MAX_MISSED                 int   = 3
 	CONNECT_TIMEOUT                 int64 = 5000
 	LOGIN_TIMEOUT                   int64 = 5000
 	CHECK_TIMEOUT_TIMEVAL_MS        int64 = 10000
 	RESET_SOCKET_TIMEOUT_TIMEVAL_MS int64 = 5000
+	MAX_REDIRECTS                   int   = 3
+
+	FRAGMENT_SIZE         int   = 8 * 1024
+	REASSEMBLE_TIMEOUT_MS int64 = 60000
+	MAX_REASSEMBLE_BYTES  int   = 16 * 1024 * 1024
+
+	COMPRESS_THRESHOLD int = 1024
+
+	SENDER_KEY_WAIT_MS         int64 = 30000
+	MAX_PENDING_GROUP_MESSAGES int   = 1000
+
+	RECEIPT_TRACK_LIMIT  int = 10000
+	RECEIPT_UNREAD_LIMIT int = 1000
 
 	MIMC_TOKEN_EXPIRE string = "token-expired"
 
@@ -59,6 +78,8 @@ const (
 	TOKEN_IP_ONLINE  string = "mimc.chat.xiaomi.net/api/account/token"
 	TOKEN_IP_STAGING string = "10.38.162.149"
 
+	REST_URL_ONLINE string = "https://mimc.chat.xiaomi.net"
+
 	CACHE_DIR  string = "/attach/"
 	CACHE_FILE string = ".userInfo"
 )
@@ -0,0 +1,175 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/byte"
+	"github.com/golang/snappy"
+)
+
+/**
+ * 压缩信封格式(大端):
+ * | magic(2) | version(1) | codec(1) | compressed data |
+ * 接收端只处理带有magic的payload，其他发送方的消息原样交给应用。
+ */
+const (
+	MAGIC        uint16 = 0x4d43
+	VERSION      byte   = 1
+	headerLength int    = 4
+)
+
+// 内置的压缩算法
+const (
+	CODEC_GZIP   byte = 1
+	CODEC_SNAPPY byte = 2
+)
+
+// 解压后的最大字节数，防止恶意构造的数据耗尽内存
+var MaxDecompressedSize = 64 * 1024 * 1024
+
+var (
+	ErrUnknownCodec = errors.New("compress: unknown codec")
+	ErrTooLarge     = errors.New("compress: decompressed payload too large")
+)
+
+type Codec interface {
+	Id() byte
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var lock sync.RWMutex
+var codecs = map[byte]Codec{
+	CODEC_GZIP:   gzipCodec{},
+	CODEC_SNAPPY: snappyCodec{},
+}
+
+// 注册自定义的压缩算法，id相同时覆盖已有的实现
+func Register(codec Codec) {
+	lock.Lock()
+	defer lock.Unlock()
+	codecs[codec.Id()] = codec
+}
+
+func GetCodec(id byte) Codec {
+	lock.RLock()
+	defer lock.RUnlock()
+	return codecs[id]
+}
+
+/**
+ * 压缩payload并加上信封头。
+ * 压缩后没有变小时返回原始payload和false，调用方直接发送原始数据即可。
+ */
+func Encode(codecId byte, payload []byte) ([]byte, bool, error) {
+	codec := GetCodec(codecId)
+	if codec == nil {
+		return nil, false, ErrUnknownCodec
+	}
+	compressed, err := codec.Compress(payload)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(compressed)+headerLength >= len(payload) {
+		return payload, false, nil
+	}
+	header := make([]byte, headerLength)
+	byteutil.TransferUint16(&header, MAGIC, 0)
+	header[2] = VERSION
+	header[3] = codecId
+	return byteutil.Integrate(header, compressed), true, nil
+}
+
+// payload是否带有压缩信封头
+func IsCompressed(payload []byte) bool {
+	return len(payload) >= headerLength && byteutil.GetUint16FromBytes(&payload, 0) == MAGIC && payload[2] == VERSION
+}
+
+/**
+ * 解压带有信封头的payload，没有信封头时原样返回。
+ * 返回的bool表示payload是否经过了解压。
+ */
+func Decode(payload []byte) ([]byte, bool, error) {
+	if !IsCompressed(payload) {
+		return payload, false, nil
+	}
+	codec := GetCodec(payload[3])
+	if codec == nil {
+		return nil, false, ErrUnknownCodec
+	}
+	data, err := codec.Decompress(payload[headerLength:])
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+type gzipCodec struct {
+}
+
+func (this gzipCodec) Id() byte {
+	return CODEC_GZIP
+}
+
+func (this gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (this gzipCodec) Compress(data []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer := gzip.NewWriter(buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (this gzipCodec) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	result, err := io.ReadAll(io.LimitReader(reader, int64(MaxDecompressedSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > MaxDecompressedSize {
+		return nil, ErrTooLarge
+	}
+	return result, nil
+}
+
+type snappyCodec struct {
+}
+
+func (this snappyCodec) Id() byte {
+	return CODEC_SNAPPY
+}
+
+func (this snappyCodec) Name() string {
+	return "snappy"
+}
+
+func (this snappyCodec) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (this snappyCodec) Decompress(data []byte) ([]byte, error) {
+	size, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxDecompressedSize {
+		return nil, ErrTooLarge
+	}
+	return snappy.Decode(nil, data)
+}
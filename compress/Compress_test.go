@@ -0,0 +1,48 @@
+package compress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeAndDecode(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"text":"hello world"}`), 200)
+	for _, codecId := range []byte{CODEC_GZIP, CODEC_SNAPPY} {
+		data, compressed, err := Encode(codecId, payload)
+		if err != nil || !compressed {
+			t.Fatalf("codec %v encode fail: %v, %v", codecId, compressed, err)
+		}
+		if len(data) >= len(payload) {
+			t.Errorf("codec %v should shrink payload: %v >= %v", codecId, len(data), len(payload))
+		}
+		result, decompressed, err := Decode(data)
+		if err != nil || !decompressed || !bytes.Equal(result, payload) {
+			t.Errorf("codec %v decode mismatch: %v, %v", codecId, decompressed, err)
+		}
+	}
+}
+
+func TestIncompressibleAndPlainPayload(t *testing.T) {
+	payload := []byte("hi")
+	data, compressed, err := Encode(CODEC_GZIP, payload)
+	if err != nil || compressed || !bytes.Equal(data, payload) {
+		t.Errorf("small payload should be sent as is")
+	}
+	result, decompressed, err := Decode([]byte("plain text from other sdk"))
+	if err != nil || decompressed || string(result) != "plain text from other sdk" {
+		t.Errorf("plain payload should be untouched")
+	}
+	if _, _, err := Decode([]byte{0x4d, 0x43, VERSION, 99, 1, 2}); err != ErrUnknownCodec {
+		t.Errorf("expect unknown codec error, got %v", err)
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	origin := MaxDecompressedSize
+	defer func() { MaxDecompressedSize = origin }()
+	data, _, _ := Encode(CODEC_GZIP, make([]byte, 4096))
+	MaxDecompressedSize = 1024
+	if _, _, err := Decode(data); err != ErrTooLarge {
+		t.Errorf("expect too large error, got %v", err)
+	}
+}
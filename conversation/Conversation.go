@@ -0,0 +1,206 @@
+package conversation
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+)
+
+// 最多记录的未读消息sequence数量，超过后未读数仍然累加，但只能通过标记到最新消息清零
+const maxUnreadSequences = 10000
+
+/**
+ * 会话，Id与store中的会话id相同: p2p/{对方appAccount}或p2t/{topicId}。
+ * UnreadSequences记录未读消息的sequence，用于按sequence标记已读。
+ */
+type Conversation struct {
+	Id               string         `json:"id"`
+	Type             string         `json:"type"`
+	Peer             string         `json:"peer,omitempty"`
+	TopicId          int64          `json:"topicId,omitempty"`
+	LastMessage      *store.Message `json:"lastMessage,omitempty"`
+	LastSequence     int64          `json:"lastSequence"`
+	LastTimestamp    int64          `json:"lastTimestamp"`
+	UnreadCount      int            `json:"unreadCount"`
+	LastReadSequence int64          `json:"lastReadSequence"`
+	UnreadSequences  []int64        `json:"unreadSequences,omitempty"`
+}
+
+func (this *Conversation) clone() *Conversation {
+	result := *this
+	if this.LastMessage != nil {
+		lastMessage := *this.LastMessage
+		result.LastMessage = &lastMessage
+	}
+	result.UnreadSequences = append([]int64(nil), this.UnreadSequences...)
+	return &result
+}
+
+// 会话的持久化存储
+type Store interface {
+	Load() ([]*Conversation, error)
+	Save(conversation *Conversation) error
+	Delete(id string) error
+}
+
+/**
+ * 在发送和接收路径上维护会话列表。
+ * 所有修改方法返回变化后的会话副本，没有变化时返回nil。
+ */
+type Tracker struct {
+	mu            sync.Mutex
+	store         Store
+	conversations map[string]*Conversation
+}
+
+func NewTracker(conversationStore Store) (*Tracker, error) {
+	if conversationStore == nil {
+		conversationStore = NewMemoryStore()
+	}
+	conversations, err := conversationStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	this := &Tracker{store: conversationStore, conversations: make(map[string]*Conversation)}
+	for _, conversation := range conversations {
+		this.conversations[conversation.Id] = conversation
+	}
+	return this, nil
+}
+
+func (this *Tracker) conversation(message *store.Message) *Conversation {
+	conversation, ok := this.conversations[message.Conversation]
+	if ok {
+		return conversation
+	}
+	conversation = &Conversation{Id: message.Conversation, Type: message.Type, TopicId: message.TopicId}
+	if message.Type == store.TYPE_P2P {
+		if message.Outgoing {
+			conversation.Peer = message.ToAccount
+		} else {
+			conversation.Peer = message.FromAccount
+		}
+	}
+	this.conversations[conversation.Id] = conversation
+	return conversation
+}
+
+// 记录发送或收到的消息，收到的消息计入未读
+func (this *Tracker) OnMessage(message *store.Message) (*Conversation, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	conversation := this.conversation(message)
+	if conversation.LastMessage == nil || message.Timestamp >= conversation.LastTimestamp {
+		lastMessage := *message
+		conversation.LastMessage = &lastMessage
+		conversation.LastTimestamp = message.Timestamp
+	}
+	if message.Sequence > conversation.LastSequence {
+		conversation.LastSequence = message.Sequence
+	}
+	if !message.Outgoing && (message.Sequence == 0 || message.Sequence > conversation.LastReadSequence) {
+		conversation.UnreadCount += 1
+		if message.Sequence > 0 && len(conversation.UnreadSequences) < maxUnreadSequences {
+			conversation.UnreadSequences = append(conversation.UnreadSequences, message.Sequence)
+		}
+	}
+	return this.save(conversation)
+}
+
+// 根据ack更新会话最后一条消息的状态和sequence
+func (this *Tracker) OnStatus(packetId string, status store.Status, sequence, timestamp int64) (*Conversation, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, conversation := range this.conversations {
+		if conversation.LastMessage == nil || conversation.LastMessage.PacketId != packetId {
+			continue
+		}
+		conversation.LastMessage.Status = status
+		if sequence > 0 {
+			conversation.LastMessage.Sequence = sequence
+			if sequence > conversation.LastSequence {
+				conversation.LastSequence = sequence
+			}
+		}
+		if timestamp > 0 {
+			conversation.LastMessage.Timestamp = timestamp
+			conversation.LastTimestamp = timestamp
+		}
+		return this.save(conversation)
+	}
+	return nil, nil
+}
+
+/**
+ * 把会话中sequence不超过upToSequence的消息标记为已读。
+ * upToSequence不小于LastSequence时未读数清零。
+ */
+func (this *Tracker) MarkRead(id string, upToSequence int64) (*Conversation, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	conversation, ok := this.conversations[id]
+	if !ok || upToSequence <= conversation.LastReadSequence && conversation.UnreadCount == 0 {
+		return nil, nil
+	}
+	if upToSequence > conversation.LastReadSequence {
+		conversation.LastReadSequence = upToSequence
+	}
+	if upToSequence >= conversation.LastSequence {
+		conversation.UnreadCount = 0
+		conversation.UnreadSequences = nil
+		return this.save(conversation)
+	}
+	remain := conversation.UnreadSequences[:0]
+	for _, sequence := range conversation.UnreadSequences {
+		if sequence > upToSequence {
+			remain = append(remain, sequence)
+		} else {
+			conversation.UnreadCount -= 1
+		}
+	}
+	conversation.UnreadSequences = remain
+	if conversation.UnreadCount < len(remain) {
+		conversation.UnreadCount = len(remain)
+	}
+	return this.save(conversation)
+}
+
+func (this *Tracker) Delete(id string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	delete(this.conversations, id)
+	return this.store.Delete(id)
+}
+
+func (this *Tracker) Get(id string) *Conversation {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	conversation, ok := this.conversations[id]
+	if !ok {
+		return nil
+	}
+	return conversation.clone()
+}
+
+// 按最后一条消息的时间从新到旧排列
+func (this *Tracker) List() []*Conversation {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	result := make([]*Conversation, 0, len(this.conversations))
+	for _, conversation := range this.conversations {
+		result = append(result, conversation.clone())
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].LastTimestamp != result[j].LastTimestamp {
+			return result[i].LastTimestamp > result[j].LastTimestamp
+		}
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+func (this *Tracker) save(conversation *Conversation) (*Conversation, error) {
+	snapshot := conversation.clone()
+	return snapshot, this.store.Save(snapshot)
+}
@@ -0,0 +1,145 @@
+package conversation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/store"
+)
+
+func received(sequence int64) *store.Message {
+	return &store.Message{
+		PacketId:     "r" + string(rune('0'+sequence)),
+		Conversation: store.P2PConversation("Bob"),
+		Type:         store.TYPE_P2P,
+		FromAccount:  "Bob",
+		ToAccount:    "Alice",
+		Sequence:     sequence,
+		Timestamp:    sequence * 1000,
+		Status:       store.STATUS_RECEIVED,
+	}
+}
+
+func TestTrackerUnread(t *testing.T) {
+	tracker, _ := NewTracker(nil)
+	for i := int64(1); i <= 3; i++ {
+		tracker.OnMessage(received(i))
+	}
+	conversation := tracker.Get(store.P2PConversation("Bob"))
+	if conversation.Peer != "Bob" || conversation.UnreadCount != 3 || conversation.LastSequence != 3 {
+		t.Fatalf("unexpected conversation: %+v", conversation)
+	}
+	conversation, _ = tracker.MarkRead(conversation.Id, 2)
+	if conversation.UnreadCount != 1 || conversation.LastReadSequence != 2 {
+		t.Errorf("mark read mismatch: %+v", conversation)
+	}
+	if changed, _ := tracker.MarkRead(conversation.Id, 1); changed == nil || changed.UnreadCount != 1 {
+		t.Errorf("older marker should not change unread: %+v", changed)
+	}
+
+	sent := &store.Message{PacketId: "s1", Conversation: store.P2PConversation("Bob"), Type: store.TYPE_P2P, FromAccount: "Alice", ToAccount: "Bob", Timestamp: 3500, Outgoing: true, Status: store.STATUS_SENDING}
+	tracker.OnMessage(sent)
+	conversation, _ = tracker.OnStatus("s1", store.STATUS_ACKED, 4, 4000)
+	if conversation == nil || conversation.LastMessage.Status != store.STATUS_ACKED || conversation.LastSequence != 4 || conversation.UnreadCount != 1 {
+		t.Fatalf("ack should update last message: %+v", conversation)
+	}
+	conversation, _ = tracker.MarkRead(conversation.Id, 4)
+	if conversation.UnreadCount != 0 || len(conversation.UnreadSequences) != 0 {
+		t.Errorf("mark read to latest should clear unread: %+v", conversation)
+	}
+	if changed, _ := tracker.OnStatus("none", store.STATUS_ACKED, 1, 1); changed != nil {
+		t.Errorf("unknown packet should not change conversation")
+	}
+}
+
+func TestTrackerFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.json")
+	conversationStore, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open store fail: %v", err)
+	}
+	tracker, _ := NewTracker(conversationStore)
+	tracker.OnMessage(received(1))
+	tracker.OnMessage(&store.Message{PacketId: "t1", Conversation: store.P2TConversation(100), Type: store.TYPE_P2T, TopicId: 100, Timestamp: 5000, Outgoing: true})
+	tracker.OnMessage(&store.Message{PacketId: "t2", Conversation: store.P2TConversation(200), Type: store.TYPE_P2T, TopicId: 200, Timestamp: 500, Outgoing: true})
+	tracker.Delete(store.P2TConversation(200))
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen store fail: %v", err)
+	}
+	tracker, _ = NewTracker(reopened)
+	conversations := tracker.List()
+	if len(conversations) != 2 || conversations[0].TopicId != 100 || conversations[1].UnreadCount != 1 {
+		t.Errorf("reloaded conversations mismatch: %+v", conversations)
+	}
+}
+
+func TestFileStoreAppendAndCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+	conversationStore, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open store fail: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		conversationStore.Save(&Conversation{Id: "p2p/Bob", UnreadCount: i})
+	}
+	conversationStore.Save(&Conversation{Id: "p2t/100"})
+	conversationStore.Delete("p2t/100")
+	conversationStore.Close()
+	// 模拟写入一半时崩溃
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"op":"save","conversation":{"id":"p2p/Ca`)
+	file.Close()
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen store fail: %v", err)
+	}
+	conversations, _ := reopened.Load()
+	if len(conversations) != 1 || conversations[0].Id != "p2p/Bob" || conversations[0].UnreadCount != 9 {
+		t.Fatalf("replayed conversations mismatch: %+v", conversations)
+	}
+	before, _ := os.Stat(path)
+	if err := reopened.Compact(); err != nil {
+		t.Fatalf("compact fail: %v", err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("compact should shrink the file, before: %v, after: %v", before.Size(), after.Size())
+	}
+	reopened.Save(&Conversation{Id: "p2p/Carol"})
+	reopened.Close()
+	reopened, _ = NewFileStore(path)
+	if conversations, _ := reopened.Load(); len(conversations) != 2 {
+		t.Errorf("expect 2 conversations after compact, got %+v", conversations)
+	}
+	reopened.Close()
+}
+
+// 每条消息追加完整的会话，文件大小通过自动压缩保持在会话大小的常数倍内
+func TestFileStoreAutoCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+	conversationStore, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open store fail: %v", err)
+	}
+	conversationStore.compactMinBytes, conversationStore.compactAt = 1024, 1024
+	tracker, _ := NewTracker(conversationStore)
+	for i := 1; i <= 1000; i++ {
+		tracker.OnMessage(received(int64(i)))
+	}
+	info, _ := os.Stat(path)
+	snapshot, _ := json.Marshal(&record{Op: opSave, Conversation: tracker.List()[0]})
+	if info.Size() > 2*int64(len(snapshot)+1)+1024 {
+		t.Errorf("file should be compacted, size: %v, snapshot: %v", info.Size(), len(snapshot))
+	}
+	conversationStore.Close()
+	reopened, _ := NewFileStore(path)
+	if conversations, _ := reopened.Load(); len(conversations) != 1 || conversations[0].UnreadCount != 1000 {
+		t.Errorf("compacted store mismatch: %+v", conversations)
+	}
+	reopened.Close()
+}
@@ -0,0 +1,160 @@
+package conversation
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/jsonl"
+)
+
+type MemoryStore struct {
+	mu            sync.Mutex
+	conversations map[string]*Conversation
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{conversations: make(map[string]*Conversation)}
+}
+
+func (this *MemoryStore) Load() ([]*Conversation, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	result := make([]*Conversation, 0, len(this.conversations))
+	for _, conversation := range this.conversations {
+		result = append(result, conversation.clone())
+	}
+	return result, nil
+}
+
+func (this *MemoryStore) Save(conversation *Conversation) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.conversations[conversation.Id] = conversation.clone()
+	return nil
+}
+
+func (this *MemoryStore) Delete(id string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	delete(this.conversations, id)
+	return nil
+}
+
+const (
+	opSave   string = "save"
+	opDelete string = "delete"
+)
+
+// 文件中的一行记录
+type record struct {
+	Op           string        `json:"op"`
+	Conversation *Conversation `json:"conversation,omitempty"`
+	Id           string        `json:"id,omitempty"`
+}
+
+// 文件超过该大小且超过上次压缩后大小的两倍时自动压缩
+const compactMinBytes int64 = 4 * 1024 * 1024
+
+/**
+ * 以JSON Lines文件持久化的会话存储，每次修改追加一行记录，打开时重放全部记录。
+ * 会话随每条消息更新，每次追加完整的会话，文件超过compactMinBytes且超过上次压缩后大小的两倍时自动压缩，
+ * 也可以调用Compact立即重写文件。进程崩溃导致的不完整行在打开时被忽略。
+ */
+type FileStore struct {
+	mu              sync.Mutex
+	log             *jsonl.AppendLog
+	memory          *MemoryStore
+	compactMinBytes int64
+	compactAt       int64
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	this := &FileStore{memory: NewMemoryStore(), compactMinBytes: compactMinBytes, compactAt: compactMinBytes}
+	appendLog, err := jsonl.Open(path, this.replay)
+	if err != nil {
+		return nil, err
+	}
+	this.log = appendLog
+	return this, nil
+}
+
+func (this *FileStore) replay(line []byte) {
+	r := new(record)
+	if err := json.Unmarshal(line, r); err != nil {
+		return
+	}
+	switch r.Op {
+	case opSave:
+		if r.Conversation != nil {
+			this.memory.conversations[r.Conversation.Id] = r.Conversation
+		}
+	case opDelete:
+		delete(this.memory.conversations, r.Id)
+	}
+}
+
+// 追加记录，文件过大时按内存中的会话压缩，调用前需要先更新内存；压缩失败不影响已经写入的记录，下次追加时重试
+func (this *FileStore) append(r *record) error {
+	if err := this.log.Append(r); err != nil {
+		return err
+	}
+	if this.log.Size() > this.compactAt {
+		this.compact()
+	}
+	return nil
+}
+
+func (this *FileStore) Load() ([]*Conversation, error) {
+	return this.memory.Load()
+}
+
+func (this *FileStore) Save(conversation *Conversation) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if err := this.memory.Save(conversation); err != nil {
+		return err
+	}
+	return this.append(&record{Op: opSave, Conversation: conversation})
+}
+
+func (this *FileStore) Delete(id string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if err := this.memory.Delete(id); err != nil {
+		return err
+	}
+	return this.append(&record{Op: opDelete, Id: id})
+}
+
+// 用当前的会话重写文件，去掉被覆盖和删除的记录
+func (this *FileStore) Compact() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.compact()
+}
+
+func (this *FileStore) compact() error {
+	conversations, _ := this.memory.Load()
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].Id < conversations[j].Id
+	})
+	records := make([]interface{}, 0, len(conversations))
+	for _, conversation := range conversations {
+		records = append(records, &record{Op: opSave, Conversation: conversation})
+	}
+	if err := this.log.Rewrite(records); err != nil {
+		return err
+	}
+	this.compactAt = 2 * this.log.Size()
+	if this.compactAt < this.compactMinBytes {
+		this.compactAt = this.compactMinBytes
+	}
+	return nil
+}
+
+func (this *FileStore) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.log.Close()
+}
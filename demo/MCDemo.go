@@ -69,7 +69,7 @@ func createUser(appAccount *string) *mimc.MCUser {
 
 	mcUser := mimc.NewUser(*appAccount)
 	statusDelegate, tokenDelegate, msgDelegate := createDelegates(appAccount)
-	mcUser.RegisterStatusDelegate(statusDelegate).RegisterTokenDelegate(tokenDelegate).RegisterMessageDelegate(msgDelegate).InitAndSetup()
+	mcUser.RegisterStatusDelegate(statusDelegate).RegisterTokenDelegate(tokenDelegate).RegisterMessageDelegate(msgDelegate).RegisterErrorDelegate(handler.NewErrorHandler()).InitAndSetup()
 	return mcUser
 }
 
@@ -0,0 +1,12 @@
+package handler
+
+type ErrorHandler struct {
+}
+
+func NewErrorHandler() *ErrorHandler {
+	return &ErrorHandler{}
+}
+
+func (this ErrorHandler) HandleError(err error) {
+	logger.Warn("[handle server error] %v.", err)
+}
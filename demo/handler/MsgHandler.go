@@ -10,7 +10,7 @@ import (
 type MsgHandler struct {
 }
 
-var logger *log.Logger
+var logger log.Logger
 
 func NewMsgHandler() *MsgHandler {
 	logger = log.GetLogger()
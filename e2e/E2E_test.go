@@ -0,0 +1,109 @@
+package e2e
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealAndOpen(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+	mallory, _ := GenerateIdentity()
+	plaintext := []byte("hello bob!")
+
+	envelope, err := Seal(alice, "Alice", "Bob", bob.PublicKey(), plaintext)
+	if err != nil {
+		t.Fatalf("seal fail: %v", err)
+	}
+	if !IsEncrypted(envelope) || bytes.Contains(envelope, plaintext) {
+		t.Fatalf("envelope should be encrypted")
+	}
+	data, err := Open(bob, "Alice", "Bob", alice.PublicKey(), envelope)
+	if err != nil || !bytes.Equal(data, plaintext) {
+		t.Fatalf("open fail: %v", err)
+	}
+
+	if _, err := Open(bob, "Mallory", "Bob", mallory.PublicKey(), envelope); err != ErrDecrypt {
+		t.Errorf("message should not verify with another sender key, got %v", err)
+	}
+	if _, err := Open(bob, "Alice", "Carol", alice.PublicKey(), envelope); err != ErrDecrypt {
+		t.Errorf("message should not verify for another recipient account, got %v", err)
+	}
+	envelope[len(envelope)-1] ^= 1
+	if _, err := Open(bob, "Alice", "Bob", alice.PublicKey(), envelope); err != ErrDecrypt {
+		t.Errorf("tampered message should not verify, got %v", err)
+	}
+	if IsEncrypted(plaintext) {
+		t.Errorf("plain payload should not be treated as encrypted")
+	}
+}
+
+func TestFileStores(t *testing.T) {
+	dir := t.TempDir()
+	identityPath := filepath.Join(dir, "identity")
+	identity, err := LoadOrCreateIdentity(identityPath)
+	if err != nil {
+		t.Fatalf("create identity fail: %v", err)
+	}
+	loaded, err := LoadOrCreateIdentity(identityPath)
+	if err != nil || !bytes.Equal(loaded.PublicKey(), identity.PublicKey()) {
+		t.Fatalf("identity should be loaded from file, err: %v", err)
+	}
+
+	directoryPath := filepath.Join(dir, "keys.json")
+	directory, _ := NewFileDirectory(directoryPath)
+	if _, err := directory.PublicKey("Alice"); err != ErrNoPublicKey {
+		t.Errorf("expect ErrNoPublicKey, got %v", err)
+	}
+	if err := directory.SetPublicKey("Alice", []byte("short")); err != ErrInvalidKey {
+		t.Errorf("expect ErrInvalidKey, got %v", err)
+	}
+	if err := directory.SetPublicKey("Alice", identity.PublicKey()); err != nil {
+		t.Fatalf("set public key fail: %v", err)
+	}
+	reopened, err := NewFileDirectory(directoryPath)
+	if err != nil {
+		t.Fatalf("reopen directory fail: %v", err)
+	}
+	if key, err := reopened.PublicKey("Alice"); err != nil || !bytes.Equal(key, identity.PublicKey()) {
+		t.Errorf("public key should be persisted, err: %v", err)
+	}
+}
+
+func TestSenderKey(t *testing.T) {
+	senderKey, _ := NewSenderKey()
+	first, _ := senderKey.Seal(1, "Alice", []byte("first"))
+	distribution, err := ParseDistribution(senderKey.Distribution(1))
+	if err != nil || distribution.TopicId != 1 || distribution.KeyId != senderKey.KeyId() {
+		t.Fatalf("parse distribution fail: %v", err)
+	}
+	receiverKey := NewReceiverKey(distribution)
+	if _, err := receiverKey.Open(1, "Alice", first); err != ErrReplay {
+		t.Errorf("message before distribution should not be decrypted, got %v", err)
+	}
+
+	second, _ := senderKey.Seal(1, "Alice", []byte("second"))
+	third, _ := senderKey.Seal(1, "Alice", []byte("third"))
+	// 乱序到达
+	if data, err := receiverKey.Open(1, "Alice", third); err != nil || string(data) != "third" {
+		t.Fatalf("open third fail: %v", err)
+	}
+	if data, err := receiverKey.Open(1, "Alice", second); err != nil || string(data) != "second" {
+		t.Fatalf("open skipped message fail: %v", err)
+	}
+	if _, err := receiverKey.Open(1, "Alice", second); err != ErrReplay {
+		t.Errorf("replayed message should be rejected, got %v", err)
+	}
+
+	fourth, _ := senderKey.Seal(1, "Alice", []byte("fourth"))
+	if _, err := receiverKey.Open(2, "Alice", fourth); err != ErrDecrypt {
+		t.Errorf("message should not verify in another topic, got %v", err)
+	}
+	if _, err := receiverKey.Open(1, "Bob", fourth); err != ErrDecrypt {
+		t.Errorf("message should not verify for another sender, got %v", err)
+	}
+	if data, err := receiverKey.Open(1, "Alice", fourth); err != nil || string(data) != "fourth" {
+		t.Errorf("failed verification should not advance the chain: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package e2e
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("e2e: invalid key")
+
+// 账号的X25519身份密钥
+type Identity struct {
+	privateKey *ecdh.PrivateKey
+}
+
+func GenerateIdentity() (*Identity, error) {
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{privateKey: privateKey}, nil
+}
+
+func NewIdentity(privateKey []byte) (*Identity, error) {
+	key, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return &Identity{privateKey: key}, nil
+}
+
+/**
+ * 从文件中读取身份密钥(base64编码的私钥)，文件不存在时生成新的密钥并写入。
+ * 私钥文件权限为0600，应与应用的其他敏感数据一同保护。
+ */
+func LoadOrCreateIdentity(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		privateKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		return NewIdentity(privateKey)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	identity, err := GenerateIdentity()
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(identity.PrivateKey())
+	if err := os.WriteFile(path, []byte(encoded), 0600); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (this *Identity) PublicKey() []byte {
+	return this.privateKey.PublicKey().Bytes()
+}
+
+func (this *Identity) PrivateKey() []byte {
+	return this.privateKey.Bytes()
+}
+
+func (this *Identity) ecdh(publicKey []byte) ([]byte, error) {
+	remote, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return this.privateKey.ECDH(remote)
+}
@@ -0,0 +1,110 @@
+package e2e
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+var ErrNoPublicKey = errors.New("e2e: public key not found")
+
+/**
+ * 账号公钥目录，加密时查询接收方公钥，解密时查询发送方公钥以验证消息来源。
+ * 应用可以对接自己的密钥服务，SDK提供内存和文件两种实现。
+ */
+type KeyDirectory interface {
+	PublicKey(appAccount string) ([]byte, error)
+	SetPublicKey(appAccount string, publicKey []byte) error
+}
+
+type MemoryDirectory struct {
+	mu   sync.RWMutex
+	keys map[string][]byte
+}
+
+func NewMemoryDirectory() *MemoryDirectory {
+	return &MemoryDirectory{keys: make(map[string][]byte)}
+}
+
+func (this *MemoryDirectory) PublicKey(appAccount string) ([]byte, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	key, ok := this.keys[appAccount]
+	if !ok {
+		return nil, ErrNoPublicKey
+	}
+	return key, nil
+}
+
+func (this *MemoryDirectory) SetPublicKey(appAccount string, publicKey []byte) error {
+	if len(publicKey) != KEY_LENGTH {
+		return ErrInvalidKey
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	key := make([]byte, len(publicKey))
+	copy(key, publicKey)
+	this.keys[appAccount] = key
+	return nil
+}
+
+// 以JSON文件({appAccount: base64公钥})持久化的公钥目录
+type FileDirectory struct {
+	mu     sync.Mutex
+	path   string
+	memory *MemoryDirectory
+}
+
+func NewFileDirectory(path string) (*FileDirectory, error) {
+	this := &FileDirectory{path: path, memory: NewMemoryDirectory()}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return this, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var encoded map[string]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+	for appAccount, value := range encoded {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		if err := this.memory.SetPublicKey(appAccount, key); err != nil {
+			return nil, err
+		}
+	}
+	return this, nil
+}
+
+func (this *FileDirectory) PublicKey(appAccount string) ([]byte, error) {
+	return this.memory.PublicKey(appAccount)
+}
+
+func (this *FileDirectory) SetPublicKey(appAccount string, publicKey []byte) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if err := this.memory.SetPublicKey(appAccount, publicKey); err != nil {
+		return err
+	}
+	this.memory.mu.RLock()
+	encoded := make(map[string]string, len(this.memory.keys))
+	for account, key := range this.memory.keys {
+		encoded[account] = base64.StdEncoding.EncodeToString(key)
+	}
+	this.memory.mu.RUnlock()
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return err
+	}
+	tmpPath := this.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, this.path)
+}
@@ -0,0 +1,124 @@
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/byte"
+)
+
+/**
+ * 端到端加密信封格式(大端):
+ * | magic(2) | version(1) | ephemeral public key(32) | nonce(12) | AES-256-GCM密文 |
+ * 密钥由 X25519(ephemeral, 接收方) || X25519(发送方, 接收方) 经HKDF-SHA256派生，
+ * 发送方和接收方账号作为附加认证数据，接收方能够确认消息确实来自发送方账号的身份密钥。
+ */
+const (
+	MAGIC        uint16 = 0x4d45
+	VERSION      byte   = 1
+	KEY_LENGTH   int    = 32
+	nonceLength         = 12
+	headerLength        = 3 + KEY_LENGTH + nonceLength
+)
+
+var (
+	ErrInvalidEnvelope = errors.New("e2e: invalid envelope")
+	ErrDecrypt         = errors.New("e2e: message authentication failed")
+)
+
+// payload是否带有端到端加密信封头
+func IsEncrypted(payload []byte) bool {
+	return len(payload) >= headerLength && byteutil.GetUint16FromBytes(&payload, 0) == MAGIC && payload[2] == VERSION
+}
+
+// 使用发送方的身份密钥和接收方的公钥加密payload
+func Seal(sender *Identity, fromAccount, toAccount string, recipientKey []byte, plaintext []byte) ([]byte, error) {
+	ephemeral, err := GenerateIdentity()
+	if err != nil {
+		return nil, err
+	}
+	ephemeralSecret, err := ephemeral.ecdh(recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	staticSecret, err := sender.ecdh(recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerLength)
+	byteutil.TransferUint16(&header, MAGIC, 0)
+	header[2] = VERSION
+	copy(header[3:], ephemeral.PublicKey())
+	nonce := header[3+KEY_LENGTH:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(byteutil.Integrate(ephemeralSecret, staticSecret), header[3:3+KEY_LENGTH], recipientKey, fromAccount, toAccount)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, nonce, plaintext, additionalData(header, fromAccount, toAccount)), nil
+}
+
+// 使用接收方的身份密钥和发送方的公钥解密并验证payload
+func Open(recipient *Identity, fromAccount, toAccount string, senderKey []byte, envelope []byte) ([]byte, error) {
+	if !IsEncrypted(envelope) {
+		return nil, ErrInvalidEnvelope
+	}
+	header := envelope[:headerLength]
+	ephemeralKey := header[3 : 3+KEY_LENGTH]
+	ephemeralSecret, err := recipient.ecdh(ephemeralKey)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	staticSecret, err := recipient.ecdh(senderKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(byteutil.Integrate(ephemeralSecret, staticSecret), ephemeralKey, recipient.PublicKey(), fromAccount, toAccount)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, header[3+KEY_LENGTH:], envelope[headerLength:], additionalData(header, fromAccount, toAccount))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newAEAD(secret, ephemeralKey, recipientKey []byte, fromAccount, toAccount string) (cipher.AEAD, error) {
+	salt := byteutil.Integrate(ephemeralKey, recipientKey)
+	info := []byte("mimc-e2e-v1\x00" + fromAccount + "\x00" + toAccount)
+	key := hkdf(secret, salt, info, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func additionalData(header []byte, fromAccount, toAccount string) []byte {
+	return byteutil.Integrate(header, []byte(fromAccount+"\x00"+toAccount))
+}
+
+// RFC 5869 HKDF-SHA256
+func hkdf(secret, salt, info []byte, length int) []byte {
+	extractor := hmac.New(sha256.New, salt)
+	extractor.Write(secret)
+	prk := extractor.Sum(nil)
+	result := make([]byte, 0, length)
+	var block []byte
+	for counter := byte(1); len(result) < length; counter++ {
+		expander := hmac.New(sha256.New, prk)
+		expander.Write(block)
+		expander.Write(info)
+		expander.Write([]byte{counter})
+		block = expander.Sum(nil)
+		result = append(result, block...)
+	}
+	return result[:length]
+}
@@ -0,0 +1,250 @@
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+/**
+ * 群聊sender key方案：每个成员为每个topic生成自己的链密钥和签名密钥，
+ * 通过端到端加密的单聊消息分发给其他成员。每发送一条消息链密钥向前推进一步，
+ * 拿到某一时刻链密钥的成员无法解密之前的消息。
+ *
+ * 分发消息格式(大端):
+ * | magic(2) | version(1) | topicId(8) | keyId(4) | iteration(4) | chain key(32) | signing public key(32) |
+ * 群聊信封格式(大端):
+ * | magic(2) | version(1) | keyId(4) | iteration(4) | nonce(12) | AES-256-GCM密文 | ed25519签名(64) |
+ */
+const (
+	DISTRIBUTION_MAGIC uint16 = 0x4d4b
+	GROUP_MAGIC        uint16 = 0x4d47
+	distributionLength        = 3 + 8 + 4 + 4 + KEY_LENGTH + ed25519.PublicKeySize
+	groupHeaderLength         = 3 + 4 + 4 + nonceLength
+)
+
+// 接收端为乱序到达的消息最多缓存的消息密钥数量，也是单次允许跳过的最大消息数
+var MaxSkippedKeys uint32 = 2000
+
+var (
+	ErrNoSenderKey = errors.New("e2e: sender key not found")
+	ErrReplay      = errors.New("e2e: message key already used")
+	ErrTooFarAhead = errors.New("e2e: too many skipped messages")
+)
+
+// payload是否带有群聊加密信封头
+func IsGroupEncrypted(payload []byte) bool {
+	return len(payload) >= groupHeaderLength+ed25519.SignatureSize && binary.BigEndian.Uint16(payload) == GROUP_MAGIC && payload[2] == VERSION
+}
+
+// payload是否为sender key分发消息
+func IsDistribution(payload []byte) bool {
+	return len(payload) == distributionLength && binary.BigEndian.Uint16(payload) == DISTRIBUTION_MAGIC && payload[2] == VERSION
+}
+
+// 群聊信封中的keyId，用于查找对应的ReceiverKey
+func GroupKeyId(envelope []byte) uint32 {
+	return binary.BigEndian.Uint32(envelope[3:])
+}
+
+// 当前账号在一个topic中的发送密钥
+type SenderKey struct {
+	mu         sync.Mutex
+	keyId      uint32
+	iteration  uint32
+	chainKey   []byte
+	signingKey ed25519.PrivateKey
+}
+
+func NewSenderKey() (*SenderKey, error) {
+	random := make([]byte, 4+KEY_LENGTH)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &SenderKey{keyId: binary.BigEndian.Uint32(random), chainKey: random[4:], signingKey: signingKey}, nil
+}
+
+func (this *SenderKey) KeyId() uint32 {
+	return this.keyId
+}
+
+// 当前链状态的分发消息，接收方只能解密此后发送的消息
+func (this *SenderKey) Distribution(topicId int64) []byte {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	data := make([]byte, distributionLength)
+	binary.BigEndian.PutUint16(data, DISTRIBUTION_MAGIC)
+	data[2] = VERSION
+	binary.BigEndian.PutUint64(data[3:], uint64(topicId))
+	binary.BigEndian.PutUint32(data[11:], this.keyId)
+	binary.BigEndian.PutUint32(data[15:], this.iteration)
+	copy(data[19:], this.chainKey)
+	copy(data[19+KEY_LENGTH:], this.signingKey.Public().(ed25519.PublicKey))
+	return data
+}
+
+func (this *SenderKey) Seal(topicId int64, fromAccount string, plaintext []byte) ([]byte, error) {
+	this.mu.Lock()
+	iteration := this.iteration
+	messageKey, nextChainKey := ratchet(this.chainKey)
+	this.chainKey = nextChainKey
+	this.iteration += 1
+	this.mu.Unlock()
+
+	header := make([]byte, groupHeaderLength)
+	binary.BigEndian.PutUint16(header, GROUP_MAGIC)
+	header[2] = VERSION
+	binary.BigEndian.PutUint32(header[3:], this.keyId)
+	binary.BigEndian.PutUint32(header[7:], iteration)
+	nonce := header[11:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	aead, err := newGroupAEAD(messageKey)
+	if err != nil {
+		return nil, err
+	}
+	envelope := aead.Seal(header, nonce, plaintext, groupAdditionalData(header, topicId, fromAccount))
+	signature := ed25519.Sign(this.signingKey, groupAdditionalData(envelope, topicId, fromAccount))
+	return append(envelope, signature...), nil
+}
+
+type SenderKeyDistribution struct {
+	TopicId    int64
+	KeyId      uint32
+	Iteration  uint32
+	ChainKey   []byte
+	SigningKey ed25519.PublicKey
+}
+
+func ParseDistribution(payload []byte) (*SenderKeyDistribution, error) {
+	if !IsDistribution(payload) {
+		return nil, ErrInvalidEnvelope
+	}
+	return &SenderKeyDistribution{
+		TopicId:    int64(binary.BigEndian.Uint64(payload[3:])),
+		KeyId:      binary.BigEndian.Uint32(payload[11:]),
+		Iteration:  binary.BigEndian.Uint32(payload[15:]),
+		ChainKey:   append([]byte(nil), payload[19:19+KEY_LENGTH]...),
+		SigningKey: append(ed25519.PublicKey(nil), payload[19+KEY_LENGTH:]...),
+	}, nil
+}
+
+// 其他成员在一个topic中的发送密钥
+type ReceiverKey struct {
+	mu         sync.Mutex
+	keyId      uint32
+	iteration  uint32
+	chainKey   []byte
+	verifyKey  ed25519.PublicKey
+	skippedKey map[uint32][]byte
+}
+
+func NewReceiverKey(distribution *SenderKeyDistribution) *ReceiverKey {
+	return &ReceiverKey{
+		keyId:      distribution.KeyId,
+		iteration:  distribution.Iteration,
+		chainKey:   distribution.ChainKey,
+		verifyKey:  distribution.SigningKey,
+		skippedKey: make(map[uint32][]byte),
+	}
+}
+
+func (this *ReceiverKey) KeyId() uint32 {
+	return this.keyId
+}
+
+// 验证签名并解密群聊信封，签名验证通过后才会推进链密钥
+func (this *ReceiverKey) Open(topicId int64, fromAccount string, envelope []byte) ([]byte, error) {
+	if !IsGroupEncrypted(envelope) || GroupKeyId(envelope) != this.keyId {
+		return nil, ErrInvalidEnvelope
+	}
+	signed := envelope[:len(envelope)-ed25519.SignatureSize]
+	if !ed25519.Verify(this.verifyKey, groupAdditionalData(signed, topicId, fromAccount), envelope[len(signed):]) {
+		return nil, ErrDecrypt
+	}
+	messageKey, err := this.messageKey(binary.BigEndian.Uint32(envelope[7:]))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGroupAEAD(messageKey)
+	if err != nil {
+		return nil, err
+	}
+	header := signed[:groupHeaderLength]
+	plaintext, err := aead.Open(nil, header[11:], signed[groupHeaderLength:], groupAdditionalData(header, topicId, fromAccount))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func (this *ReceiverKey) messageKey(iteration uint32) ([]byte, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if iteration < this.iteration {
+		messageKey, ok := this.skippedKey[iteration]
+		if !ok {
+			return nil, ErrReplay
+		}
+		delete(this.skippedKey, iteration)
+		return messageKey, nil
+	}
+	if iteration-this.iteration > MaxSkippedKeys {
+		return nil, ErrTooFarAhead
+	}
+	for this.iteration < iteration {
+		messageKey, nextChainKey := ratchet(this.chainKey)
+		this.skippedKey[this.iteration] = messageKey
+		this.chainKey = nextChainKey
+		this.iteration += 1
+	}
+	for uint32(len(this.skippedKey)) > MaxSkippedKeys {
+		oldest := this.iteration
+		for skipped := range this.skippedKey {
+			if skipped < oldest {
+				oldest = skipped
+			}
+		}
+		delete(this.skippedKey, oldest)
+	}
+	messageKey, nextChainKey := ratchet(this.chainKey)
+	this.chainKey = nextChainKey
+	this.iteration += 1
+	return messageKey, nil
+}
+
+func ratchet(chainKey []byte) (messageKey []byte, nextChainKey []byte) {
+	mac := hmac.New(sha256.New, chainKey)
+	mac.Write([]byte{1})
+	messageKey = mac.Sum(nil)
+	mac.Reset()
+	mac.Write([]byte{2})
+	nextChainKey = mac.Sum(nil)
+	return
+}
+
+func newGroupAEAD(messageKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(messageKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func groupAdditionalData(data []byte, topicId int64, fromAccount string) []byte {
+	result := make([]byte, len(data)+8, len(data)+8+len(fromAccount))
+	copy(result, data)
+	binary.BigEndian.PutUint64(result[len(data):], uint64(topicId))
+	return append(result, fromAccount...)
+}
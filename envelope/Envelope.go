@@ -0,0 +1,137 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+
+	pb "github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/envelope"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/byte"
+	"github.com/golang/protobuf/proto"
+)
+
+/**
+ * 信封格式(大端):
+ * | magic(2) | version(1) | protobuf序列化的MIMCEnvelope |
+ * 与其他端SDK共用，定义见protobuf/envelope/envelope.proto。
+ */
+const (
+	MAGIC        uint16 = 0x4d56
+	VERSION      byte   = 1
+	headerLength int    = 3
+)
+
+// 内置的内容类型，应用也可以使用其他MIME类型
+const (
+	CONTENT_TYPE_TEXT    string = "text/plain"
+	CONTENT_TYPE_JSON    string = "application/json"
+	CONTENT_TYPE_BINARY  string = "application/octet-stream"
+	CONTENT_TYPE_COMMAND string = "application/vnd.mimc.command"
+)
+
+var ErrInvalidEnvelope = errors.New("envelope: invalid envelope")
+
+type Envelope struct {
+	ContentType string
+	BizType     string
+	ClientMsgId string
+	// 发送时间，毫秒
+	SentAt  int64
+	ReplyTo string
+	Headers map[string]string
+	Body    []byte
+}
+
+func NewText(text string) *Envelope {
+	return &Envelope{ContentType: CONTENT_TYPE_TEXT, Body: []byte(text)}
+}
+
+func NewJSON(v interface{}) (*Envelope, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{ContentType: CONTENT_TYPE_JSON, Body: body}, nil
+}
+
+func (this *Envelope) SetBizType(bizType string) *Envelope {
+	this.BizType = bizType
+	return this
+}
+
+func (this *Envelope) SetReplyTo(replyTo string) *Envelope {
+	this.ReplyTo = replyTo
+	return this
+}
+
+func (this *Envelope) SetHeader(key, value string) *Envelope {
+	if this.Headers == nil {
+		this.Headers = make(map[string]string)
+	}
+	this.Headers[key] = value
+	return this
+}
+
+func (this *Envelope) Header(key string) string {
+	return this.Headers[key]
+}
+
+func (this *Envelope) Text() string {
+	return string(this.Body)
+}
+
+// 把JSON类型的Body解析到v
+func (this *Envelope) DecodeJSON(v interface{}) error {
+	return json.Unmarshal(this.Body, v)
+}
+
+// payload是否带有信封头
+func IsEnvelope(payload []byte) bool {
+	return len(payload) >= headerLength && byteutil.GetUint16FromBytes(&payload, 0) == MAGIC && payload[2] == VERSION
+}
+
+func Encode(env *Envelope) ([]byte, error) {
+	message := &pb.MIMCEnvelope{Headers: env.Headers, Body: env.Body}
+	if len(env.ContentType) > 0 {
+		message.ContentType = proto.String(env.ContentType)
+	}
+	if len(env.BizType) > 0 {
+		message.BizType = proto.String(env.BizType)
+	}
+	if len(env.ClientMsgId) > 0 {
+		message.ClientMsgId = proto.String(env.ClientMsgId)
+	}
+	if env.SentAt != 0 {
+		message.SentAt = proto.Int64(env.SentAt)
+	}
+	if len(env.ReplyTo) > 0 {
+		message.ReplyTo = proto.String(env.ReplyTo)
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerLength)
+	byteutil.TransferUint16(&header, MAGIC, 0)
+	header[2] = VERSION
+	return byteutil.Integrate(header, data), nil
+}
+
+// 解析带有信封头的payload，没有信封头时返回ErrInvalidEnvelope
+func Decode(payload []byte) (*Envelope, error) {
+	if !IsEnvelope(payload) {
+		return nil, ErrInvalidEnvelope
+	}
+	message := new(pb.MIMCEnvelope)
+	if err := proto.Unmarshal(payload[headerLength:], message); err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		ContentType: message.GetContentType(),
+		BizType:     message.GetBizType(),
+		ClientMsgId: message.GetClientMsgId(),
+		SentAt:      message.GetSentAt(),
+		ReplyTo:     message.GetReplyTo(),
+		Headers:     message.GetHeaders(),
+		Body:        message.GetBody(),
+	}, nil
+}
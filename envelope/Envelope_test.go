@@ -0,0 +1,40 @@
+package envelope
+
+import (
+	"testing"
+)
+
+func TestEncodeAndDecode(t *testing.T) {
+	env, _ := NewJSON(map[string]int{"count": 3})
+	env.SetBizType("order").SetReplyTo("packet_1").SetHeader("trace", "abc")
+	env.ClientMsgId = "client_1"
+	env.SentAt = 1600000000000
+	payload, err := Encode(env)
+	if err != nil {
+		t.Fatalf("encode fail: %v", err)
+	}
+	if !IsEnvelope(payload) {
+		t.Fatalf("payload should be an envelope")
+	}
+	decoded, err := Decode(payload)
+	if err != nil {
+		t.Fatalf("decode fail: %v", err)
+	}
+	if decoded.ContentType != CONTENT_TYPE_JSON || decoded.BizType != "order" || decoded.ReplyTo != "packet_1" ||
+		decoded.ClientMsgId != "client_1" || decoded.SentAt != 1600000000000 || decoded.Header("trace") != "abc" {
+		t.Errorf("decoded envelope mismatch: %+v", decoded)
+	}
+	var body map[string]int
+	if err := decoded.DecodeJSON(&body); err != nil || body["count"] != 3 {
+		t.Errorf("decode json body fail: %v, %v", err, body)
+	}
+}
+
+func TestDecodePlainPayload(t *testing.T) {
+	if _, err := Decode([]byte("hello")); err != ErrInvalidEnvelope {
+		t.Errorf("plain payload should not be decoded, got %v", err)
+	}
+	if text := NewText("你好").Text(); text != "你好" {
+		t.Errorf("text mismatch: %v", text)
+	}
+}
@@ -0,0 +1,87 @@
+package fragment
+
+import (
+	"errors"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/byte"
+)
+
+/**
+ * 分片格式(大端):
+ * | magic(2) | version(1) | idLen(1) | msgId(idLen) | index(2) | total(2) | data |
+ * magic用于区分普通消息与分片，非SDK发送的消息不会被误判为分片。
+ */
+const (
+	MAGIC        uint16 = 0x4d46
+	VERSION      byte   = 1
+	MAX_FRAGMENT int    = 0xffff
+	headerLength int    = 8
+)
+
+var (
+	ErrTooManyFragments = errors.New("fragment: too many fragments")
+	ErrInvalidSize      = errors.New("fragment: invalid fragment size")
+)
+
+type Fragment struct {
+	MsgId string
+	Index int
+	Total int
+	Data  []byte
+}
+
+// 将payload切分为若干分片，每个分片的数据部分不超过size字节
+func Split(msgId string, payload []byte, size int) ([][]byte, error) {
+	if size <= 0 || len(msgId) == 0 || len(msgId) > 0xff {
+		return nil, ErrInvalidSize
+	}
+	total := (len(payload) + size - 1) / size
+	if total == 0 {
+		total = 1
+	}
+	if total > MAX_FRAGMENT {
+		return nil, ErrTooManyFragments
+	}
+	fragments := make([][]byte, 0, total)
+	for index := 0; index < total; index++ {
+		from := index * size
+		to := from + size
+		if to > len(payload) {
+			to = len(payload)
+		}
+		fragments = append(fragments, Encode(&Fragment{MsgId: msgId, Index: index, Total: total, Data: payload[from:to]}))
+	}
+	return fragments, nil
+}
+
+func Encode(fragment *Fragment) []byte {
+	header := make([]byte, headerLength+len(fragment.MsgId))
+	byteutil.TransferUint16(&header, MAGIC, 0)
+	header[2] = VERSION
+	header[3] = byte(len(fragment.MsgId))
+	copy(header[4:], fragment.MsgId)
+	offset := 4 + len(fragment.MsgId)
+	byteutil.TransferUint16(&header, uint16(fragment.Index), offset)
+	byteutil.TransferUint16(&header, uint16(fragment.Total), offset+2)
+	return byteutil.Integrate(header, fragment.Data)
+}
+
+// 解析分片，payload不是合法分片时返回nil
+func Decode(payload []byte) *Fragment {
+	if len(payload) < headerLength || byteutil.GetUint16FromBytes(&payload, 0) != MAGIC || payload[2] != VERSION {
+		return nil
+	}
+	idLen := int(payload[3])
+	if idLen == 0 || len(payload) < headerLength+idLen {
+		return nil
+	}
+	offset := 4 + idLen
+	fragment := new(Fragment)
+	fragment.MsgId = string(payload[4:offset])
+	fragment.Index = int(byteutil.GetUint16FromBytes(&payload, offset))
+	fragment.Total = int(byteutil.GetUint16FromBytes(&payload, offset+2))
+	if fragment.Total == 0 || fragment.Index >= fragment.Total {
+		return nil
+	}
+	fragment.Data = payload[offset+4:]
+	return fragment
+}
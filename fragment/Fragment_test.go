@@ -0,0 +1,104 @@
+package fragment
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestSplitAndReassemble(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 100)
+	fragments, err := Split("msg_1", payload, 64)
+	if err != nil {
+		t.Fatalf("split fail: %v", err)
+	}
+	if len(fragments) != 16 {
+		t.Fatalf("expect 16 fragments, got %v", len(fragments))
+	}
+	reassembler := NewReassembler(1000, 0)
+	var result []byte
+	// 乱序并且重复投递
+	for i := len(fragments) - 1; i >= 0; i-- {
+		for j := 0; j < 2; j++ {
+			data, failure := reassembler.Add("p2p/Alice", Decode(fragments[i]), 0)
+			if failure != nil {
+				t.Fatalf("unexpected failure: %v", failure.Err)
+			}
+			if data != nil {
+				result = data
+			}
+		}
+	}
+	if !bytes.Equal(result, payload) {
+		t.Errorf("reassembled payload mismatch")
+	}
+	if reassembler.Size() != 0 {
+		t.Errorf("buffer should be released, size: %v", reassembler.Size())
+	}
+}
+
+func TestDecodeIgnoresPlainPayload(t *testing.T) {
+	if Decode([]byte("hello world!")) != nil {
+		t.Errorf("plain payload should not be decoded as fragment")
+	}
+	if Decode([]byte{0x4d, 0x46, VERSION, 3, 'a'}) != nil {
+		t.Errorf("truncated fragment should be rejected")
+	}
+}
+
+func TestReassembleLimits(t *testing.T) {
+	fragments, _ := Split("msg_2", make([]byte, 100), 10)
+	reassembler := NewReassembler(1000, 10*slotSize+25)
+	var failures []*Failure
+	for _, data := range fragments {
+		if _, failure := reassembler.Add("p2p/Bob", Decode(data), 0); failure != nil {
+			failures = append(failures, failure)
+		}
+	}
+	if len(failures) != 1 || failures[0].Err != ErrMemoryLimit || failures[0].Received != 2 {
+		t.Fatalf("expect exactly one memory limit failure, got %v", failures)
+	}
+
+	fragments, _ = Split("msg_3", make([]byte, 20), 10)
+	reassembler.Add("p2p/Bob", Decode(fragments[0]), 0)
+	if expired := reassembler.Expire(999); len(expired) != 0 {
+		t.Errorf("should not expire before timeout")
+	}
+	expired := reassembler.Expire(1000)
+	if len(expired) != 1 || expired[0].Err != ErrTimeout || expired[0].MsgId != "msg_3" {
+		t.Fatalf("expect one timeout failure, got %v", expired)
+	}
+	if data, failure := reassembler.Add("p2p/Bob", Decode(fragments[1]), 1001); data != nil || failure != nil {
+		t.Errorf("late fragment of a failed message should be ignored")
+	}
+}
+
+// 只发送空的第一个分片不能无限占用内存
+func TestReassembleRejectsFlood(t *testing.T) {
+	reassembler := NewReassembler(1000, 1024).SetFragmentSize(100)
+	huge := &Fragment{MsgId: "huge", Index: 0, Total: MAX_FRAGMENT}
+	if _, failure := reassembler.Add("p2p/Bob", huge, 0); failure == nil || failure.Err != ErrTooManyFragments {
+		t.Fatalf("expect too many fragments, got %v", failure)
+	}
+	if reassembler.Size() != 0 {
+		t.Errorf("rejected message should not be cached, size: %v", reassembler.Size())
+	}
+
+	reassembler = NewReassembler(1000, 0).SetMaxPartials(2, 3)
+	for i, key := range []string{"p2p/Bob", "p2p/Bob", "p2p/Bob", "p2p/Carol", "p2p/Dave"} {
+		_, failure := reassembler.Add(key, &Fragment{MsgId: strconv.Itoa(i), Index: 0, Total: 2}, 0)
+		if expectReject := i == 2 || i == 4; expectReject != (failure != nil) || (failure != nil && failure.Err != ErrTooManyPartials) {
+			t.Errorf("fragment %v of %v: unexpected failure %v", i, key, failure)
+		}
+	}
+	if reassembler.Size() != 3*2*slotSize {
+		t.Errorf("slots of pending messages should be counted, size: %v", reassembler.Size())
+	}
+	reassembler.Expire(1000)
+	if reassembler.Size() != 0 {
+		t.Errorf("expired messages should release their slots, size: %v", reassembler.Size())
+	}
+	if _, failure := reassembler.Add("p2p/Bob", &Fragment{MsgId: "next", Index: 0, Total: 2}, 1000); failure != nil {
+		t.Errorf("expired messages should not count toward the limits: %v", failure)
+	}
+}
@@ -0,0 +1,202 @@
+package fragment
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+const (
+	// 每个key同时重组的消息数
+	MAX_PARTIALS_PER_KEY int = 16
+	// 所有key同时重组的消息数
+	MAX_PARTIALS int = 1024
+)
+
+var (
+	ErrMemoryLimit     = errors.New("fragment: reassemble buffer is full")
+	ErrTimeout         = errors.New("fragment: reassemble timeout")
+	ErrMismatch        = errors.New("fragment: fragment count mismatch")
+	ErrTooManyPartials = errors.New("fragment: too many messages reassembling")
+)
+
+// 每个分片在partial.datas中占用的字节数，收到第一个分片时按total计入缓存大小
+const slotSize = int(unsafe.Sizeof([]byte(nil)))
+
+type partial struct {
+	key       string
+	msgId     string
+	total     int
+	received  int
+	size      int
+	datas     [][]byte
+	createdAt int64
+}
+
+// 重组失败的消息，每个消息只会报告一次
+type Failure struct {
+	Key      string
+	MsgId    string
+	Received int
+	Total    int
+	Err      error
+}
+
+/**
+ * 按key(通常为会话+发送方)重组分片，超过timeoutMs未收齐的消息会被丢弃，
+ * 缓存的分片数据和分片索引总量不超过maxBytes。
+ * 同时重组的消息数每个key不超过MAX_PARTIALS_PER_KEY，总共不超过MAX_PARTIALS。
+ */
+type Reassembler struct {
+	mu             sync.Mutex
+	timeoutMs      int64
+	maxBytes       int
+	fragmentSize   int
+	maxPartialsKey int
+	maxPartials    int
+	size           int
+	partials       map[string]*partial
+	keyPartials    map[string]int
+	finished       map[string]int64
+}
+
+func NewReassembler(timeoutMs int64, maxBytes int) *Reassembler {
+	this := new(Reassembler)
+	this.timeoutMs = timeoutMs
+	this.maxBytes = maxBytes
+	this.maxPartialsKey = MAX_PARTIALS_PER_KEY
+	this.maxPartials = MAX_PARTIALS
+	this.partials = make(map[string]*partial)
+	this.keyPartials = make(map[string]int)
+	this.finished = make(map[string]int64)
+	return this
+}
+
+// 发送端的分片大小，设置后分片数超过maxBytes/fragmentSize的消息直接被拒绝
+func (this *Reassembler) SetFragmentSize(fragmentSize int) *Reassembler {
+	this.fragmentSize = fragmentSize
+	return this
+}
+
+// 同时重组的消息数上限，小于等于0时使用默认值
+func (this *Reassembler) SetMaxPartials(perKey int, total int) *Reassembler {
+	if perKey > 0 {
+		this.maxPartialsKey = perKey
+	}
+	if total > 0 {
+		this.maxPartials = total
+	}
+	return this
+}
+
+/**
+ * 加入一个分片，收齐后返回完整的payload。
+ * 分片被拒绝时返回对应消息的Failure，该消息已缓存的分片同时被丢弃。
+ */
+func (this *Reassembler) Add(key string, fragment *Fragment, now int64) ([]byte, *Failure) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	fullKey := key + "\x00" + fragment.MsgId
+	if _, ok := this.finished[fullKey]; ok {
+		// 该消息已经重组完成或报告过失败，忽略重复或剩余的分片
+		return nil, nil
+	}
+	current, ok := this.partials[fullKey]
+	if !ok {
+		if fragment.Total == 1 {
+			return fragment.Data, nil
+		}
+		if err := this.admit(key, fragment.Total); err != nil {
+			this.finished[fullKey] = now
+			return nil, &Failure{Key: key, MsgId: fragment.MsgId, Total: fragment.Total, Err: err}
+		}
+		current = &partial{key: key, msgId: fragment.MsgId, total: fragment.Total, datas: make([][]byte, fragment.Total), createdAt: now}
+		this.partials[fullKey] = current
+		this.keyPartials[key] += 1
+		this.size += fragment.Total * slotSize
+	}
+	if current.total != fragment.Total {
+		return nil, this.drop(fullKey, ErrMismatch, now)
+	}
+	if current.datas[fragment.Index] != nil {
+		// 重复的分片
+		return nil, nil
+	}
+	if this.maxBytes > 0 && this.size+len(fragment.Data) > this.maxBytes {
+		return nil, this.drop(fullKey, ErrMemoryLimit, now)
+	}
+	data := make([]byte, len(fragment.Data))
+	copy(data, fragment.Data)
+	current.datas[fragment.Index] = data
+	current.received += 1
+	current.size += len(data)
+	this.size += len(data)
+	if current.received < current.total {
+		return nil, nil
+	}
+	payload := make([]byte, 0, current.size)
+	for _, data := range current.datas {
+		payload = append(payload, data...)
+	}
+	this.remove(fullKey, current)
+	this.finished[fullKey] = now
+	return payload, nil
+}
+
+// 检查能否开始重组一个有total个分片的消息
+func (this *Reassembler) admit(key string, total int) error {
+	if this.maxBytes > 0 && this.fragmentSize > 0 && total > (this.maxBytes+this.fragmentSize-1)/this.fragmentSize {
+		return ErrTooManyFragments
+	}
+	if this.keyPartials[key] >= this.maxPartialsKey || len(this.partials) >= this.maxPartials {
+		return ErrTooManyPartials
+	}
+	if this.maxBytes > 0 && this.size+total*slotSize > this.maxBytes {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+func (this *Reassembler) remove(fullKey string, current *partial) {
+	delete(this.partials, fullKey)
+	if this.keyPartials[current.key] -= 1; this.keyPartials[current.key] == 0 {
+		delete(this.keyPartials, current.key)
+	}
+	this.size -= current.size + current.total*slotSize
+}
+
+// 丢弃超时未收齐的消息
+func (this *Reassembler) Expire(now int64) []*Failure {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	var failures []*Failure
+	for fullKey, finishedAt := range this.finished {
+		if now-finishedAt >= this.timeoutMs {
+			delete(this.finished, fullKey)
+		}
+	}
+	for fullKey, current := range this.partials {
+		if now-current.createdAt < this.timeoutMs {
+			continue
+		}
+		failures = append(failures, this.drop(fullKey, ErrTimeout, now))
+	}
+	return failures
+}
+
+// 当前缓存的分片数据和分片索引字节数
+func (this *Reassembler) Size() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.size
+}
+
+func (this *Reassembler) drop(fullKey string, err error, now int64) *Failure {
+	current, ok := this.partials[fullKey]
+	if !ok {
+		return nil
+	}
+	this.remove(fullKey, current)
+	this.finished[fullKey] = now
+	return &Failure{Key: current.key, MsgId: current.msgId, Received: current.received, Total: current.total, Err: err}
+}
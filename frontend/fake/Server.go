@@ -0,0 +1,521 @@
+package fake
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	mrand "math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/cipher"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/frontend"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/inspect"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/id"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/string"
+	"github.com/golang/protobuf/proto"
+)
+
+var ErrStarted = errors.New("fake frontend: already started")
+
+/**
+ * AckDelay为服务端确认消息前的延迟，DropAckRate为不确认消息的概率，用于制造发送超时。
+ * MaxConnAge不为0时，连接存活超过该时长后被服务端关闭，用于制造重连。
+ */
+type Options struct {
+	AppId       int64
+	AppPackage  string
+	AckDelay    time.Duration
+	DropAckRate float64
+	MaxConnAge  time.Duration
+}
+
+type Stats struct {
+	Connections int64 `json:"connections"`
+	Accepted    int64 `json:"accepted"`
+	Binds       int64 `json:"binds"`
+	Messages    int64 `json:"messages"`
+	Delivered   int64 `json:"delivered"`
+	DroppedAcks int64 `json:"droppedAcks"`
+	Expired     int64 `json:"expired"`
+}
+
+type account struct {
+	appAccount string
+	uuid       int64
+	secKey     string
+	token      string
+}
+
+/**
+ * 进程内的MIMC前端，实现CONN、BIND、PING、UBND和SECMSG的P2P、P2T消息收发，
+ * 用于测试和压测，不校验BIND的sig，也不持久化消息。
+ * Server实现了frontend.IFrontendPeerFetcher，可以直接交给MCUser.PeerFetcher。
+ */
+type Server struct {
+	options  Options
+	listener net.Listener
+
+	mu       sync.Mutex
+	accounts map[string]*account
+	tokens   map[string]*account
+	sessions map[string]map[*conn]bool
+	topics   map[int64][]string
+	conns    map[*conn]bool
+	closed   bool
+
+	sequence int64
+	stats    Stats
+}
+
+func NewServer(options Options) *Server {
+	if options.AppPackage == "" {
+		options.AppPackage = "mimc-fake"
+	}
+	return &Server{
+		options:  options,
+		accounts: make(map[string]*account),
+		tokens:   make(map[string]*account),
+		sessions: make(map[string]map[*conn]bool),
+		topics:   make(map[int64][]string),
+		conns:    make(map[*conn]bool),
+	}
+}
+
+// 开始监听，addr为空时监听127.0.0.1的随机端口
+func (this *Server) Start(addr string) error {
+	if this.listener != nil {
+		return ErrStarted
+	}
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	this.listener = listener
+	go this.acceptRoutine()
+	return nil
+}
+
+func (this *Server) Addr() string {
+	if this.listener == nil {
+		return ""
+	}
+	return this.listener.Addr().String()
+}
+
+func (this *Server) FetchPeer() *frontend.Peer {
+	host, port, _ := net.SplitHostPort(this.Addr())
+	portNum, _ := strconv.Atoi(port)
+	return new(frontend.Peer).SetHost(host).SetPort(portNum)
+}
+
+// 设置群成员，P2T消息投递给发送者以外的在线成员
+func (this *Server) SetTopicMembers(topicId int64, accounts []string) *Server {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.topics[topicId] = append([]string{}, accounts...)
+	return this
+}
+
+func (this *Server) Stats() Stats {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return Stats{
+		Connections: int64(len(this.conns)),
+		Accepted:    atomic.LoadInt64(&this.stats.Accepted),
+		Binds:       atomic.LoadInt64(&this.stats.Binds),
+		Messages:    atomic.LoadInt64(&this.stats.Messages),
+		Delivered:   atomic.LoadInt64(&this.stats.Delivered),
+		DroppedAcks: atomic.LoadInt64(&this.stats.DroppedAcks),
+		Expired:     atomic.LoadInt64(&this.stats.Expired),
+	}
+}
+
+// 停止监听并关闭所有连接
+func (this *Server) Close() error {
+	this.mu.Lock()
+	this.closed = true
+	conns := make([]*conn, 0, len(this.conns))
+	for c := range this.conns {
+		conns = append(conns, c)
+	}
+	this.mu.Unlock()
+	for _, c := range conns {
+		c.tcpConn.Close()
+	}
+	if this.listener == nil {
+		return nil
+	}
+	return this.listener.Close()
+}
+
+/**
+ * 返回appAccount在此服务器上登录使用的Token，响应格式与MIMC token接口相同。
+ * 同一个appAccount多次调用返回相同的uuid和token。
+ */
+func (this *Server) Token(appAccount string) *Token {
+	return &Token{server: this, appAccount: appAccount}
+}
+
+type Token struct {
+	server     *Server
+	appAccount string
+}
+
+func (this *Token) FetchToken() *string {
+	account := this.server.account(this.appAccount)
+	response, _ := json.Marshal(map[string]interface{}{
+		"code":    200,
+		"message": "success",
+		"data": map[string]interface{}{
+			"appId":             strconv.FormatInt(this.server.options.AppId, 10),
+			"appPackage":        this.server.options.AppPackage,
+			"appAccount":        account.appAccount,
+			"miChid":            cnst.MIMC_CHID,
+			"miUserId":          strconv.FormatInt(account.uuid, 10),
+			"miUserSecurityKey": account.secKey,
+			"token":             account.token,
+		},
+	})
+	token := string(response)
+	return &token
+}
+
+func (this *Server) account(appAccount string) *account {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if existing, ok := this.accounts[appAccount]; ok {
+		return existing
+	}
+	created := &account{
+		appAccount: appAccount,
+		uuid:       int64(10000 + len(this.accounts)),
+		secKey:     base64.StdEncoding.EncodeToString(randomBytes(16)),
+		token:      hex.EncodeToString(randomBytes(16)),
+	}
+	this.accounts[appAccount] = created
+	this.tokens[created.token] = created
+	return created
+}
+
+func randomBytes(n int) []byte {
+	data := make([]byte, n)
+	rand.Read(data)
+	return data
+}
+
+func (this *Server) acceptRoutine() {
+	for {
+		tcpConn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{server: this, tcpConn: tcpConn}
+		this.mu.Lock()
+		if this.closed {
+			this.mu.Unlock()
+			tcpConn.Close()
+			return
+		}
+		this.conns[c] = true
+		this.mu.Unlock()
+		atomic.AddInt64(&this.stats.Accepted, 1)
+		if this.options.MaxConnAge > 0 {
+			time.AfterFunc(this.options.MaxConnAge, func() {
+				if c.close() {
+					atomic.AddInt64(&this.stats.Expired, 1)
+				}
+			})
+		}
+		go c.readRoutine()
+	}
+}
+
+// 登录成功后连接才能收发消息，account和resource在锁内设置，之后不再修改
+func (this *Server) bind(c *conn, token, resource string) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	bound, ok := this.tokens[token]
+	if !ok || c.account != nil {
+		return ok
+	}
+	c.account, c.resource = bound, resource
+	if this.sessions[bound.appAccount] == nil {
+		this.sessions[bound.appAccount] = make(map[*conn]bool)
+	}
+	this.sessions[bound.appAccount][c] = true
+	return true
+}
+
+func (this *Server) remove(c *conn) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	delete(this.conns, c)
+	if c.account != nil {
+		delete(this.sessions[c.account.appAccount], c)
+	}
+}
+
+// appAccount的所有在线连接
+func (this *Server) online(appAccount string) []*conn {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	conns := make([]*conn, 0, len(this.sessions[appAccount]))
+	for c := range this.sessions[appAccount] {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func (this *Server) members(topicId int64) []string {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.topics[topicId]
+}
+
+func (this *Server) nextSequence() int64 {
+	return atomic.AddInt64(&this.sequence, 1)
+}
+
+func (this *Server) dropAck() bool {
+	return this.options.DropAckRate > 0 && mrand.Float64() < this.options.DropAckRate
+}
+
+type conn struct {
+	server  *Server
+	tcpConn net.Conn
+
+	writeLock sync.Mutex
+	rc4Key    []byte
+	account   *account
+	resource  string
+	closed    bool
+}
+
+func (this *conn) readRoutine() {
+	defer this.close()
+	reader := bufio.NewReader(this.tcpConn)
+	for {
+		data, err := inspect.ReadFrame(reader)
+		if err != nil {
+			return
+		}
+		headLen, bodyLen := int(cnst.V6_HEAD_LENGTH), len(data)-int(cnst.V6_HEAD_LENGTH)-cnst.V6_CRC_LENGTH
+		head, body, crc := data[:headLen], data[headLen:headLen+bodyLen], data[headLen+bodyLen:]
+		secKey := ""
+		if this.account != nil {
+			secKey = this.account.secKey
+		}
+		v6Packet := packet.ParseBytesToPacket(&head, &body, &crc, &this.rc4Key, &secKey)
+		if v6Packet == nil {
+			log.GetLogger().Warn("[fake] parse packet fail, close conn.")
+			return
+		}
+		if !this.handle(v6Packet) {
+			return
+		}
+	}
+}
+
+// 处理一个客户端的包，返回false时关闭连接
+func (this *conn) handle(v6Packet *packet.MIMCV6Packet) bool {
+	header := v6Packet.GetHeader()
+	if header == nil || header.GetCmd() == cnst.CMD_PING {
+		this.write(packet.NewV6Packet())
+		return true
+	}
+	switch header.GetCmd() {
+	case cnst.CMD_CONN:
+		connReq := new(ims.XMMsgConn)
+		if proto.Unmarshal(v6Packet.GetPayload(), connReq) != nil {
+			return false
+		}
+		challenge := hex.EncodeToString(randomBytes(16))
+		payload, _ := proto.Marshal(&ims.XMMsgConnResp{Challenge: &challenge})
+		this.write(packet.NewV6Packet().Header(this.responseHeader(header)).Payload(payload))
+		this.writeLock.Lock()
+		this.rc4Key = rc4Key(connReq.GetUdid(), challenge)
+		this.writeLock.Unlock()
+	case cnst.CMD_BIND:
+		bindReq := new(ims.XMMsgBind)
+		if proto.Unmarshal(v6Packet.GetPayload(), bindReq) != nil {
+			return false
+		}
+		bindResp := &ims.XMMsgBindResp{Result: proto.Bool(true)}
+		if this.server.bind(this, bindReq.GetToken(), header.GetResource()) {
+			atomic.AddInt64(&this.server.stats.Binds, 1)
+		} else {
+			bindResp = &ims.XMMsgBindResp{Result: proto.Bool(false), ErrorType: proto.String("token-invalid"), ErrorReason: proto.String("unknown token"), ErrorDesc: proto.String("token is not issued by this server")}
+		}
+		payload, _ := proto.Marshal(bindResp)
+		this.write(packet.NewV6Packet().Header(this.responseHeader(header)).Payload(payload))
+	case cnst.CMD_UNBIND:
+		this.write(packet.NewV6Packet().Header(this.responseHeader(header)))
+		return false
+	case cnst.CMD_SECMSG:
+		if this.account == nil {
+			return false
+		}
+		mimcPacket := new(mimc.MIMCPacket)
+		if proto.Unmarshal(v6Packet.GetPayload(), mimcPacket) != nil {
+			return false
+		}
+		this.handleSecMsg(mimcPacket)
+	}
+	return true
+}
+
+func (this *conn) handleSecMsg(mimcPacket *mimc.MIMCPacket) {
+	var targets []*conn
+	switch mimcPacket.GetType() {
+	case mimc.MIMC_MSG_TYPE_P2P_MESSAGE:
+		p2pMessage := new(mimc.MIMCP2PMessage)
+		if proto.Unmarshal(mimcPacket.Payload, p2pMessage) != nil {
+			return
+		}
+		targets = this.server.online(p2pMessage.GetTo().GetAppAccount())
+	case mimc.MIMC_MSG_TYPE_P2T_MESSAGE:
+		p2tMessage := new(mimc.MIMCP2TMessage)
+		if proto.Unmarshal(mimcPacket.Payload, p2tMessage) != nil {
+			return
+		}
+		for _, member := range this.server.members(p2tMessage.GetTo().GetTopicId()) {
+			if member != this.account.appAccount {
+				targets = append(targets, this.server.online(member)...)
+			}
+		}
+	default:
+		// SEQUENCE_ACK等不需要响应
+		return
+	}
+	atomic.AddInt64(&this.server.stats.Messages, 1)
+	sequence, timestamp := this.server.nextSequence(), time.Now().UnixNano()/1e6
+	for _, target := range targets {
+		if target.deliver(mimcPacket, sequence, timestamp) {
+			atomic.AddInt64(&this.server.stats.Delivered, 1)
+		}
+	}
+	if this.server.dropAck() {
+		atomic.AddInt64(&this.server.stats.DroppedAcks, 1)
+		return
+	}
+	ack, _ := proto.Marshal(&mimc.MIMCPacketAck{
+		PacketId:  mimcPacket.PacketId,
+		Uuid:      proto.Int64(this.account.uuid),
+		Resource:  proto.String(this.resource),
+		Sequence:  proto.Int64(sequence),
+		Timestamp: proto.Int64(timestamp),
+	})
+	ackPacket := this.secMsg(mimc.MIMC_MSG_TYPE_PACKET_ACK, ack)
+	if this.server.options.AckDelay > 0 {
+		time.AfterFunc(this.server.options.AckDelay, func() { this.write(ackPacket) })
+		return
+	}
+	this.write(ackPacket)
+}
+
+// 把消息以COMPOUND包投递给连接，连接尚未登录时返回false
+func (this *conn) deliver(message *mimc.MIMCPacket, sequence, timestamp int64) bool {
+	if this.account == nil {
+		return false
+	}
+	packetList, _ := proto.Marshal(&mimc.MIMCPacketList{
+		Uuid:        proto.Int64(this.account.uuid),
+		Resource:    proto.String(this.resource),
+		MaxSequence: proto.Int64(sequence),
+		Packets: []*mimc.MIMCPacket{{
+			PacketId:  message.PacketId,
+			Package:   message.Package,
+			Sequence:  proto.Int64(sequence),
+			Timestamp: proto.Int64(timestamp),
+			Type:      message.Type,
+			Payload:   message.Payload,
+		}},
+	})
+	return this.write(this.secMsg(mimc.MIMC_MSG_TYPE_COMPOUND, packetList))
+}
+
+func (this *conn) secMsg(msgType mimc.MIMC_MSG_TYPE, payload []byte) *packet.MIMCV6Packet {
+	mimcPacket, _ := proto.Marshal(&mimc.MIMCPacket{
+		PacketId: id.Generate(),
+		Package:  proto.String(this.server.options.AppPackage),
+		Type:     msgType.Enum(),
+		Payload:  payload,
+	})
+	header := &ims.ClientHeader{
+		Cmd:     proto.String(cnst.CMD_SECMSG),
+		Id:      id.Generate(),
+		Chid:    proto.Int32(cnst.MIMC_CHID),
+		Uuid:    proto.Int64(this.account.uuid),
+		Server:  proto.String(cnst.MIMC_SERVER),
+		Cipher:  proto.Int32(cnst.CIPHER_RC4),
+		DirFlag: ims.ClientHeader_SC_REQ.Enum(),
+	}
+	return packet.NewV6Packet().Header(header).Payload(mimcPacket)
+}
+
+func (this *conn) responseHeader(request *ims.ClientHeader) *ims.ClientHeader {
+	return &ims.ClientHeader{
+		Cmd:      request.Cmd,
+		Id:       request.Id,
+		Chid:     request.Chid,
+		Uuid:     request.Uuid,
+		Resource: request.Resource,
+		Server:   request.Server,
+		Cipher:   proto.Int32(cnst.CIPHER_NONE),
+		DirFlag:  ims.ClientHeader_SC_RESP.Enum(),
+	}
+}
+
+func (this *conn) write(v6Packet *packet.MIMCV6Packet) bool {
+	this.writeLock.Lock()
+	defer this.writeLock.Unlock()
+	if this.closed {
+		return false
+	}
+	var payloadKey []byte
+	if header := v6Packet.GetHeader(); header != nil && this.account != nil {
+		payloadKey = cipher.GenerateKeyForRC4(&this.account.secKey, header.Id)
+	}
+	data := v6Packet.Bytes(this.rc4Key, payloadKey)
+	if _, err := this.tcpConn.Write(data); err != nil {
+		return false
+	}
+	return true
+}
+
+// 关闭连接，已经关闭时返回false
+func (this *conn) close() bool {
+	this.writeLock.Lock()
+	if this.closed {
+		this.writeLock.Unlock()
+		return false
+	}
+	this.closed = true
+	this.writeLock.Unlock()
+	this.tcpConn.Close()
+	this.server.remove(this)
+	return true
+}
+
+// 与MIMCConnection.SetChallengeAndRc4Key相同
+func rc4Key(udid, challenge string) []byte {
+	halfUdid := strutil.Substring(&udid, len(udid)/2)
+	halfChallenge := strutil.Substring(&challenge, len(challenge)/2)
+	key := strutil.Concat(&halfChallenge, &halfUdid)
+	return cipher.Encrypt([]byte(challenge), []byte(key))
+}
@@ -0,0 +1,119 @@
+package fake
+
+import (
+	"container/list"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/message"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/log"
+)
+
+type collector struct {
+	mu       sync.Mutex
+	messages []string
+	acks     []string
+}
+
+func (this *collector) HandleMessage(packets *list.List) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.messages = append(this.messages, string(ele.Value.(*msg.P2PMessage).Payload()))
+	}
+}
+
+func (this *collector) HandleGroupMessage(packets *list.List) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for ele := packets.Front(); ele != nil; ele = ele.Next() {
+		this.messages = append(this.messages, string(ele.Value.(*msg.P2TMessage).Payload()))
+	}
+}
+
+func (this *collector) HandleServerAck(packetId *string, sequence, timestamp *int64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.acks = append(this.acks, *packetId)
+}
+
+func (this *collector) HandleSendMessageTimeout(message *msg.P2PMessage)      {}
+func (this *collector) HandleSendGroupMessageTimeout(message *msg.P2TMessage) {}
+
+func (this *collector) HandleChange(isOnline bool, errType, errReason, errDescription *string) {}
+
+func (this *collector) count() (int, int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return len(this.messages), len(this.acks)
+}
+
+func login(t *testing.T, server *Server, appAccount string) (*mimc.MCUser, *collector) {
+	handler := new(collector)
+	user := mimc.NewUser(appAccount).SetPeerFetcher(server).RegisterTokenDelegate(server.Token(appAccount)).RegisterMessageDelegate(handler).RegisterStatusDelegate(handler)
+	user.InitAndSetup()
+	if !user.Login() {
+		t.Fatalf("%v login fail", appAccount)
+	}
+	waitFor(t, appAccount+" online", func() bool { return user.Status() == mimc.Online })
+	return user, handler
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(20 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %v", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestServer(t *testing.T) {
+	log.SetLogger(log.NewNopLogger())
+	server := NewServer(Options{AppId: 1})
+	if err := server.Start(""); err != nil {
+		t.Fatalf("start fail: %v", err)
+	}
+	defer server.Close()
+	alice, aliceHandler := login(t, server, "alice")
+	_, bobHandler := login(t, server, "bob")
+
+	alice.SendMessage("bob", []byte("hello bob"))
+	waitFor(t, "p2p message", func() bool {
+		received, _ := bobHandler.count()
+		_, acked := aliceHandler.count()
+		return received == 1 && acked == 1
+	})
+	topicId := int64(100)
+	server.SetTopicMembers(topicId, []string{"alice", "bob"})
+	alice.SendGroupMessage(&topicId, []byte("hello topic"))
+	waitFor(t, "p2t message", func() bool {
+		received, _ := bobHandler.count()
+		_, acked := aliceHandler.count()
+		return received == 2 && acked == 2
+	})
+	if bobHandler.messages[0] != "hello bob" || bobHandler.messages[1] != "hello topic" {
+		t.Errorf("unexpected messages: %v", bobHandler.messages)
+	}
+	if stats := server.Stats(); stats.Binds != 2 || stats.Messages != 2 || stats.Delivered != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestServerExpiresConns(t *testing.T) {
+	log.SetLogger(log.NewNopLogger())
+	server := NewServer(Options{MaxConnAge: 2 * time.Second})
+	if err := server.Start(""); err != nil {
+		t.Fatalf("start fail: %v", err)
+	}
+	defer server.Close()
+	user, _ := login(t, server, "carol")
+	// 连接被关闭后用同一个peerFetcher重连并重新登录
+	waitFor(t, "relogin", func() bool { return server.Stats().Binds >= 2 && user.Status() == mimc.Online })
+	if server.Stats().Expired == 0 {
+		t.Errorf("conn should be expired: %+v", server.Stats())
+	}
+}
@@ -0,0 +1,161 @@
+package history
+
+import (
+	"net/http"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/rest"
+)
+
+const (
+	DEFAULT_COUNT int = 20
+	MAX_COUNT     int = 100
+)
+
+var (
+	ErrNoToken         = rest.ErrNoToken
+	ErrInvalidResponse = rest.ErrInvalidResponse
+)
+
+// 服务端返回的错误
+type Error = rest.Error
+
+// 服务端保存的一条历史消息，payload为发送时的原始字节
+type Message struct {
+	PacketId    string `json:"packetId"`
+	Sequence    int64  `json:"sequence,string"`
+	FromAccount string `json:"fromAccount"`
+	ToAccount   string `json:"toAccount,omitempty"`
+	TopicId     int64  `json:"topicId,string,omitempty"`
+	Timestamp   int64  `json:"ts,string"`
+	Payload     []byte `json:"payload"`
+	BizType     string `json:"bizType,omitempty"`
+}
+
+/**
+ * 历史消息查询条件，时间单位为毫秒，为0的时间不限制，为nil的sequence不限制。
+ * Reverse为true时从最新的消息开始向前查询，结果按从新到旧排列。
+ */
+type Query struct {
+	StartTime   int64
+	EndTime     int64
+	MinSequence *int64
+	MaxSequence *int64
+	Count       int
+	Reverse     bool
+}
+
+type Page struct {
+	Messages []*Message
+	HasMore  bool
+}
+
+/**
+ * 返回查询下一页的条件，没有更多消息时返回nil。
+ * 以最后一条消息的sequence作为游标，服务端没有返回sequence时退化为按时间翻页，
+ * 时间也没有返回时无法继续翻页，返回nil。
+ */
+func (this *Page) Next(query Query) *Query {
+	if !this.HasMore || len(this.Messages) == 0 {
+		return nil
+	}
+	last := this.Messages[len(this.Messages)-1]
+	next := query
+	switch {
+	case last.Sequence != 0 && query.Reverse:
+		next.EndTime = last.Timestamp
+		sequence := last.Sequence - 1
+		next.MaxSequence = &sequence
+	case last.Sequence != 0:
+		next.StartTime = last.Timestamp
+		sequence := last.Sequence + 1
+		next.MinSequence = &sequence
+	case last.Timestamp == 0:
+		return nil
+	case query.Reverse:
+		next.EndTime = last.Timestamp - 1
+	default:
+		next.StartTime = last.Timestamp + 1
+	}
+	return &next
+}
+
+type request struct {
+	FromAccount string `json:"fromAccount,omitempty"`
+	ToAccount   string `json:"toAccount,omitempty"`
+	Account     string `json:"account,omitempty"`
+	TopicId     int64  `json:"topicId,string,omitempty"`
+	UtcFromTime int64  `json:"utcFromTime,string,omitempty"`
+	UtcToTime   int64  `json:"utcToTime,string,omitempty"`
+	MinSequence *int64 `json:"minSequence,string,omitempty"`
+	MaxSequence *int64 `json:"maxSequence,string,omitempty"`
+	Count       int    `json:"count"`
+	Reverse     bool   `json:"reverse,omitempty"`
+}
+
+func newRequest(query Query) *request {
+	count := query.Count
+	if count <= 0 {
+		count = DEFAULT_COUNT
+	} else if count > MAX_COUNT {
+		count = MAX_COUNT
+	}
+	return &request{
+		UtcFromTime: query.StartTime,
+		UtcToTime:   query.EndTime,
+		MinSequence: query.MinSequence,
+		MaxSequence: query.MaxSequence,
+		Count:       count,
+		Reverse:     query.Reverse,
+	}
+}
+
+type response struct {
+	Messages []*Message `json:"messages"`
+	HasMore  bool       `json:"hasMore"`
+}
+
+/**
+ * MIMC历史消息接口的客户端，请求头中携带用户登录使用的token。
+ * token在每次请求时通过tokenFunc获取，重新登录后不需要重建客户端。
+ */
+type Client struct {
+	rest *rest.Client
+}
+
+func NewClient(baseUrl string, tokenFunc func() string) *Client {
+	return &Client{rest: rest.NewClient(baseUrl, tokenFunc)}
+}
+
+func (this *Client) SetHttpClient(httpClient *http.Client) *Client {
+	this.rest.SetHttpClient(httpClient)
+	return this
+}
+
+// 查询appAccount与peer之间的单聊消息，包括双方发送的消息
+func (this *Client) P2P(appAccount string, peer string, query Query) (*Page, error) {
+	req := newRequest(query)
+	req.FromAccount = appAccount
+	req.ToAccount = peer
+	return this.query("/api/msg/p2p/query/", req)
+}
+
+// 查询topicId的群聊消息，appAccount需要是群成员
+func (this *Client) P2T(appAccount string, topicId int64, query Query) (*Page, error) {
+	req := newRequest(query)
+	req.Account = appAccount
+	req.TopicId = topicId
+	return this.query("/api/msg/p2t/query/", req)
+}
+
+func (this *Client) query(path string, req *request) (*Page, error) {
+	resp := new(response)
+	if err := this.rest.Do(http.MethodPost, path, req, resp); err != nil {
+		return nil, err
+	}
+	for _, message := range resp.Messages {
+		if message == nil || len(message.PacketId) == 0 {
+			return nil, ErrInvalidResponse
+		}
+	}
+	return &Page{Messages: resp.Messages, HasMore: resp.HasMore}, nil
+}
@@ -0,0 +1,96 @@
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// 模拟MIMC历史消息接口，按sequence和时间过滤并分页
+type fakeServer struct {
+	messages []*Message
+	requests []*request
+}
+
+func (this *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := new(request)
+	json.NewDecoder(r.Body).Decode(req)
+	this.requests = append(this.requests, req)
+	var matched []*Message
+	for _, message := range this.messages {
+		if req.UtcFromTime > 0 && message.Timestamp < req.UtcFromTime || req.UtcToTime > 0 && message.Timestamp > req.UtcToTime {
+			continue
+		}
+		if req.MinSequence != nil && message.Sequence < *req.MinSequence || req.MaxSequence != nil && message.Sequence > *req.MaxSequence {
+			continue
+		}
+		matched = append(matched, message)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Sequence < matched[j].Sequence != req.Reverse
+	})
+	hasMore := len(matched) > req.Count
+	if hasMore {
+		matched = matched[:req.Count]
+	}
+	body, _ := json.Marshal(map[string]interface{}{"code": 200, "message": "success", "data": response{Messages: matched, HasMore: hasMore}})
+	w.Write(body)
+}
+
+func TestClientPaging(t *testing.T) {
+	server := &fakeServer{}
+	for i := int64(1); i <= 5; i++ {
+		server.messages = append(server.messages, &Message{PacketId: "p" + strconv.FormatInt(i, 10), Sequence: i, FromAccount: "Bob", ToAccount: "Alice", Timestamp: 1000, Payload: []byte("hi")})
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	client := NewClient(httpServer.URL, func() string { return "token" })
+
+	query := &Query{Count: 2, Reverse: true}
+	var ids []string
+	for query != nil {
+		page, err := client.P2P("Alice", "Bob", *query)
+		if err != nil {
+			t.Fatalf("query fail: %v", err)
+		}
+		for _, message := range page.Messages {
+			ids = append(ids, message.PacketId)
+		}
+		query = page.Next(*query)
+	}
+	if len(ids) != 5 || ids[0] != "p5" || ids[4] != "p1" {
+		t.Errorf("reverse paging mismatch: %v", ids)
+	}
+	if req := server.requests[1]; req.FromAccount != "Alice" || req.ToAccount != "Bob" || req.MaxSequence == nil || *req.MaxSequence != 3 || req.Count != 2 {
+		t.Errorf("unexpected second request: %+v", req)
+	}
+
+	minSequence := int64(4)
+	page, err := client.P2T("Alice", 100, Query{MinSequence: &minSequence})
+	if err != nil || len(page.Messages) != 2 || page.HasMore || page.Next(Query{}) != nil {
+		t.Fatalf("forward query mismatch: %+v, %v", page, err)
+	}
+	if req := server.requests[len(server.requests)-1]; req.Account != "Alice" || req.TopicId != 100 || req.Count != DEFAULT_COUNT {
+		t.Errorf("unexpected p2t request: %+v", req)
+	}
+}
+
+func TestPageNext(t *testing.T) {
+	// 倒序翻到sequence为1的消息之后，下一页的MaxSequence为0而不是不限制
+	page := &Page{Messages: []*Message{{PacketId: "p1", Sequence: 1, Timestamp: 1000}}, HasMore: true}
+	if next := page.Next(Query{Reverse: true}); next == nil || next.MaxSequence == nil || *next.MaxSequence != 0 {
+		t.Errorf("reverse cursor mismatch: %+v", next)
+	}
+	// 服务端没有返回sequence时按时间翻页
+	page.Messages[0].Sequence = 0
+	if next := page.Next(Query{}); next == nil || next.StartTime != 1001 || next.MinSequence != nil {
+		t.Errorf("time cursor mismatch: %+v", next)
+	}
+	page.Messages[0].Timestamp = 0
+	if next := page.Next(Query{Reverse: true}); next != nil {
+		t.Errorf("missing cursor should stop paging, got %+v", next)
+	}
+}
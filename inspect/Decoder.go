@@ -0,0 +1,345 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/cipher"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/byte"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/string"
+	"github.com/golang/protobuf/proto"
+)
+
+// 单帧包体的上限，超过时认为数据流已经错位
+const MAX_BODY_LENGTH int = cnst.V6_MAX_BODY_LENGTH
+
+var (
+	ErrBadMagic  = errors.New("inspect: bad magic")
+	ErrTruncated = errors.New("inspect: truncated frame")
+)
+
+/**
+ * 解密需要的密钥。包体的RC4密钥由连接的Udid和服务端下发的Challenge生成，
+ * SECMSG的payload密钥由SecKey和ClientHeader的id生成。
+ */
+type Keys struct {
+	Udid      string `json:"udid,omitempty"`
+	Challenge string `json:"challenge,omitempty"`
+	SecKey    string `json:"secKey,omitempty"`
+}
+
+/**
+ * V6帧解码器，按顺序解码同一个连接的帧。
+ * 没有指定Udid或Challenge时，从解出的CONN请求和响应中学习。
+ */
+type Decoder struct {
+	keys   Keys
+	rc4Key []byte
+	index  int
+}
+
+func NewDecoder(keys Keys) *Decoder {
+	this := new(Decoder)
+	this.SetKeys(keys)
+	return this
+}
+
+// 更新密钥，为空的字段保留原值
+func (this *Decoder) SetKeys(keys Keys) {
+	if keys.Udid != "" {
+		this.keys.Udid = keys.Udid
+	}
+	if keys.Challenge != "" {
+		this.keys.Challenge = keys.Challenge
+	}
+	if keys.SecKey != "" {
+		this.keys.SecKey = keys.SecKey
+	}
+	this.rc4Key = nil
+	if this.keys.Udid != "" && this.keys.Challenge != "" {
+		this.rc4Key = rc4Key(this.keys.Udid, this.keys.Challenge)
+	}
+}
+
+func (this *Decoder) Keys() Keys {
+	return this.keys
+}
+
+// 与MIMCConnection.SetChallengeAndRc4Key相同
+func rc4Key(udid, challenge string) []byte {
+	halfUdid := strutil.Substring(&udid, len(udid)/2)
+	halfChallenge := strutil.Substring(&challenge, len(challenge)/2)
+	key := strutil.Concat(&halfChallenge, &halfUdid)
+	return cipher.Encrypt([]byte(challenge), []byte(key))
+}
+
+// 读取一个完整帧(包头、包体和crc)的原始字节
+func ReadFrame(reader io.Reader) ([]byte, error) {
+	head := make([]byte, cnst.V6_HEAD_LENGTH)
+	if _, err := io.ReadFull(reader, head); err != nil {
+		return nil, err
+	}
+	if byteutil.GetUint16FromBytes(&head, cnst.V6_MAGIC_OFFSET) != cnst.MAGIC {
+		return nil, ErrBadMagic
+	}
+	bodyLen := byteutil.GetIntFromBytes(&head, cnst.V6_BODYLEN_OFFSET)
+	if bodyLen < 0 || bodyLen > MAX_BODY_LENGTH {
+		return nil, ErrBadMagic
+	}
+	frame := make([]byte, len(head)+bodyLen+cnst.V6_CRC_LENGTH)
+	copy(frame, head)
+	if _, err := io.ReadFull(reader, frame[len(head):]); err != nil {
+		return nil, ErrTruncated
+	}
+	return frame, nil
+}
+
+/**
+ * 依次解码原始TCP数据流中的帧，direction为DIRECTION_IN、DIRECTION_OUT或空。
+ * 数据流错位或不完整时返回错误，已经解出的帧已经交给callback。
+ */
+func (this *Decoder) DecodeStream(reader io.Reader, direction string, callback func(frame *Frame) error) error {
+	for {
+		data, err := ReadFrame(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := callback(this.Decode(data, direction)); err != nil {
+			return err
+		}
+	}
+}
+
+// 解码一个完整帧，direction用于区分CONN、BIND的请求和响应，未知时传空
+func (this *Decoder) Decode(data []byte, direction string) *Frame {
+	this.index += 1
+	frame := &Frame{Index: this.index, Direction: direction, Length: len(data)}
+	headLen := int(cnst.V6_HEAD_LENGTH)
+	if len(data) < headLen+cnst.V6_CRC_LENGTH {
+		frame.addError("frame too short: %d bytes", len(data))
+		return frame
+	}
+	frame.Magic = byteutil.GetUint16FromBytes(&data, cnst.V6_MAGIC_OFFSET)
+	frame.Version = byteutil.GetUint16FromBytes(&data, cnst.V6_VERSION_OFFSET)
+	if frame.Magic != cnst.MAGIC {
+		frame.addError("bad magic %#x", frame.Magic)
+	}
+	bodyLen := byteutil.GetIntFromBytes(&data, cnst.V6_BODYLEN_OFFSET)
+	if bodyLen != len(data)-headLen-cnst.V6_CRC_LENGTH {
+		frame.addError("body length %d does not match frame length %d", bodyLen, len(data))
+		return frame
+	}
+	crcBins := data[len(data)-cnst.V6_CRC_LENGTH:]
+	frame.CrcOk = byteutil.GetIntFromBytes(&crcBins, 0) == byteutil.Crc(data[:len(data)-cnst.V6_CRC_LENGTH])
+	if bodyLen == 0 {
+		return frame
+	}
+	body := data[headLen : headLen+bodyLen]
+	header, rest, ok := parseBody(body)
+	if !ok || header.GetCmd() != cnst.CMD_CONN {
+		if this.rc4Key == nil {
+			frame.BodyEncrypted = true
+			frame.addError("body is encrypted, udid and challenge are required")
+			return frame
+		}
+		plain := cipher.Encrypt(this.rc4Key, body)
+		if header, rest, ok = parseBody(plain); !ok {
+			frame.BodyEncrypted = true
+			frame.addError("can not parse body, wrong udid or challenge?")
+			return frame
+		}
+		frame.BodyEncrypted = true
+	}
+	frame.Header = header
+	this.decodePayload(frame, rest, direction, true)
+	return frame
+}
+
+// 解码已经解密的ClientHeader和payload，header为空时为PING
+func (this *Decoder) DecodePlain(header, payload []byte, direction string) *Frame {
+	this.index += 1
+	frame := &Frame{Index: this.index, Direction: direction, Length: len(header) + len(payload), CrcOk: true}
+	if len(header) == 0 {
+		// 与线上PING帧的长度一致
+		frame.Length = int(cnst.V6_HEAD_LENGTH) + cnst.V6_CRC_LENGTH
+		return frame
+	}
+	frame.Header = new(ims.ClientHeader)
+	if err := proto.Unmarshal(header, frame.Header); err != nil {
+		frame.Header = nil
+		frame.addError("can not parse header: %v", err)
+		return frame
+	}
+	this.decodePayload(frame, payload, direction, false)
+	return frame
+}
+
+// 解析包体头和ClientHeader，返回ClientHeader之后的payload
+func parseBody(body []byte) (*ims.ClientHeader, []byte, bool) {
+	bodyHeadLen := int(cnst.V6_BODY_HEADER_LENGTH)
+	if len(body) < bodyHeadLen || byteutil.GetUint16FromBytes(&body, cnst.V6_PAYLOADTYPE_OFFSET) != cnst.PAYLOAD_TYPE {
+		return nil, nil, false
+	}
+	headerLen := int(byteutil.GetUint16FromBytes(&body, cnst.V6_HEADERLEN_OFFSET))
+	payloadLen := byteutil.GetIntFromBytes(&body, cnst.V6_PAYLOADLEN_OFFSET)
+	if payloadLen < 0 || bodyHeadLen+headerLen+payloadLen != len(body) {
+		return nil, nil, false
+	}
+	header := new(ims.ClientHeader)
+	if err := proto.Unmarshal(body[bodyHeadLen:bodyHeadLen+headerLen], header); err != nil || header.Cmd == nil {
+		return nil, nil, false
+	}
+	return header, body[bodyHeadLen+headerLen:], true
+}
+
+func (this *Decoder) decodePayload(frame *Frame, payload []byte, direction string, encrypted bool) {
+	if len(payload) == 0 {
+		return
+	}
+	switch frame.Header.GetCmd() {
+	case cnst.CMD_SECMSG:
+		if encrypted && this.keys.SecKey == "" {
+			frame.addError("payload is encrypted, secKey is required")
+			return
+		}
+		if encrypted {
+			payloadKey := cipher.GenerateKeyForRC4(&this.keys.SecKey, frame.Header.Id)
+			payload = cipher.Encrypt(payloadKey, payload)
+		}
+		frame.Packet = decodePacket(payload)
+		if frame.Packet.raw == nil {
+			frame.addError("can not parse MIMCPacket, wrong secKey?")
+			frame.Packet = nil
+		}
+		return
+	case cnst.CMD_CONN:
+		frame.MessageType, frame.Message = decodeMessage(payload, direction, &ims.XMMsgConn{}, &ims.XMMsgConnResp{})
+		switch message := frame.Message.(type) {
+		case *ims.XMMsgConn:
+			if this.keys.Udid == "" && message.GetUdid() != "" {
+				this.SetKeys(Keys{Udid: message.GetUdid()})
+			}
+		case *ims.XMMsgConnResp:
+			if this.keys.Challenge == "" && message.GetChallenge() != "" {
+				this.SetKeys(Keys{Challenge: message.GetChallenge()})
+			}
+		}
+	case cnst.CMD_BIND:
+		frame.MessageType, frame.Message = decodeMessage(payload, direction, &ims.XMMsgBind{}, &ims.XMMsgBindResp{})
+	case cnst.CMD_PING:
+		frame.MessageType, frame.Message = decodeMessage(payload, "", &ims.XMMsgPing{})
+	case cnst.CMD_NOTIFY, cnst.CMD_KICK:
+		frame.MessageType, frame.Message = decodeMessage(payload, "", &ims.XMMsgNotify{})
+	}
+	if frame.Message == nil {
+		frame.addError("unknown payload of %v: %d bytes", frame.Header.GetCmd(), len(payload))
+	}
+}
+
+/**
+ * 按请求、响应的顺序尝试解析，方向已知时只解析对应类型。
+ * 方向未知时选择没有未知字段的类型。
+ */
+func decodeMessage(payload []byte, direction string, candidates ...proto.Message) (string, proto.Message) {
+	if len(candidates) == 2 && direction == DIRECTION_OUT {
+		candidates = candidates[:1]
+	} else if len(candidates) == 2 && direction == DIRECTION_IN {
+		candidates = candidates[1:]
+	}
+	var fallback proto.Message
+	for _, candidate := range candidates {
+		if err := proto.Unmarshal(payload, candidate); err != nil {
+			continue
+		}
+		if len(proto.MessageReflect(candidate).GetUnknown()) == 0 {
+			return messageType(candidate), candidate
+		}
+		if fallback == nil {
+			fallback = candidate
+		}
+	}
+	if fallback == nil {
+		return "", nil
+	}
+	return messageType(fallback), fallback
+}
+
+func messageType(message proto.Message) string {
+	return string(proto.MessageReflect(message).Descriptor().Name())
+}
+
+func decodePacket(data []byte) *Packet {
+	raw := new(mimc.MIMCPacket)
+	if err := proto.Unmarshal(data, raw); err != nil || raw.Type == nil {
+		return &Packet{}
+	}
+	packet := &Packet{
+		PacketId:  raw.GetPacketId(),
+		Package:   raw.GetPackage(),
+		Type:      raw.GetType().String(),
+		Sequence:  raw.GetSequence(),
+		Timestamp: raw.GetTimestamp(),
+		raw:       raw,
+	}
+	var content proto.Message
+	switch raw.GetType() {
+	case mimc.MIMC_MSG_TYPE_P2P_MESSAGE:
+		content = new(mimc.MIMCP2PMessage)
+	case mimc.MIMC_MSG_TYPE_P2T_MESSAGE:
+		content = new(mimc.MIMCP2TMessage)
+	case mimc.MIMC_MSG_TYPE_PACKET_ACK:
+		content = new(mimc.MIMCPacketAck)
+	case mimc.MIMC_MSG_TYPE_SEQUENCE_ACK:
+		content = new(mimc.MIMCSequenceAck)
+	case mimc.MIMC_MSG_TYPE_PULL:
+		content = new(mimc.MIMCPull)
+	case mimc.MIMC_MSG_TYPE_COMPOUND:
+		list := new(mimc.MIMCPacketList)
+		if err := proto.Unmarshal(raw.Payload, list); err != nil {
+			packet.Error = "can not parse MIMCPacketList: " + err.Error()
+			return packet
+		}
+		for _, inner := range list.Packets {
+			if inner == nil {
+				continue
+			}
+			innerData, _ := proto.Marshal(inner)
+			packet.Packets = append(packet.Packets, decodePacket(innerData))
+		}
+		list.Packets = nil
+		packet.Content = list
+		return packet
+	default:
+		packet.Error = "unknown packet type"
+		return packet
+	}
+	if err := proto.Unmarshal(raw.Payload, content); err != nil {
+		packet.Error = "can not parse content: " + err.Error()
+		return packet
+	}
+	packet.Content = content
+	return packet
+}
+
+// 十六进制文本(可以包含空白、换行和冒号)转换为字节
+func ParseHex(text []byte) ([]byte, error) {
+	cleaned := bytes.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' || r == ':' {
+			return -1
+		}
+		return r
+	}, text)
+	data := make([]byte, hex.DecodedLen(len(cleaned)))
+	if _, err := hex.Decode(data, cleaned); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
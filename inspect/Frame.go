@@ -0,0 +1,107 @@
+package inspect
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	DIRECTION_IN  string = "in"
+	DIRECTION_OUT string = "out"
+)
+
+/**
+ * 解码后的一个V6帧。Message为ClientHeader之后的payload，
+ * SECMSG时为Packet，其他命令为XMMsgConn、XMMsgBindResp等ims消息。
+ * 解码过程中的问题记录在Errors中，已经解出的部分仍然保留。
+ */
+type Frame struct {
+	Index         int               `json:"index"`
+	Direction     string            `json:"direction,omitempty"`
+	Time          int64             `json:"time,omitempty"`
+	Length        int               `json:"length"`
+	Magic         uint16            `json:"magic"`
+	Version       uint16            `json:"version"`
+	CrcOk         bool              `json:"crcOk"`
+	BodyEncrypted bool              `json:"bodyEncrypted"`
+	Header        *ims.ClientHeader `json:"header,omitempty"`
+	MessageType   string            `json:"messageType,omitempty"`
+	Message       proto.Message     `json:"message,omitempty"`
+	Packet        *Packet           `json:"packet,omitempty"`
+	Errors        []string          `json:"errors,omitempty"`
+}
+
+// SECMSG中的MIMCPacket，Content为按Type解出的内部消息，COMPOUND时内部包在Packets中
+type Packet struct {
+	PacketId  string        `json:"packetId,omitempty"`
+	Package   string        `json:"package,omitempty"`
+	Type      string        `json:"type"`
+	Sequence  int64         `json:"sequence,omitempty"`
+	Timestamp int64         `json:"timestamp,omitempty"`
+	Content   proto.Message `json:"content,omitempty"`
+	Packets   []*Packet     `json:"packets,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	raw       *mimc.MIMCPacket
+}
+
+func (this *Frame) addError(format string, args ...interface{}) {
+	this.Errors = append(this.Errors, fmt.Sprintf(format, args...))
+}
+
+func (this *Frame) Cmd() string {
+	if this.Header == nil {
+		if this.Length == 0 {
+			return ""
+		}
+		return "PING"
+	}
+	return this.Header.GetCmd()
+}
+
+// 多行的可读文本
+func (this *Frame) Text() string {
+	builder := new(strings.Builder)
+	fmt.Fprintf(builder, "#%d", this.Index)
+	if this.Direction != "" {
+		fmt.Fprintf(builder, " %v", this.Direction)
+	}
+	if this.Time > 0 {
+		fmt.Fprintf(builder, " %v", time.UnixMilli(this.Time).Format("2006-01-02 15:04:05.000"))
+	}
+	fmt.Fprintf(builder, " %v len=%d crc=%v", this.Cmd(), this.Length, map[bool]string{true: "ok", false: "BAD"}[this.CrcOk])
+	if this.BodyEncrypted {
+		builder.WriteString(" rc4")
+	}
+	builder.WriteString("\n")
+	if this.Header != nil {
+		fmt.Fprintf(builder, "  header: %v\n", proto.CompactTextString(this.Header))
+	}
+	if this.Message != nil {
+		fmt.Fprintf(builder, "  %v: %v\n", this.MessageType, proto.CompactTextString(this.Message))
+	}
+	if this.Packet != nil {
+		this.Packet.text(builder, "  ")
+	}
+	for _, err := range this.Errors {
+		fmt.Fprintf(builder, "  error: %v\n", err)
+	}
+	return builder.String()
+}
+
+func (this *Packet) text(builder *strings.Builder, indent string) {
+	fmt.Fprintf(builder, "%vpacket: %v id=%v seq=%v ts=%v\n", indent, this.Type, this.PacketId, this.Sequence, this.Timestamp)
+	if this.Content != nil {
+		fmt.Fprintf(builder, "%v  %v\n", indent, proto.CompactTextString(this.Content))
+	}
+	for _, packet := range this.Packets {
+		packet.text(builder, indent+"  ")
+	}
+	if this.Error != "" {
+		fmt.Fprintf(builder, "%v  error: %v\n", indent, this.Error)
+	}
+}
@@ -0,0 +1,143 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/cipher"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/packet"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	testUdid      = "0123456789abcdef"
+	testChallenge = "challenge-0001"
+	testSecKey    = "c2VjcmV0LWtleQ=="
+)
+
+func frameBytes(t testing.TB, cmd, id string, message proto.Message, bodyKey []byte) []byte {
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatalf("marshal fail: %v", err)
+	}
+	header := &ims.ClientHeader{Cmd: proto.String(cmd), Id: proto.String(id), Server: proto.String(cnst.MIMC_SERVER)}
+	secKey := testSecKey
+	return packet.NewV6Packet().Header(header).Payload(payload).Bytes(bodyKey, cipher.GenerateKeyForRC4(&secKey, &id))
+}
+
+func connection(t testing.TB) [][]byte {
+	bodyKey := rc4Key(testUdid, testChallenge)
+	p2p, _ := proto.Marshal(&mimc.MIMCP2PMessage{
+		From:    &mimc.MIMCUser{AppAccount: proto.String("Alice")},
+		To:      &mimc.MIMCUser{AppAccount: proto.String("Bob")},
+		Payload: []byte("hello"),
+	})
+	p2pPacket := &mimc.MIMCPacket{PacketId: proto.String("p1"), Type: mimc.MIMC_MSG_TYPE_P2P_MESSAGE.Enum(), Payload: p2p}
+	ack, _ := proto.Marshal(&mimc.MIMCPacketAck{PacketId: proto.String("p1"), Sequence: proto.Int64(7)})
+	return [][]byte{
+		frameBytes(t, cnst.CMD_CONN, "c1", &ims.XMMsgConn{Version: proto.Uint32(106), Udid: proto.String(testUdid)}, nil),
+		frameBytes(t, cnst.CMD_CONN, "c1", &ims.XMMsgConnResp{Challenge: proto.String(testChallenge)}, nil),
+		frameBytes(t, cnst.CMD_SECMSG, "s1", p2pPacket, bodyKey),
+		frameBytes(t, cnst.CMD_SECMSG, "s2", &mimc.MIMCPacket{PacketId: proto.String("a1"), Type: mimc.MIMC_MSG_TYPE_PACKET_ACK.Enum(), Payload: ack}, bodyKey),
+	}
+}
+
+func TestDecodeStreamLearnsKeys(t *testing.T) {
+	stream := bytes.Join(connection(t), nil)
+	var frames []*Frame
+	err := NewDecoder(Keys{SecKey: testSecKey}).DecodeStream(bytes.NewReader(stream), "", func(frame *Frame) error {
+		frames = append(frames, frame)
+		return nil
+	})
+	if err != nil || len(frames) != 4 {
+		t.Fatalf("expect 4 frames, got %v, %v", len(frames), err)
+	}
+	if frames[0].MessageType != "XMMsgConn" || frames[1].MessageType != "XMMsgConnResp" || frames[0].BodyEncrypted {
+		t.Errorf("conn frames mismatch: %v, %v", frames[0].Text(), frames[1].Text())
+	}
+	p2p := frames[2]
+	if !p2p.CrcOk || !p2p.BodyEncrypted || p2p.Packet == nil || p2p.Packet.Type != "P2P_MESSAGE" || len(p2p.Errors) > 0 {
+		t.Fatalf("p2p frame mismatch: %v", p2p.Text())
+	}
+	if text := p2p.Text(); !strings.Contains(text, `payload:"hello"`) || !strings.Contains(text, "Alice") {
+		t.Errorf("unexpected text: %v", text)
+	}
+	if ack := frames[3].Packet.Content.(*mimc.MIMCPacketAck); ack.GetSequence() != 7 {
+		t.Errorf("ack mismatch: %v", frames[3].Text())
+	}
+	if _, err := json.Marshal(p2p); err != nil {
+		t.Errorf("marshal frame fail: %v", err)
+	}
+}
+
+func TestDecodeWithoutKeys(t *testing.T) {
+	frames := connection(t)
+	frame := NewDecoder(Keys{}).Decode(frames[2], DIRECTION_OUT)
+	if !frame.BodyEncrypted || len(frame.Errors) == 0 || frame.Header != nil {
+		t.Errorf("expect encrypted body error: %v", frame.Text())
+	}
+	frame = NewDecoder(Keys{Udid: testUdid, Challenge: testChallenge}).Decode(frames[2], DIRECTION_OUT)
+	if frame.Header.GetId() != "s1" || frame.Packet != nil || len(frame.Errors) != 1 {
+		t.Errorf("expect header without packet: %v", frame.Text())
+	}
+	corrupted := append([]byte(nil), frames[3]...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if frame := NewDecoder(Keys{}).Decode(corrupted[:len(corrupted)-2], ""); len(frame.Errors) == 0 {
+		t.Errorf("truncated frame should report an error")
+	}
+}
+
+func TestReadSession(t *testing.T) {
+	frames := connection(t)
+	buffer := new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	encoder.Encode(&Record{Keys: &Keys{Udid: testUdid, Challenge: testChallenge, SecKey: testSecKey}})
+	encoder.Encode(&Record{Direction: DIRECTION_IN, Time: 1700000000000, Data: frames[3]})
+	buffer.WriteString("not json\n")
+	var decoded []*Frame
+	if err := ReadSession(buffer, NewDecoder(Keys{}), func(frame *Frame) error {
+		decoded = append(decoded, frame)
+		return nil
+	}); err != nil || len(decoded) != 1 {
+		t.Fatalf("expect 1 frame, got %v, %v", len(decoded), err)
+	}
+	if decoded[0].Packet == nil || decoded[0].Packet.Type != "PACKET_ACK" || decoded[0].Time != 1700000000000 {
+		t.Errorf("session frame mismatch: %v", decoded[0].Text())
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	data, err := ParseHex([]byte("c2 fe:00\n05"))
+	if err != nil || !bytes.Equal(data, []byte{0xc2, 0xfe, 0x00, 0x05}) {
+		t.Errorf("parse hex mismatch: %x, %v", data, err)
+	}
+}
+
+// 任意字节流都应解码出带错误信息的帧，而不是panic
+func FuzzDecode(f *testing.F) {
+	for _, frame := range connection(f) {
+		f.Add(frame, true)
+	}
+	f.Add([]byte{0xc2, 0xfe, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, false)
+	f.Fuzz(func(t *testing.T, data []byte, withKeys bool) {
+		keys := Keys{SecKey: testSecKey}
+		if withKeys {
+			keys.Udid, keys.Challenge = testUdid, testChallenge
+		}
+		decoder := NewDecoder(keys)
+		frame := decoder.Decode(data, "")
+		frame.Text()
+		if _, err := json.Marshal(frame); err != nil {
+			t.Errorf("marshal frame fail: %v", err)
+		}
+		decoder.DecodeStream(bytes.NewReader(data), DIRECTION_IN, func(frame *Frame) error {
+			frame.Text()
+			return nil
+		})
+	})
+}
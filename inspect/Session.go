@@ -0,0 +1,60 @@
+package inspect
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// 录制会话的用户，回放时用于恢复resource等信息
+type Account struct {
+	AppId      int64  `json:"appId,omitempty"`
+	AppAccount string `json:"appAccount,omitempty"`
+	Uuid       int64  `json:"uuid,omitempty"`
+	Resource   string `json:"resource,omitempty"`
+}
+
+/**
+ * 会话文件中的一行记录，会话文件为JSON Lines格式。
+ * Keys不为空时更新解码器的密钥，Data为一个完整的V6帧。
+ * Decoded为true时为SDK录制的已解密帧，Header为ClientHeader，Payload为解密后的payload，
+ * Header为空表示PING。
+ */
+type Record struct {
+	Direction string   `json:"dir,omitempty"`
+	Time      int64    `json:"ts,omitempty"`
+	Keys      *Keys    `json:"keys,omitempty"`
+	Account   *Account `json:"account,omitempty"`
+	Data      []byte   `json:"data,omitempty"`
+	Decoded   bool     `json:"decoded,omitempty"`
+	Header    []byte   `json:"header,omitempty"`
+	Payload   []byte   `json:"payload,omitempty"`
+}
+
+// 逐行读取会话文件并解码其中的帧，无法解析的行跳过
+func ReadSession(reader io.Reader, decoder *Decoder, callback func(frame *Frame) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*MAX_BODY_LENGTH)
+	for scanner.Scan() {
+		record := new(Record)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			continue
+		}
+		if record.Keys != nil {
+			decoder.SetKeys(*record.Keys)
+		}
+		var frame *Frame
+		if record.Decoded {
+			frame = decoder.DecodePlain(record.Header, record.Payload, record.Direction)
+		} else if len(record.Data) > 0 {
+			frame = decoder.Decode(record.Data, record.Direction)
+		} else {
+			continue
+		}
+		frame.Time = record.Time
+		if err := callback(frame); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -1,5 +1,9 @@
 package msg
 
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/envelope"
+)
+
 type P2PMessage struct {
 	packetId    *string
 	sequence    *int64
@@ -7,6 +11,7 @@ type P2PMessage struct {
 	fromAccount *string
 	toAccount   *string
 	payload     []byte
+	transient   bool
 }
 
 func NewP2pMsg(packetId, fromAccount, toAccount *string, sequence, timestamp *int64, payload []byte) *P2PMessage {
@@ -43,3 +48,38 @@ func (this *P2PMessage) ToAccount() *string {
 func (this *P2PMessage) Payload() []byte {
 	return this.payload
 }
+
+func (this *P2PMessage) SetTransient(transient bool) *P2PMessage {
+	this.transient = transient
+	return this
+}
+
+// 是否为不存储的消息(IsStore=false)，例如正在输入等信令
+func (this *P2PMessage) IsTransient() bool {
+	return this.transient
+}
+
+// 解析payload中的应用层信封，payload不是信封格式时返回nil
+func (this *P2PMessage) Envelope() *envelope.Envelope {
+	env, err := envelope.Decode(this.payload)
+	if err != nil {
+		return nil
+	}
+	return env
+}
+
+// 信封中的内容类型，payload不是信封格式时返回空字符串
+func (this *P2PMessage) ContentType() string {
+	if env := this.Envelope(); env != nil {
+		return env.ContentType
+	}
+	return ""
+}
+
+// 信封中的Body，payload不是信封格式时返回原始payload
+func (this *P2PMessage) Body() []byte {
+	if env := this.Envelope(); env != nil {
+		return env.Body
+	}
+	return this.payload
+}
@@ -1,5 +1,9 @@
 package msg
 
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/envelope"
+)
+
 type P2TMessage struct {
 	packetId    *string
 	sequence    *int64
@@ -7,6 +11,7 @@ type P2TMessage struct {
 	fromAccount *string
 	groupId     *int64
 	payload     []byte
+	transient   bool
 }
 
 func NewP2tMsg(packetId, fromAccount *string, sequence, timestamp, groupId *int64, payload []byte) *P2TMessage {
@@ -39,6 +44,41 @@ func (this *P2TMessage) FromAccount() *string {
 func (this *P2TMessage) Payload() []byte {
 	return this.payload
 }
+
+func (this *P2TMessage) SetTransient(transient bool) *P2TMessage {
+	this.transient = transient
+	return this
+}
+
+// 是否为不存储的消息(IsStore=false)，例如正在输入等信令
+func (this *P2TMessage) IsTransient() bool {
+	return this.transient
+}
 func (this *P2TMessage) GroupId() *int64 {
 	return this.groupId
 }
+
+// 解析payload中的应用层信封，payload不是信封格式时返回nil
+func (this *P2TMessage) Envelope() *envelope.Envelope {
+	env, err := envelope.Decode(this.payload)
+	if err != nil {
+		return nil
+	}
+	return env
+}
+
+// 信封中的内容类型，payload不是信封格式时返回空字符串
+func (this *P2TMessage) ContentType() string {
+	if env := this.Envelope(); env != nil {
+		return env.ContentType
+	}
+	return ""
+}
+
+// 信封中的Body，payload不是信封格式时返回原始payload
+func (this *P2TMessage) Body() []byte {
+	if env := this.Envelope(); env != nil {
+		return env.Body
+	}
+	return this.payload
+}
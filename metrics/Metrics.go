@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// 队列名称，用于SetQueueDepth
+const (
+	QueueMessageToSend    = "messageToSend"
+	QueueMessageToAck     = "messageToAck"
+	QueuePacketToCallback = "packetToCallback"
+)
+
+// 消息类型，用于IncSendTimeout
+const (
+	MsgTypeP2P = "p2p"
+	MsgTypeP2T = "p2t"
+)
+
+/**
+ * SDK在连接、发送、接收路径上上报的指标，实现必须是并发安全的。
+ * appAccount为产生该指标的用户，聚合型实现可以忽略它。
+ */
+type Metrics interface {
+	SetQueueDepth(appAccount, queue string, depth int)
+	ObserveAckLatency(appAccount string, latency time.Duration)
+	IncSendTimeout(appAccount, msgType string)
+	IncReconnect(appAccount string)
+	IncBindFailure(appAccount, errorType string)
+	IncCrcFailure(appAccount string)
+	AddBytesSent(appAccount string, n int)
+	AddBytesReceived(appAccount string, n int)
+	ObservePingRtt(appAccount string, rtt time.Duration)
+}
+
+var lock sync.RWMutex
+var metrics Metrics = nil
+
+// 设置新建用户默认使用的Metrics，传nil则不上报
+func SetMetrics(m Metrics) {
+	lock.Lock()
+	defer lock.Unlock()
+	metrics = m
+}
+
+func GetMetrics() Metrics {
+	lock.RLock()
+	defer lock.RUnlock()
+	if metrics == nil {
+		return nopMetrics{}
+	}
+	return metrics
+}
+
+type nopMetrics struct {
+}
+
+func NewNopMetrics() Metrics {
+	return nopMetrics{}
+}
+
+func (this nopMetrics) SetQueueDepth(appAccount, queue string, depth int)          {}
+func (this nopMetrics) ObserveAckLatency(appAccount string, latency time.Duration) {}
+func (this nopMetrics) IncSendTimeout(appAccount, msgType string)                  {}
+func (this nopMetrics) IncReconnect(appAccount string)                             {}
+func (this nopMetrics) IncBindFailure(appAccount, errorType string)                {}
+func (this nopMetrics) IncCrcFailure(appAccount string)                            {}
+func (this nopMetrics) AddBytesSent(appAccount string, n int)                      {}
+func (this nopMetrics) AddBytesReceived(appAccount string, n int)                  {}
+func (this nopMetrics) ObservePingRtt(appAccount string, rtt time.Duration)        {}
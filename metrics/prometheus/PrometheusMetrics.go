@@ -0,0 +1,162 @@
+package prom
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "mimc"
+const labelAppAccount = "app_account"
+
+/**
+ * Prometheus实现，perUser为true时所有指标带app_account标签，
+ * 否则所有用户聚合到同一组时间序列，避免账号数量过多导致序列膨胀。
+ */
+type PrometheusMetrics struct {
+	perUser bool
+
+	mu     sync.Mutex
+	depths map[string]map[string]int
+
+	queueDepth    *prometheus.GaugeVec
+	ackLatency    *prometheus.HistogramVec
+	sendTimeouts  *prometheus.CounterVec
+	reconnects    *prometheus.CounterVec
+	bindFailures  *prometheus.CounterVec
+	crcFailures   *prometheus.CounterVec
+	bytesSent     *prometheus.CounterVec
+	bytesReceived *prometheus.CounterVec
+	pingRtt       *prometheus.HistogramVec
+}
+
+var _ metrics.Metrics = (*PrometheusMetrics)(nil)
+
+func NewPrometheusMetrics(registerer prometheus.Registerer, perUser bool) (*PrometheusMetrics, error) {
+	this := &PrometheusMetrics{perUser: perUser, depths: make(map[string]map[string]int)}
+	this.queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Number of packets waiting in the SDK internal queues.",
+	}, this.labels("queue"))
+	this.ackLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "send_ack_latency_seconds",
+		Help:      "Time from enqueueing a message to receiving its server ack.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, this.labels())
+	this.sendTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "send_timeouts_total",
+		Help:      "Messages that were not acked by the server in time.",
+	}, this.labels("type"))
+	this.reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconnects_total",
+		Help:      "Connection resets that force a reconnect.",
+	}, this.labels())
+	this.bindFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bind_failures_total",
+		Help:      "Failed BIND responses by error type.",
+	}, this.labels("error_type"))
+	this.crcFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "crc_failures_total",
+		Help:      "Received frames dropped because of a CRC mismatch.",
+	}, this.labels())
+	this.bytesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sent_bytes_total",
+		Help:      "Bytes written to the connection.",
+	}, this.labels())
+	this.bytesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "received_bytes_total",
+		Help:      "Bytes read from the connection.",
+	}, this.labels())
+	this.pingRtt = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ping_rtt_seconds",
+		Help:      "Round trip time between a ping and its pong.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, this.labels())
+
+	collectors := []prometheus.Collector{this.queueDepth, this.ackLatency, this.sendTimeouts, this.reconnects,
+		this.bindFailures, this.crcFailures, this.bytesSent, this.bytesReceived, this.pingRtt}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return this, nil
+}
+
+func (this *PrometheusMetrics) labels(extra ...string) []string {
+	if this.perUser {
+		return append([]string{labelAppAccount}, extra...)
+	}
+	return extra
+}
+
+func (this *PrometheusMetrics) values(appAccount string, extra ...string) []string {
+	if this.perUser {
+		return append([]string{appAccount}, extra...)
+	}
+	return extra
+}
+
+func (this *PrometheusMetrics) SetQueueDepth(appAccount, queue string, depth int) {
+	if this.perUser {
+		this.queueDepth.WithLabelValues(appAccount, queue).Set(float64(depth))
+		return
+	}
+	// 聚合模式下记录每个用户最近一次上报的深度，导出所有用户之和
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	queues, ok := this.depths[queue]
+	if !ok {
+		queues = make(map[string]int)
+		this.depths[queue] = queues
+	}
+	queues[appAccount] = depth
+	total := 0
+	for _, value := range queues {
+		total += value
+	}
+	this.queueDepth.WithLabelValues(queue).Set(float64(total))
+}
+
+func (this *PrometheusMetrics) ObserveAckLatency(appAccount string, latency time.Duration) {
+	this.ackLatency.WithLabelValues(this.values(appAccount)...).Observe(latency.Seconds())
+}
+
+func (this *PrometheusMetrics) IncSendTimeout(appAccount, msgType string) {
+	this.sendTimeouts.WithLabelValues(this.values(appAccount, msgType)...).Inc()
+}
+
+func (this *PrometheusMetrics) IncReconnect(appAccount string) {
+	this.reconnects.WithLabelValues(this.values(appAccount)...).Inc()
+}
+
+func (this *PrometheusMetrics) IncBindFailure(appAccount, errorType string) {
+	this.bindFailures.WithLabelValues(this.values(appAccount, errorType)...).Inc()
+}
+
+func (this *PrometheusMetrics) IncCrcFailure(appAccount string) {
+	this.crcFailures.WithLabelValues(this.values(appAccount)...).Inc()
+}
+
+func (this *PrometheusMetrics) AddBytesSent(appAccount string, n int) {
+	this.bytesSent.WithLabelValues(this.values(appAccount)...).Add(float64(n))
+}
+
+func (this *PrometheusMetrics) AddBytesReceived(appAccount string, n int) {
+	this.bytesReceived.WithLabelValues(this.values(appAccount)...).Add(float64(n))
+}
+
+func (this *PrometheusMetrics) ObservePingRtt(appAccount string, rtt time.Duration) {
+	this.pingRtt.WithLabelValues(this.values(appAccount)...).Observe(rtt.Seconds())
+}
@@ -0,0 +1,66 @@
+package prom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gather(t *testing.T, registry *prometheus.Registry) map[string]*dto.MetricFamily {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather fail: %v", err)
+	}
+	result := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		result[family.GetName()] = family
+	}
+	return result
+}
+
+func TestPerUserMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewPrometheusMetrics(registry, true)
+	if err != nil {
+		t.Fatalf("create metrics fail: %v", err)
+	}
+	m.IncBindFailure("Alice", "token-expired")
+	m.IncBindFailure("Alice", "token-expired")
+	m.ObserveAckLatency("Bob", 30*time.Millisecond)
+	m.AddBytesSent("Alice", 128)
+
+	families := gather(t, registry)
+	bind := families["mimc_bind_failures_total"].GetMetric()
+	if len(bind) != 1 || bind[0].GetCounter().GetValue() != 2 {
+		t.Errorf("unexpected bind failures: %v", bind)
+	}
+	if len(bind[0].GetLabel()) != 2 {
+		t.Errorf("expect app_account and error_type labels: %v", bind[0].GetLabel())
+	}
+	ack := families["mimc_send_ack_latency_seconds"].GetMetric()
+	if len(ack) != 1 || ack[0].GetHistogram().GetSampleCount() != 1 {
+		t.Errorf("unexpected ack latency: %v", ack)
+	}
+}
+
+func TestAggregateQueueDepth(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewPrometheusMetrics(registry, false)
+	if err != nil {
+		t.Fatalf("create metrics fail: %v", err)
+	}
+	m.SetQueueDepth("Alice", metrics.QueueMessageToSend, 3)
+	m.SetQueueDepth("Bob", metrics.QueueMessageToSend, 4)
+	m.SetQueueDepth("Alice", metrics.QueueMessageToSend, 1)
+
+	depth := gather(t, registry)["mimc_queue_depth"].GetMetric()
+	if len(depth) != 1 || depth[0].GetGauge().GetValue() != 5 {
+		t.Errorf("unexpected queue depth: %v", depth)
+	}
+	if len(depth[0].GetLabel()) != 1 {
+		t.Errorf("aggregate metrics should only carry the queue label: %v", depth[0].GetLabel())
+	}
+}
@@ -7,10 +7,11 @@ import (
 type MIMCTimeoutPacket struct {
 	timestamp int64
 	packet    *mimc.MIMCPacket
+	payload   []byte
 }
 
 func NewTimeoutPacket(timestamp int64, packet *mimc.MIMCPacket) *MIMCTimeoutPacket {
-	return &MIMCTimeoutPacket{timestamp, packet}
+	return &MIMCTimeoutPacket{timestamp: timestamp, packet: packet}
 }
 
 func (this *MIMCTimeoutPacket) Timestamp() int64 {
@@ -20,3 +21,13 @@ func (this *MIMCTimeoutPacket) Timestamp() int64 {
 func (this *MIMCTimeoutPacket) Packet() *mimc.MIMCPacket {
 	return this.packet
 }
+
+// 应用层的原始payload，发送前经过压缩或加密时用于超时回调
+func (this *MIMCTimeoutPacket) SetPayload(payload []byte) *MIMCTimeoutPacket {
+	this.payload = payload
+	return this
+}
+
+func (this *MIMCTimeoutPacket) Payload() []byte {
+	return this.payload
+}
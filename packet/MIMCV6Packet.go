@@ -2,6 +2,8 @@ package packet
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/cipher"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
@@ -10,8 +12,6 @@ import (
 	"github.com/golang/protobuf/proto"
 )
 
-var logger *log.Logger
-
 type MIMCV6Packet struct {
 	magic     uint16
 	version   uint16
@@ -29,59 +29,124 @@ type MIMCV6Packet struct {
 
 func NewV6Packet() *MIMCV6Packet {
 	packet := new(MIMCV6Packet)
-	logger = log.GetLogger()
 	return packet
 }
-func ParseBytesToPacket(headerBins, bodyBins, crcBins *[]byte, bodyKey *[]byte, secKey *string) *MIMCV6Packet {
-	v6BinsBuffer := new(bytes.Buffer)
-	v6BinsBuffer.Write(*headerBins)
-	v6BinsBuffer.Write(*bodyBins)
-	v6Bins := v6BinsBuffer.Bytes()
 
-	crcfe := byteutil.GetIntFromBytes(crcBins, 0)
-	crc := byteutil.Crc(v6Bins)
-	if crcfe != crc {
-		logger.Error("[ParseBytesToPacket] crc check fail.")
+var (
+	ErrMalformed    = errors.New("packet: malformed v6 packet")
+	ErrCrc          = errors.New("packet: crc check fail")
+	ErrBodyTooLarge = errors.New("packet: body too large")
+)
+
+// 解析V6包头，返回包体长度，包体长度超过V6_MAX_BODY_LENGTH时返回ErrBodyTooLarge
+func ParseHead(headerBins []byte) (int, error) {
+	if len(headerBins) != int(cnst.V6_HEAD_LENGTH) {
+		return 0, fmt.Errorf("%w: head length %d", ErrMalformed, len(headerBins))
+	}
+	if magic := byteutil.GetUint16FromBytes(&headerBins, cnst.V6_MAGIC_OFFSET); magic != cnst.MAGIC {
+		return 0, fmt.Errorf("%w: error magic %#x", ErrMalformed, magic)
+	}
+	if version := byteutil.GetUint16FromBytes(&headerBins, cnst.V6_VERSION_OFFSET); version != cnst.V6_VERSION {
+		return 0, fmt.Errorf("%w: error version %v", ErrMalformed, version)
+	}
+	bodyLen := byteutil.GetIntFromBytes(&headerBins, cnst.V6_BODYLEN_OFFSET)
+	if bodyLen < 0 || bodyLen > cnst.V6_MAX_BODY_LENGTH {
+		return 0, fmt.Errorf("%w: %d bytes", ErrBodyTooLarge, bodyLen)
+	}
+	return bodyLen, nil
+}
+
+// 解析失败时记录日志并返回nil，需要区分失败原因时使用Parse
+func ParseBytesToPacket(headerBins, bodyBins, crcBins *[]byte, bodyKey *[]byte, secKey *string) *MIMCV6Packet {
+	if headerBins == nil || bodyBins == nil || crcBins == nil {
+		log.GetLogger().Error("[ParseBytesToPacket] nil packet bytes.")
+		return nil
+	}
+	var key []byte
+	if bodyKey != nil {
+		key = *bodyKey
+	}
+	var seckey string
+	if secKey != nil {
+		seckey = *secKey
+	}
+	v6Packet, err := Parse(*headerBins, *bodyBins, *crcBins, key, seckey)
+	if err != nil {
+		log.GetLogger().Error("[ParseBytesToPacket] %v", err)
 		return nil
 	}
+	return v6Packet
+}
+
+/**
+ * 解析从连接上读到的包头、包体和crc，bodyKey为空时包体不解密。
+ * 长度字段与实际数据不符时返回ErrMalformed，不会越界访问。
+ */
+func Parse(headerBins, bodyBins, crcBins []byte, bodyKey []byte, secKey string) (*MIMCV6Packet, error) {
+	if len(headerBins) != int(cnst.V6_HEAD_LENGTH) || len(crcBins) != cnst.V6_CRC_LENGTH {
+		return nil, fmt.Errorf("%w: head length %d, crc length %d", ErrMalformed, len(headerBins), len(crcBins))
+	}
+	if !CheckCrc(&headerBins, &bodyBins, &crcBins) {
+		return nil, ErrCrc
+	}
 	v6Packet := NewV6Packet()
-	v6Packet.magic = byteutil.GetUint16FromBytes(&v6Bins, cnst.V6_MAGIC_OFFSET)
-	v6Packet.version = byteutil.GetUint16FromBytes(&v6Bins, cnst.V6_VERSION_OFFSET)
-	if bodyKey != nil && len(*bodyKey) > 0 && bodyBins != nil && len(*bodyBins) > 0 {
-		v6BodyBinsUnEn := cipher.Encrypt(*bodyKey, *bodyBins)
-		bodyBins = &v6BodyBinsUnEn
+	v6Packet.magic = byteutil.GetUint16FromBytes(&headerBins, cnst.V6_MAGIC_OFFSET)
+	v6Packet.version = byteutil.GetUint16FromBytes(&headerBins, cnst.V6_VERSION_OFFSET)
+	if len(bodyKey) > 0 && len(bodyBins) > 0 {
+		bodyBins = cipher.Encrypt(bodyKey, bodyBins)
 	}
-	if len(*bodyBins) == 0 {
+	if len(bodyBins) == 0 {
 		v6Packet.packetLen = 0
-		return v6Packet
-	} else {
-		v6Packet.packetLen = len(*bodyBins)
+		return v6Packet, nil
+	}
+	v6Packet.packetLen = len(bodyBins)
+	bodyHeadLen := int(cnst.V6_BODY_HEADER_LENGTH)
+	if len(bodyBins) < bodyHeadLen {
+		return nil, fmt.Errorf("%w: body length %d", ErrMalformed, len(bodyBins))
 	}
-	v6Packet.payloadType = byteutil.GetUint16FromBytes(bodyBins, cnst.V6_PAYLOADTYPE_OFFSET)
-	v6Packet.clientHeaderLen = byteutil.GetUint16FromBytes(bodyBins, cnst.V6_HEADERLEN_OFFSET)
-	v6Packet.payloadLen = uint32(byteutil.GetIntFromBytes(bodyBins, cnst.V6_PAYLOADLEN_OFFSET))
+	v6Packet.payloadType = byteutil.GetUint16FromBytes(&bodyBins, cnst.V6_PAYLOADTYPE_OFFSET)
+	v6Packet.clientHeaderLen = byteutil.GetUint16FromBytes(&bodyBins, cnst.V6_HEADERLEN_OFFSET)
+	payloadLen := byteutil.GetIntFromBytes(&bodyBins, cnst.V6_PAYLOADLEN_OFFSET)
 	if v6Packet.payloadType != cnst.PAYLOAD_TYPE {
-		return nil
+		return nil, fmt.Errorf("%w: payload type %v", ErrMalformed, v6Packet.payloadType)
+	}
+	headerLen := int(v6Packet.clientHeaderLen)
+	if payloadLen < 0 || bodyHeadLen+headerLen+payloadLen > len(bodyBins) {
+		return nil, fmt.Errorf("%w: header length %d, payload length %d, body length %d", ErrMalformed, headerLen, payloadLen, len(bodyBins))
 	}
-	headerBytes := byteutil.Copy(bodyBins, int(cnst.V6_BODY_HEADER_LENGTH), int(v6Packet.clientHeaderLen))
-	payloadBytes := byteutil.Copy(bodyBins, int(cnst.V6_BODY_HEADER_LENGTH)+int(v6Packet.clientHeaderLen), int(v6Packet.payloadLen))
+	v6Packet.payloadLen = uint32(payloadLen)
+	headerBytes := byteutil.Copy(&bodyBins, bodyHeadLen, headerLen)
+	payloadBytes := byteutil.Copy(&bodyBins, bodyHeadLen+headerLen, payloadLen)
 
 	clientHeader := new(ims.ClientHeader)
-	err := proto.Unmarshal(headerBytes, clientHeader)
-
-	if err != nil {
-		logger.Error("[ParseBytesToPacket] deserial clientHeader fail: %v", err)
-		return nil
+	if err := proto.Unmarshal(headerBytes, clientHeader); err != nil {
+		return nil, fmt.Errorf("%w: deserial clientHeader fail: %v", ErrMalformed, err)
 	}
-
-	if cnst.CMD_SECMSG == *(clientHeader.Cmd) {
-		payloadKey := cipher.GenerateKeyForRC4(secKey, clientHeader.Id)
+	if clientHeader.Cmd == nil {
+		return nil, fmt.Errorf("%w: clientHeader without cmd", ErrMalformed)
+	}
+	if cnst.CMD_SECMSG == clientHeader.GetCmd() {
+		if clientHeader.Id == nil {
+			return nil, fmt.Errorf("%w: SECMSG without id", ErrMalformed)
+		}
+		payloadKey := cipher.GenerateKeyForRC4(&secKey, clientHeader.Id)
 		payloadBytes = cipher.Encrypt(payloadKey, payloadBytes)
 	}
 	v6Packet.clientHeader = clientHeader
 	v6Packet.payload = payloadBytes
-	return v6Packet
+	return v6Packet, nil
+}
 
+// 校验V6包头和包体的adler32是否与crcBins一致
+func CheckCrc(headerBins, bodyBins, crcBins *[]byte) bool {
+	if headerBins == nil || bodyBins == nil || crcBins == nil || len(*crcBins) < cnst.V6_CRC_LENGTH {
+		return false
+	}
+	v6BinsBuffer := new(bytes.Buffer)
+	v6BinsBuffer.Write(*headerBins)
+	v6BinsBuffer.Write(*bodyBins)
+	crcfe := byteutil.GetIntFromBytes(crcBins, 0)
+	return crcfe == byteutil.Crc(v6BinsBuffer.Bytes())
 }
 
 func (this *MIMCV6Packet) PayloadType(payloadType uint16) {
@@ -119,7 +184,7 @@ func (this *MIMCV6Packet) Bytes(v6BodyKey []byte, payloadKey []byte) []byte {
 		bodyHead := make([]byte, cnst.V6_BODY_HEADER_LENGTH)
 		headBin, err := proto.Marshal(this.clientHeader)
 		if err != nil {
-			logger.Error("[bytes] marshaling error: %v", err)
+			log.GetLogger().With(log.FieldPacketId, this.clientHeader.GetId(), log.FieldCmd, this.clientHeader.GetCmd()).Error("[bytes] marshaling error: %v", err)
 			return nil
 		}
 		var headerBinLen, payloadLen int
@@ -156,7 +221,7 @@ func (this *MIMCV6Packet) HeaderId() []byte {
 	if this.clientHeader == nil {
 		return nil
 	}
-	logger.Debug("v6header: %v", this.clientHeader)
+	log.GetLogger().Debug("v6header: %v", this.clientHeader)
 	return []byte(*(this.clientHeader.Id))
 }
 
@@ -0,0 +1,104 @@
+package packet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/cipher"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/byte"
+	"github.com/golang/protobuf/proto"
+)
+
+const testSecKey = "c2VjcmV0LWtleQ=="
+
+var testBodyKey = []byte("body-key")
+
+func split(frame []byte) ([]byte, []byte, []byte) {
+	headLen := int(cnst.V6_HEAD_LENGTH)
+	return frame[:headLen], frame[headLen : len(frame)-cnst.V6_CRC_LENGTH], frame[len(frame)-cnst.V6_CRC_LENGTH:]
+}
+
+func secMsg(payload []byte) []byte {
+	header := &ims.ClientHeader{Cmd: proto.String(cnst.CMD_SECMSG), Id: proto.String("h1")}
+	secKey := testSecKey
+	return NewV6Packet().Header(header).Payload(payload).Bytes(testBodyKey, cipher.GenerateKeyForRC4(&secKey, header.Id))
+}
+
+func TestParse(t *testing.T) {
+	head, body, crc := split(secMsg([]byte("hello")))
+	if bodyLen, err := ParseHead(head); err != nil || bodyLen != len(body) {
+		t.Fatalf("parse head fail: %v, %v", bodyLen, err)
+	}
+	v6Packet, err := Parse(head, body, crc, testBodyKey, testSecKey)
+	if err != nil || v6Packet.GetHeader().GetCmd() != cnst.CMD_SECMSG || !bytes.Equal(v6Packet.GetPayload(), []byte("hello")) {
+		t.Fatalf("parse fail: %v", err)
+	}
+	if _, err := Parse(head, body, []byte{0, 0, 0, 0}, testBodyKey, testSecKey); !errors.Is(err, ErrCrc) {
+		t.Errorf("expect ErrCrc, got %v", err)
+	}
+
+	// 头部长度超出包体时返回错误而不是越界
+	plain := cipher.Encrypt(testBodyKey, body)
+	byteutil.TransferUint16(&plain, 0xffff, cnst.V6_HEADERLEN_OFFSET)
+	truncated := cipher.Encrypt(testBodyKey, plain)
+	frame := byteutil.Integrate(head, truncated)
+	if _, err := Parse(head, truncated, byteutil.Bytes(byteutil.Crc(frame)), testBodyKey, testSecKey); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expect ErrMalformed, got %v", err)
+	}
+	if ParseBytesToPacket(&head, &truncated, &crc, nil, nil) != nil {
+		t.Errorf("ParseBytesToPacket should return nil for a malformed packet")
+	}
+
+	large := make([]byte, cnst.V6_HEAD_LENGTH)
+	byteutil.TransferUint16(&large, cnst.MAGIC, cnst.V6_MAGIC_OFFSET)
+	byteutil.TransferUint16(&large, cnst.V6_VERSION, cnst.V6_VERSION_OFFSET)
+	byteutil.TransferInt(&large, cnst.V6_MAX_BODY_LENGTH+1, cnst.V6_BODYLEN_OFFSET)
+	if _, err := ParseHead(large); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("expect ErrBodyTooLarge, got %v", err)
+	}
+	if _, err := ParseHead(large[:4]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expect ErrMalformed, got %v", err)
+	}
+}
+
+func FuzzParseHead(f *testing.F) {
+	head, _, _ := split(secMsg([]byte("hello")))
+	f.Add(head)
+	f.Add([]byte{0xc2, 0xfe, 0, 5, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bodyLen, err := ParseHead(data)
+		if err == nil && (bodyLen < 0 || bodyLen > cnst.V6_MAX_BODY_LENGTH) {
+			t.Errorf("body length %d out of range", bodyLen)
+		}
+	})
+}
+
+/**
+ * 输入为包头和包体，crc由测试计算，使变异能够进入包体解析。
+ * encrypted为true时按已经建立连接的帧用RC4解密包体。
+ */
+func FuzzParse(f *testing.F) {
+	f.Add(secMsg([]byte("hello"))[:len(secMsg([]byte("hello")))-cnst.V6_CRC_LENGTH], true)
+	connHeader := &ims.ClientHeader{Cmd: proto.String(cnst.CMD_CONN), Id: proto.String("c1")}
+	conn := NewV6Packet().Header(connHeader).Payload([]byte{0x0a, 0x01, 0x41}).Bytes(nil, nil)
+	f.Add(conn[:len(conn)-cnst.V6_CRC_LENGTH], false)
+	f.Add(NewV6Packet().Bytes(nil, nil)[:cnst.V6_HEAD_LENGTH], false)
+	f.Fuzz(func(t *testing.T, data []byte, encrypted bool) {
+		if len(data) < int(cnst.V6_HEAD_LENGTH) {
+			return
+		}
+		head, body := data[:cnst.V6_HEAD_LENGTH], data[cnst.V6_HEAD_LENGTH:]
+		crc := byteutil.Bytes(byteutil.Crc(data))
+		var bodyKey []byte
+		if encrypted {
+			bodyKey = testBodyKey
+		}
+		v6Packet, err := Parse(head, body, crc, bodyKey, testSecKey)
+		if err == nil && v6Packet.GetHeader() != nil && v6Packet.GetHeader().Cmd == nil {
+			t.Errorf("parsed header without cmd")
+		}
+	})
+}
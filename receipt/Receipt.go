@@ -0,0 +1,86 @@
+package receipt
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+/**
+ * 回执消息格式(大端):
+ * | magic(2) | version(1) | type(1) | upToSequence(8) | count(2) | (idLen(1) | packetId)* |
+ * 回执通过不存储的单聊消息发送，接收端识别magic后交给ReceiptDelegate，不交给应用。
+ */
+const (
+	MAGIC        uint16 = 0x4d52
+	VERSION      byte   = 1
+	headerLength int    = 14
+	maxCount     int    = 0xffff
+)
+
+const (
+	TYPE_DELIVERED byte = 1
+	TYPE_READ      byte = 2
+)
+
+var ErrInvalidReceipt = errors.New("receipt: invalid receipt")
+
+type Receipt struct {
+	Type byte
+	// 已读回执中的sequence上限，送达回执为0
+	UpToSequence int64
+	PacketIds    []string
+}
+
+// payload是否带有回执头
+func IsReceipt(payload []byte) bool {
+	return len(payload) >= headerLength && binary.BigEndian.Uint16(payload) == MAGIC && payload[2] == VERSION
+}
+
+func Encode(receipt *Receipt) ([]byte, error) {
+	if len(receipt.PacketIds) > maxCount {
+		return nil, ErrInvalidReceipt
+	}
+	data := make([]byte, headerLength)
+	binary.BigEndian.PutUint16(data, MAGIC)
+	data[2] = VERSION
+	data[3] = receipt.Type
+	binary.BigEndian.PutUint64(data[4:], uint64(receipt.UpToSequence))
+	binary.BigEndian.PutUint16(data[12:], uint16(len(receipt.PacketIds)))
+	for _, packetId := range receipt.PacketIds {
+		if len(packetId) > 0xff {
+			return nil, ErrInvalidReceipt
+		}
+		data = append(data, byte(len(packetId)))
+		data = append(data, packetId...)
+	}
+	return data, nil
+}
+
+func Decode(payload []byte) (*Receipt, error) {
+	if !IsReceipt(payload) {
+		return nil, ErrInvalidReceipt
+	}
+	receipt := &Receipt{Type: payload[3], UpToSequence: int64(binary.BigEndian.Uint64(payload[4:]))}
+	if receipt.Type != TYPE_DELIVERED && receipt.Type != TYPE_READ {
+		return nil, ErrInvalidReceipt
+	}
+	count := int(binary.BigEndian.Uint16(payload[12:]))
+	receipt.PacketIds = make([]string, 0, count)
+	offset := headerLength
+	for i := 0; i < count; i++ {
+		if offset >= len(payload) {
+			return nil, ErrInvalidReceipt
+		}
+		idLength := int(payload[offset])
+		offset += 1
+		if offset+idLength > len(payload) {
+			return nil, ErrInvalidReceipt
+		}
+		receipt.PacketIds = append(receipt.PacketIds, string(payload[offset:offset+idLength]))
+		offset += idLength
+	}
+	if offset != len(payload) {
+		return nil, ErrInvalidReceipt
+	}
+	return receipt, nil
+}
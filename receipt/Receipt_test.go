@@ -0,0 +1,27 @@
+package receipt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeAndDecode(t *testing.T) {
+	receipt := &Receipt{Type: TYPE_READ, UpToSequence: 42, PacketIds: []string{"abc_1", "abc_2"}}
+	payload, err := Encode(receipt)
+	if err != nil {
+		t.Fatalf("encode fail: %v", err)
+	}
+	decoded, err := Decode(payload)
+	if err != nil {
+		t.Fatalf("decode fail: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, receipt) {
+		t.Errorf("decoded receipt mismatch: %+v", decoded)
+	}
+	if _, err := Decode(payload[:len(payload)-1]); err != ErrInvalidReceipt {
+		t.Errorf("truncated receipt should be rejected, got %v", err)
+	}
+	if _, err := Decode([]byte("hello world, not a receipt")); err != ErrInvalidReceipt {
+		t.Errorf("plain payload should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package recording
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/inspect"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/mimc"
+	"github.com/golang/protobuf/proto"
+)
+
+// 脱敏后替换原值的内容
+const REDACTED string = "[redacted]"
+
+/**
+ * RedactPayloads为true时，单聊和群聊消息的payload替换为REDACTED；
+ * RedactKeys为true时，BIND中的token和签名、CONN响应中的challenge替换为REDACTED。
+ */
+type Options struct {
+	RedactPayloads bool
+	RedactKeys     bool
+}
+
+/**
+ * 把SDK收发的已解密帧写成inspect.Record格式的JSON Lines会话文件，
+ * 可以用mimc-inspect -session查看，或者用MCUser.Replay回放。
+ * 写入失败后不再写入，错误通过Err获取。
+ */
+type Recorder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+	options Options
+	err     error
+}
+
+func NewRecorder(writer io.Writer, options Options) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(writer), options: options}
+}
+
+// 以追加方式写入文件，文件权限为0600
+func NewFileRecorder(path string, options Options) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	this := NewRecorder(file, options)
+	this.closer = file
+	return this, nil
+}
+
+// 记录当前用户信息，回放时据此恢复resource
+func (this *Recorder) Account(account inspect.Account) error {
+	return this.write(&inspect.Record{Time: time.Now().UnixMilli(), Account: &account})
+}
+
+// 记录一个帧，header为nil时为PING，payload为SECMSG加密前或解密后的内容
+func (this *Recorder) Record(direction string, header *ims.ClientHeader, payload []byte) error {
+	record := &inspect.Record{Direction: direction, Time: time.Now().UnixMilli(), Decoded: true}
+	if header != nil {
+		headerBytes, err := proto.Marshal(header)
+		if err != nil {
+			return err
+		}
+		record.Header = headerBytes
+		record.Payload = this.redact(header.GetCmd(), payload)
+	}
+	return this.write(record)
+}
+
+func (this *Recorder) write(record *inspect.Record) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.err != nil {
+		return this.err
+	}
+	this.err = this.encoder.Encode(record)
+	return this.err
+}
+
+func (this *Recorder) Err() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.err
+}
+
+func (this *Recorder) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.err == nil {
+		this.err = os.ErrClosed
+	}
+	if this.closer == nil {
+		return nil
+	}
+	return this.closer.Close()
+}
+
+// 按选项脱敏，无法解析的payload原样保留
+func (this *Recorder) redact(cmd string, payload []byte) []byte {
+	if len(payload) == 0 {
+		return payload
+	}
+	switch {
+	case cmd == cnst.CMD_SECMSG && this.options.RedactPayloads:
+		packet := new(mimc.MIMCPacket)
+		if proto.Unmarshal(payload, packet) == nil && redactPacket(packet) {
+			if data, err := proto.Marshal(packet); err == nil {
+				return data
+			}
+		}
+	case cmd == cnst.CMD_BIND && this.options.RedactKeys:
+		bind := new(ims.XMMsgBind)
+		if proto.Unmarshal(payload, bind) == nil && bind.Token != nil {
+			bind.Token = proto.String(REDACTED)
+			if bind.Sig != nil {
+				bind.Sig = proto.String(REDACTED)
+			}
+			if data, err := proto.Marshal(bind); err == nil {
+				return data
+			}
+		}
+	case cmd == cnst.CMD_CONN && this.options.RedactKeys:
+		connResp := new(ims.XMMsgConnResp)
+		if proto.Unmarshal(payload, connResp) == nil && len(proto.MessageReflect(connResp).GetUnknown()) == 0 && connResp.Challenge != nil {
+			connResp.Challenge = proto.String(REDACTED)
+			if data, err := proto.Marshal(connResp); err == nil {
+				return data
+			}
+		}
+	}
+	return payload
+}
+
+// 替换单聊、群聊消息的payload，返回是否有修改
+func redactPacket(packet *mimc.MIMCPacket) bool {
+	var message proto.Message
+	switch packet.GetType() {
+	case mimc.MIMC_MSG_TYPE_P2P_MESSAGE:
+		message = new(mimc.MIMCP2PMessage)
+	case mimc.MIMC_MSG_TYPE_P2T_MESSAGE:
+		message = new(mimc.MIMCP2TMessage)
+	case mimc.MIMC_MSG_TYPE_COMPOUND:
+		packetList := new(mimc.MIMCPacketList)
+		if proto.Unmarshal(packet.Payload, packetList) != nil {
+			return false
+		}
+		changed := false
+		for _, inner := range packetList.Packets {
+			if inner != nil && redactPacket(inner) {
+				changed = true
+			}
+		}
+		data, err := proto.Marshal(packetList)
+		if !changed || err != nil {
+			return false
+		}
+		packet.Payload = data
+		return true
+	default:
+		return false
+	}
+	if proto.Unmarshal(packet.Payload, message) != nil {
+		return false
+	}
+	switch content := message.(type) {
+	case *mimc.MIMCP2PMessage:
+		content.Payload = []byte(REDACTED)
+	case *mimc.MIMCP2TMessage:
+		content.Payload = []byte(REDACTED)
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return false
+	}
+	packet.Payload = data
+	return true
+}
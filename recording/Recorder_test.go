@@ -0,0 +1,42 @@
+package recording
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/inspect"
+	"github.com/Xiaomi-mimc/mimc-go-sdk/protobuf/ims"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestRedactKeys(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	recorder := NewRecorder(buffer, Options{RedactKeys: true})
+	bind, _ := proto.Marshal(&ims.XMMsgBind{Token: proto.String("secret-token"), Sig: proto.String("secret-sig"), Method: proto.String("XIAOMI-PASS")})
+	recorder.Record(inspect.DIRECTION_OUT, &ims.ClientHeader{Cmd: proto.String(cnst.CMD_BIND), Id: proto.String("b1")}, bind)
+	connResp, _ := proto.Marshal(&ims.XMMsgConnResp{Challenge: proto.String("secret-challenge")})
+	recorder.Record(inspect.DIRECTION_IN, &ims.ClientHeader{Cmd: proto.String(cnst.CMD_CONN), Id: proto.String("c1")}, connResp)
+	recorder.Record(inspect.DIRECTION_OUT, nil, nil)
+	recorder.Close()
+	if err := recorder.Record(inspect.DIRECTION_OUT, nil, nil); err == nil {
+		t.Errorf("record after close should fail")
+	}
+
+	var texts []string
+	err := inspect.ReadSession(bytes.NewReader(buffer.Bytes()), inspect.NewDecoder(inspect.Keys{}), func(frame *inspect.Frame) error {
+		texts = append(texts, frame.Text())
+		return nil
+	})
+	if err != nil || len(texts) != 3 {
+		t.Fatalf("expect 3 frames, got %v, %v", len(texts), err)
+	}
+	all := strings.Join(texts, "")
+	if strings.Contains(all, "secret") || !strings.Contains(all, REDACTED) || !strings.Contains(texts[0], "XIAOMI-PASS") {
+		t.Errorf("keys should be redacted: %v", all)
+	}
+	if !strings.Contains(texts[2], "PING") {
+		t.Errorf("header-less frame should be a ping: %v", texts[2])
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/jsonl"
+)
+
+const (
+	opSave   string = "save"
+	opStatus string = "status"
+)
+
+// 文件中的一行记录
+type record struct {
+	Op        string   `json:"op"`
+	Message   *Message `json:"message,omitempty"`
+	PacketId  string   `json:"packetId,omitempty"`
+	Status    Status   `json:"status,omitempty"`
+	Sequence  int64    `json:"sequence,omitempty"`
+	Timestamp int64    `json:"timestamp,omitempty"`
+}
+
+/**
+ * 以JSON Lines文件持久化的消息存储，每次写入追加一行记录，打开时重放全部记录到内存索引。
+ * 状态更新同样以追加方式记录，可以定期调用Compact重写文件。
+ * 进程崩溃导致的不完整行在打开时被忽略。
+ */
+type FileStore struct {
+	mu     sync.Mutex
+	log    *jsonl.AppendLog
+	memory *MemoryStore
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	this := &FileStore{memory: NewMemoryStore()}
+	appendLog, err := jsonl.Open(path, this.replay)
+	if err != nil {
+		return nil, err
+	}
+	this.log = appendLog
+	return this, nil
+}
+
+func (this *FileStore) replay(line []byte) {
+	r := new(record)
+	if err := json.Unmarshal(line, r); err != nil {
+		return
+	}
+	switch r.Op {
+	case opSave:
+		if r.Message != nil {
+			this.memory.save(r.Message)
+		}
+	case opStatus:
+		this.memory.updateStatus(r.PacketId, r.Status, r.Sequence, r.Timestamp)
+	}
+}
+
+func (this *FileStore) Save(message *Message) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if err := this.log.Append(&record{Op: opSave, Message: message}); err != nil {
+		return err
+	}
+	return this.memory.Save(message)
+}
+
+func (this *FileStore) UpdateStatus(packetId string, status Status, sequence, timestamp int64) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if err := this.memory.UpdateStatus(packetId, status, sequence, timestamp); err != nil {
+		return err
+	}
+	return this.log.Append(&record{Op: opStatus, PacketId: packetId, Status: status, Sequence: sequence, Timestamp: timestamp})
+}
+
+func (this *FileStore) Get(packetId string) (*Message, error) {
+	return this.memory.Get(packetId)
+}
+
+func (this *FileStore) Query(query Query) ([]*Message, error) {
+	return this.memory.Query(query)
+}
+
+// 用当前状态重写文件，去掉被覆盖的记录和状态更新记录
+func (this *FileStore) Compact() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	messages, _ := this.memory.Query(Query{})
+	sort.SliceStable(messages, func(i, j int) bool {
+		return less(messages[i], messages[j])
+	})
+	records := make([]interface{}, 0, len(messages))
+	for _, message := range messages {
+		records = append(records, &record{Op: opSave, Message: message})
+	}
+	return this.log.Rewrite(records)
+}
+
+func (this *FileStore) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.log.Close()
+}
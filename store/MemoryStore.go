@@ -0,0 +1,137 @@
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+type MemoryStore struct {
+	mu       sync.RWMutex
+	messages map[string]*Message
+	// 每个会话中的消息，按时间排序
+	conversations map[string][]*Message
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{messages: make(map[string]*Message), conversations: make(map[string][]*Message)}
+}
+
+func (this *MemoryStore) Save(message *Message) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.save(message)
+	return nil
+}
+
+func (this *MemoryStore) save(message *Message) {
+	stored := *message
+	if old, ok := this.messages[message.PacketId]; ok {
+		this.removeFromConversation(old)
+	}
+	this.messages[message.PacketId] = &stored
+	this.insertIntoConversation(&stored)
+}
+
+func (this *MemoryStore) UpdateStatus(packetId string, status Status, sequence, timestamp int64) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.updateStatus(packetId, status, sequence, timestamp)
+}
+
+func (this *MemoryStore) updateStatus(packetId string, status Status, sequence, timestamp int64) error {
+	message, ok := this.messages[packetId]
+	if !ok {
+		return ErrNotFound
+	}
+	message.Status = status
+	if sequence > 0 {
+		message.Sequence = sequence
+	}
+	if timestamp > 0 && timestamp != message.Timestamp {
+		this.removeFromConversation(message)
+		message.Timestamp = timestamp
+		this.insertIntoConversation(message)
+	}
+	return nil
+}
+
+func (this *MemoryStore) Get(packetId string) (*Message, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	message, ok := this.messages[packetId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := *message
+	return &result, nil
+}
+
+func (this *MemoryStore) Query(query Query) ([]*Message, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	var candidates []*Message
+	if len(query.Conversation) > 0 {
+		candidates = this.conversations[query.Conversation]
+	} else {
+		candidates = make([]*Message, 0, len(this.messages))
+		for _, message := range this.messages {
+			candidates = append(candidates, message)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return less(candidates[i], candidates[j])
+		})
+	}
+	result := make([]*Message, 0)
+	skipped := 0
+	for i := range candidates {
+		message := candidates[i]
+		if query.Reverse {
+			message = candidates[len(candidates)-1-i]
+		}
+		if !query.match(message) {
+			continue
+		}
+		if skipped < query.Offset {
+			skipped += 1
+			continue
+		}
+		copied := *message
+		result = append(result, &copied)
+		if query.Limit > 0 && len(result) >= query.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (this *MemoryStore) Close() error {
+	return nil
+}
+
+func less(a, b *Message) bool {
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp < b.Timestamp
+	}
+	return a.Sequence < b.Sequence
+}
+
+func (this *MemoryStore) insertIntoConversation(message *Message) {
+	messages := this.conversations[message.Conversation]
+	index := sort.Search(len(messages), func(i int) bool {
+		return less(message, messages[i])
+	})
+	messages = append(messages, nil)
+	copy(messages[index+1:], messages[index:])
+	messages[index] = message
+	this.conversations[message.Conversation] = messages
+}
+
+func (this *MemoryStore) removeFromConversation(message *Message) {
+	messages := this.conversations[message.Conversation]
+	for i := range messages {
+		if messages[i] == message {
+			this.conversations[message.Conversation] = append(messages[:i], messages[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+)
+
+type Status int
+
+const (
+	STATUS_SENDING Status = iota
+	STATUS_ACKED
+	STATUS_TIMEOUT
+	STATUS_FAILED
+	STATUS_RECEIVED
+)
+
+const (
+	TYPE_P2P string = "p2p"
+	TYPE_P2T string = "p2t"
+)
+
+var ErrNotFound = errors.New("store: message not found")
+
+// 单聊会话id: p2p/{对方appAccount}
+func P2PConversation(peerAccount string) string {
+	return TYPE_P2P + "/" + peerAccount
+}
+
+// 群聊会话id: p2t/{topicId}
+func P2TConversation(topicId int64) string {
+	return TYPE_P2T + "/" + strconv.FormatInt(topicId, 10)
+}
+
+type Message struct {
+	PacketId     string `json:"packetId"`
+	Conversation string `json:"conversation"`
+	Type         string `json:"type"`
+	FromAccount  string `json:"fromAccount"`
+	ToAccount    string `json:"toAccount,omitempty"`
+	TopicId      int64  `json:"topicId,omitempty"`
+	Sequence     int64  `json:"sequence"`
+	// 毫秒，发送的消息在收到ack后更新为服务端时间
+	Timestamp int64  `json:"timestamp"`
+	Payload   []byte `json:"payload"`
+	Outgoing  bool   `json:"outgoing"`
+	Status    Status `json:"status"`
+}
+
+/**
+ * 消息查询条件，为0的条件不生效。
+ * 结果默认按时间从早到晚排序，Reverse为true时从晚到早，Offset和Limit在排序后分页。
+ */
+type Query struct {
+	Conversation string
+	StartTime    int64
+	EndTime      int64
+	MinSequence  int64
+	MaxSequence  int64
+	Offset       int
+	Limit        int
+	Reverse      bool
+}
+
+func (this *Query) match(message *Message) bool {
+	if len(this.Conversation) > 0 && message.Conversation != this.Conversation {
+		return false
+	}
+	if this.StartTime > 0 && message.Timestamp < this.StartTime {
+		return false
+	}
+	if this.EndTime > 0 && message.Timestamp > this.EndTime {
+		return false
+	}
+	if this.MinSequence > 0 && message.Sequence < this.MinSequence {
+		return false
+	}
+	if this.MaxSequence > 0 && message.Sequence > this.MaxSequence {
+		return false
+	}
+	return true
+}
+
+/**
+ * 本地消息存储，SDK在发送、ack、超时和收到消息时调用。
+ * 同一个packetId重复Save时覆盖原有记录。
+ */
+type MessageStore interface {
+	Save(message *Message) error
+	UpdateStatus(packetId string, status Status, sequence, timestamp int64) error
+	Get(packetId string) (*Message, error)
+	Query(query Query) ([]*Message, error)
+	Close() error
+}
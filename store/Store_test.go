@@ -0,0 +1,104 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fill(t *testing.T, messageStore MessageStore) {
+	for i := int64(1); i <= 5; i++ {
+		message := &Message{
+			PacketId:     "p" + string(rune('0'+i)),
+			Conversation: P2PConversation("Bob"),
+			Type:         TYPE_P2P,
+			FromAccount:  "Bob",
+			ToAccount:    "Alice",
+			Sequence:     i,
+			Timestamp:    i * 1000,
+			Payload:      []byte("hello"),
+			Status:       STATUS_RECEIVED,
+		}
+		if err := messageStore.Save(message); err != nil {
+			t.Fatalf("save fail: %v", err)
+		}
+	}
+	messageStore.Save(&Message{PacketId: "t1", Conversation: P2TConversation(100), Type: TYPE_P2T, TopicId: 100, Timestamp: 500, Outgoing: true})
+}
+
+func packetIds(messages []*Message) string {
+	result := ""
+	for _, message := range messages {
+		result += message.PacketId + ","
+	}
+	return result
+}
+
+func TestMemoryStoreQuery(t *testing.T) {
+	messageStore := NewMemoryStore()
+	fill(t, messageStore)
+	messages, _ := messageStore.Query(Query{Conversation: P2PConversation("Bob"), Reverse: true, Offset: 1, Limit: 2})
+	if ids := packetIds(messages); ids != "p4,p3," {
+		t.Errorf("reverse page mismatch: %v", ids)
+	}
+	messages, _ = messageStore.Query(Query{Conversation: P2PConversation("Bob"), StartTime: 2000, EndTime: 3000})
+	if ids := packetIds(messages); ids != "p2,p3," {
+		t.Errorf("time range mismatch: %v", ids)
+	}
+	messages, _ = messageStore.Query(Query{MinSequence: 4})
+	if ids := packetIds(messages); ids != "p4,p5," {
+		t.Errorf("sequence range mismatch: %v", ids)
+	}
+	messages, _ = messageStore.Query(Query{Limit: 2})
+	if ids := packetIds(messages); ids != "t1,p1," {
+		t.Errorf("all conversations mismatch: %v", ids)
+	}
+	if err := messageStore.UpdateStatus("t1", STATUS_ACKED, 9, 6000); err != nil {
+		t.Fatalf("update status fail: %v", err)
+	}
+	messages, _ = messageStore.Query(Query{Reverse: true, Limit: 1})
+	if len(messages) != 1 || messages[0].PacketId != "t1" || messages[0].Status != STATUS_ACKED || messages[0].Sequence != 9 {
+		t.Errorf("acked message should be reordered by server timestamp: %+v", messages)
+	}
+	if err := messageStore.UpdateStatus("none", STATUS_ACKED, 0, 0); err != ErrNotFound {
+		t.Errorf("expect ErrNotFound, got %v", err)
+	}
+}
+
+func TestFileStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+	messageStore, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open store fail: %v", err)
+	}
+	fill(t, messageStore)
+	messageStore.UpdateStatus("t1", STATUS_TIMEOUT, 0, 0)
+	messageStore.Close()
+
+	// 模拟写入一半的记录
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"op":"save","message":{"packetId":`)
+	file.Close()
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen store fail: %v", err)
+	}
+	message, err := reopened.Get("t1")
+	if err != nil || message.Status != STATUS_TIMEOUT || !message.Outgoing {
+		t.Fatalf("status should be replayed: %+v, %v", message, err)
+	}
+	reopened.Save(&Message{PacketId: "p6", Conversation: P2PConversation("Bob"), Timestamp: 6000})
+	if err := reopened.Compact(); err != nil {
+		t.Fatalf("compact fail: %v", err)
+	}
+	reopened.Save(&Message{PacketId: "p7", Conversation: P2PConversation("Bob"), Timestamp: 7000})
+	reopened.Close()
+
+	compacted, _ := NewFileStore(path)
+	defer compacted.Close()
+	messages, _ := compacted.Query(Query{Conversation: P2PConversation("Bob")})
+	if ids := packetIds(messages); ids != "p1,p2,p3,p4,p5,p6,p7," {
+		t.Errorf("compacted store mismatch: %v", ids)
+	}
+}
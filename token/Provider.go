@@ -0,0 +1,260 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_TIMEOUT     = 10 * time.Second
+	DEFAULT_MAX_RETRIES = 3
+	DEFAULT_BACKOFF     = 200 * time.Millisecond
+	MAX_BACKOFF         = 5 * time.Second
+	DEFAULT_TTL         = 12 * time.Hour
+)
+
+var (
+	ErrInvalidConfig   = errors.New("token: invalid config")
+	ErrInvalidResponse = errors.New("token: invalid response")
+	ErrAccountMismatch = errors.New("token: appAccount does not match")
+)
+
+// token接口返回非200的HTTP状态码
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (this *HTTPError) Error() string {
+	return fmt.Sprintf("token: http status %v, %v", this.StatusCode, this.Body)
+}
+
+// token接口返回的code不是200
+type ResponseError struct {
+	Code    int
+	Message string
+}
+
+func (this *ResponseError) Error() string {
+	return fmt.Sprintf("token: response code %v, %v", this.Code, this.Message)
+}
+
+/**
+ * Url为MIMC获取token的接口，默认TTL内复用上次获取的token。
+ * Timeout为单次获取(包括重试)的总超时时间，MaxRetries为网络错误或5xx时的重试次数。
+ */
+type Config struct {
+	Url        string
+	AppId      int64
+	AppKey     string
+	AppSecret  string
+	AppAccount string
+
+	HttpClient *http.Client
+	Timeout    time.Duration
+	MaxRetries int
+	Backoff    time.Duration
+	TTL        time.Duration
+}
+
+/**
+ * 实现mimc.Token的token获取器，带超时、重试、响应校验和缓存。
+ * 请求体在创建时序列化一次，appSecret包含在其中，每次请求都会发送给token接口，
+ * 因此Provider只应在应用服务端使用，客户端应通过token/server获取token。
+ */
+type Provider struct {
+	url        string
+	appAccount string
+	body       []byte
+	httpClient *http.Client
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+	ttl        time.Duration
+
+	mu        sync.Mutex
+	cached    *string
+	expireAt  time.Time
+	lastError error
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.Url == "" || config.AppId == 0 || config.AppKey == "" || config.AppSecret == "" || config.AppAccount == "" {
+		return nil, ErrInvalidConfig
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"appId":      config.AppId,
+		"appKey":     config.AppKey,
+		"appSecret":  config.AppSecret,
+		"appAccount": config.AppAccount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	this := &Provider{
+		url:        config.Url,
+		appAccount: config.AppAccount,
+		body:       body,
+		httpClient: config.HttpClient,
+		timeout:    config.Timeout,
+		maxRetries: config.MaxRetries,
+		backoff:    config.Backoff,
+		ttl:        config.TTL,
+	}
+	if this.httpClient == nil {
+		this.httpClient = http.DefaultClient
+	}
+	if this.timeout <= 0 {
+		this.timeout = DEFAULT_TIMEOUT
+	}
+	if this.maxRetries < 0 {
+		this.maxRetries = 0
+	} else if this.maxRetries == 0 {
+		this.maxRetries = DEFAULT_MAX_RETRIES
+	}
+	if this.backoff <= 0 {
+		this.backoff = DEFAULT_BACKOFF
+	}
+	if this.ttl <= 0 {
+		this.ttl = DEFAULT_TTL
+	}
+	return this, nil
+}
+
+// 实现mimc.Token，失败时返回nil，原因可以通过LastError获取
+func (this *Provider) FetchToken() *string {
+	token, err := this.Fetch(context.Background())
+	if err != nil {
+		return nil
+	}
+	return &token
+}
+
+// 获取token接口的完整响应，缓存未过期时直接返回缓存。请求受ctx和Timeout中较早的一个限制
+func (this *Provider) Fetch(ctx context.Context) (string, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.cached != nil && time.Now().Before(this.expireAt) {
+		return *this.cached, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, this.timeout)
+	defer cancel()
+	token, err := this.fetch(ctx)
+	this.lastError = err
+	if err != nil {
+		return "", err
+	}
+	this.cached = &token
+	this.expireAt = time.Now().Add(this.ttl)
+	return token, nil
+}
+
+// 丢弃缓存的token，下次获取时重新请求，token过期时由SDK调用
+func (this *Provider) Invalidate() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.cached = nil
+}
+
+func (this *Provider) LastError() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.lastError
+}
+
+func (this *Provider) fetch(ctx context.Context) (string, error) {
+	backoff := this.backoff
+	for retry := 0; ; retry++ {
+		token, err := this.request(ctx)
+		if err == nil || retry >= this.maxRetries || !retryable(err) {
+			return token, err
+		}
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > MAX_BACKOFF {
+			backoff = MAX_BACKOFF
+		}
+	}
+}
+
+// 网络错误和5xx可以重试，超时、校验失败和4xx不重试
+func retryable(err error) bool {
+	var httpError *HTTPError
+	if errors.As(err, &httpError) {
+		return httpError.StatusCode >= 500
+	}
+	var responseError *ResponseError
+	return !errors.As(err, &responseError) && err != ErrInvalidResponse && err != ErrAccountMismatch &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+func (this *Provider) request(ctx context.Context) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, this.url, bytes.NewReader(this.body))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := this.httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", &HTTPError{StatusCode: response.StatusCode, Body: string(data)}
+	}
+	if err := this.validate(data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// 校验响应包含MCUser登录需要的字段
+func (this *Provider) validate(data []byte) error {
+	var resp struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    *struct {
+			AppId             string  `json:"appId"`
+			AppPackage        string  `json:"appPackage"`
+			AppAccount        string  `json:"appAccount"`
+			MiChid            float64 `json:"miChid"`
+			MiUserId          string  `json:"miUserId"`
+			MiUserSecurityKey string  `json:"miUserSecurityKey"`
+			Token             string  `json:"token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return ErrInvalidResponse
+	}
+	if resp.Code != 200 {
+		return &ResponseError{Code: resp.Code, Message: resp.Message}
+	}
+	if resp.Data == nil || resp.Data.Token == "" || resp.Data.MiUserSecurityKey == "" {
+		return ErrInvalidResponse
+	}
+	if _, err := strconv.ParseInt(resp.Data.AppId, 10, 64); err != nil {
+		return ErrInvalidResponse
+	}
+	if _, err := strconv.ParseInt(resp.Data.MiUserId, 10, 64); err != nil {
+		return ErrInvalidResponse
+	}
+	if resp.Data.AppAccount != this.appAccount {
+		return ErrAccountMismatch
+	}
+	return nil
+}
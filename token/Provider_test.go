@@ -0,0 +1,108 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const tokenResponse = `{"code":200,"message":"success","data":{"appId":"7","appPackage":"com.demo","appAccount":"Alice","miChid":9,"miUserId":"100","miUserSecurityKey":"key","token":"t"}}`
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) (*Provider, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	provider, err := NewProvider(Config{Url: server.URL, AppId: 7, AppKey: "k", AppSecret: "s", AppAccount: "Alice", Backoff: time.Millisecond, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("new provider fail: %v", err)
+	}
+	return provider, server
+}
+
+func TestProviderRetryAndCache(t *testing.T) {
+	var calls int32
+	provider, server := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(tokenResponse))
+	})
+	defer server.Close()
+	if token := provider.FetchToken(); token == nil || *token != tokenResponse {
+		t.Fatalf("fetch token fail: %v", provider.LastError())
+	}
+	provider.FetchToken()
+	if calls != 3 {
+		t.Errorf("expect 2 retries and cached result, got %v calls", calls)
+	}
+	provider.Invalidate()
+	provider.FetchToken()
+	if calls != 4 {
+		t.Errorf("invalidate should refetch, got %v calls", calls)
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	var calls int32
+	response := `{"code":401,"message":"invalid app"}`
+	provider, server := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(response))
+	})
+	defer server.Close()
+	var responseError *ResponseError
+	if _, err := provider.Fetch(context.Background()); !errors.As(err, &responseError) || responseError.Code != 401 || calls != 1 {
+		t.Errorf("expect ResponseError without retry, got %v after %v calls", err, calls)
+	}
+	response = `{"code":200,"data":{"appId":"7","appAccount":"Bob","miUserId":"100","miUserSecurityKey":"key","token":"t"}}`
+	if _, err := provider.Fetch(context.Background()); err != ErrAccountMismatch {
+		t.Errorf("expect ErrAccountMismatch, got %v", err)
+	}
+	if provider.FetchToken() != nil || provider.LastError() != ErrAccountMismatch {
+		t.Errorf("FetchToken should fail with last error")
+	}
+	if _, err := NewProvider(Config{Url: server.URL}); err != ErrInvalidConfig {
+		t.Errorf("expect ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestProviderTimeout(t *testing.T) {
+	provider, server := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(300 * time.Millisecond):
+		}
+	})
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := provider.Fetch(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("fetch should return at deadline")
+	}
+}
+
+// 调用方的ctx没有截止时间时，Fetch同样受Timeout限制
+func TestProviderTimeoutWithoutDeadline(t *testing.T) {
+	provider, server := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+	})
+	defer server.Close()
+	provider.timeout = 50 * time.Millisecond
+	start := time.Now()
+	if _, err := provider.Fetch(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 400*time.Millisecond {
+		t.Errorf("fetch should return after Timeout")
+	}
+}
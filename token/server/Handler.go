@@ -0,0 +1,148 @@
+package server
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/token"
+)
+
+const DEFAULT_MAX_ACCOUNTS int = 100000
+
+var ErrUnauthorized = errors.New("token server: unauthorized")
+
+/**
+ * 认证应用自己的用户，返回该用户的appAccount。
+ * 返回错误时响应401，错误信息会返回给客户端。
+ */
+type Authenticator func(request *http.Request) (appAccount string, err error)
+
+/**
+ * Url、AppId、AppKey、AppSecret用于请求MIMC的token接口，AppSecret随每次请求发送给该接口，但不会返回给客户端。
+ * HttpClient、Timeout、MaxRetries、TTL传给每个appAccount的token.Provider，
+ * 每次请求MIMC的耗时不超过Timeout，客户端断开时提前结束。
+ * MaxAccounts为缓存token的appAccount数量上限，超过时淘汰最久未使用的。
+ */
+type Config struct {
+	Url          string
+	AppId        int64
+	AppKey       string
+	AppSecret    string
+	Authenticate Authenticator
+
+	HttpClient  *http.Client
+	Timeout     time.Duration
+	MaxRetries  int
+	TTL         time.Duration
+	MaxAccounts int
+}
+
+type entry struct {
+	appAccount string
+	provider   *token.Provider
+}
+
+/**
+ * 为客户端签发token的http.Handler，应用服务端认证用户后代为请求MIMC token，
+ * 响应体与MIMC token接口相同，可以直接交给MCUser登录。
+ */
+type Handler struct {
+	config Config
+
+	mu        sync.Mutex
+	providers map[string]*list.Element
+	lru       *list.List
+}
+
+func NewHandler(config Config) (*Handler, error) {
+	if config.Authenticate == nil {
+		return nil, token.ErrInvalidConfig
+	}
+	// 提前校验MIMC配置
+	if _, err := token.NewProvider(token.Config{Url: config.Url, AppId: config.AppId, AppKey: config.AppKey, AppSecret: config.AppSecret, AppAccount: "-"}); err != nil {
+		return nil, err
+	}
+	if config.MaxAccounts <= 0 {
+		config.MaxAccounts = DEFAULT_MAX_ACCOUNTS
+	}
+	return &Handler{config: config, providers: make(map[string]*list.Element), lru: list.New()}, nil
+}
+
+func (this *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	appAccount, err := this.config.Authenticate(r)
+	if err == nil && appAccount == "" {
+		err = ErrUnauthorized
+	}
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	provider, err := this.provider(appAccount)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response, err := provider.Fetch(r.Context())
+	if err != nil {
+		// 不把MIMC接口的错误细节返回给客户端
+		writeError(w, http.StatusBadGateway, "fetch token fail")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(response))
+}
+
+// 丢弃appAccount缓存的token，客户端报告token过期时调用
+func (this *Handler) Invalidate(appAccount string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if element, ok := this.providers[appAccount]; ok {
+		element.Value.(*entry).provider.Invalidate()
+	}
+}
+
+func (this *Handler) provider(appAccount string) (*token.Provider, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if element, ok := this.providers[appAccount]; ok {
+		this.lru.MoveToFront(element)
+		return element.Value.(*entry).provider, nil
+	}
+	provider, err := token.NewProvider(token.Config{
+		Url:        this.config.Url,
+		AppId:      this.config.AppId,
+		AppKey:     this.config.AppKey,
+		AppSecret:  this.config.AppSecret,
+		AppAccount: appAccount,
+		HttpClient: this.config.HttpClient,
+		Timeout:    this.config.Timeout,
+		MaxRetries: this.config.MaxRetries,
+		TTL:        this.config.TTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	this.providers[appAccount] = this.lru.PushFront(&entry{appAccount, provider})
+	for this.lru.Len() > this.config.MaxAccounts {
+		oldest := this.lru.Back()
+		this.lru.Remove(oldest)
+		delete(this.providers, oldest.Value.(*entry).appAccount)
+	}
+	return provider, nil
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	body, _ := json.Marshal(map[string]interface{}{"code": statusCode, "message": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 模拟MIMC token接口，校验appSecret并返回请求中的appAccount
+func newMIMCServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		if request["appSecret"] != "secret" {
+			w.Write([]byte(`{"code":500,"message":"invalid secret"}`))
+			return
+		}
+		body, _ := json.Marshal(map[string]interface{}{"code": 200, "message": "success", "data": map[string]interface{}{
+			"appId": "7", "appPackage": "com.demo", "appAccount": request["appAccount"], "miChid": 9,
+			"miUserId": "100", "miUserSecurityKey": "key", "token": "t",
+		}})
+		w.Write(body)
+	}))
+}
+
+func TestHandler(t *testing.T) {
+	var calls int32
+	mimcServer := newMIMCServer(&calls)
+	defer mimcServer.Close()
+	handler, err := NewHandler(Config{
+		Url: mimcServer.URL, AppId: 7, AppKey: "key", AppSecret: "secret", MaxAccounts: 1,
+		Authenticate: func(r *http.Request) (string, error) {
+			if r.Header.Get("Authorization") == "" {
+				return "", errors.New("missing session")
+			}
+			return r.Header.Get("Authorization"), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("new handler fail: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	fetch := func(account string) (int, map[string]interface{}) {
+		request, _ := http.NewRequest(http.MethodPost, server.URL, nil)
+		if account != "" {
+			request.Header.Set("Authorization", account)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("request fail: %v", err)
+		}
+		defer response.Body.Close()
+		data, _ := io.ReadAll(response.Body)
+		var body map[string]interface{}
+		json.Unmarshal(data, &body)
+		return response.StatusCode, body
+	}
+
+	if status, _ := fetch(""); status != http.StatusUnauthorized {
+		t.Errorf("expect 401, got %v", status)
+	}
+	status, body := fetch("Alice")
+	if status != http.StatusOK || body["data"].(map[string]interface{})["appAccount"] != "Alice" {
+		t.Fatalf("unexpected response: %v, %v", status, body)
+	}
+	fetch("Alice")
+	if calls != 1 {
+		t.Errorf("token should be cached, got %v calls", calls)
+	}
+	handler.Invalidate("Alice")
+	fetch("Alice")
+	fetch("Bob")
+	fetch("Alice")
+	if calls != 4 {
+		t.Errorf("expect refetch after invalidate and eviction, got %v calls", calls)
+	}
+}
+
+// MIMC接口没有响应时，请求在Timeout后返回502，不会一直挂起
+func TestHandlerStalledUpstream(t *testing.T) {
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+	}))
+	defer stalled.Close()
+	handler, err := NewHandler(Config{
+		Url: stalled.URL, AppId: 7, AppKey: "key", AppSecret: "secret", Timeout: 50 * time.Millisecond,
+		Authenticate: func(r *http.Request) (string, error) { return "Alice", nil },
+	})
+	if err != nil {
+		t.Fatalf("new handler fail: %v", err)
+	}
+	start := time.Now()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	if recorder.Code != http.StatusBadGateway {
+		t.Errorf("expect 502, got %v", recorder.Code)
+	}
+	if time.Since(start) > 400*time.Millisecond {
+		t.Errorf("request should give up after Timeout")
+	}
+}
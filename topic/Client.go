@@ -0,0 +1,85 @@
+package topic
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/rest"
+)
+
+const DEFAULT_TIMEOUT = rest.DEFAULT_TIMEOUT
+
+/**
+ * MIMC群管理接口的客户端，请求头中携带用户登录使用的token。
+ * token在每次请求时通过tokenFunc获取，重新登录后不需要重建客户端。
+ */
+type Client struct {
+	appId int64
+	rest  *rest.Client
+}
+
+func NewClient(baseUrl string, appId int64, tokenFunc func() string) *Client {
+	return &Client{appId: appId, rest: rest.NewClient(baseUrl, tokenFunc)}
+}
+
+func (this *Client) SetHttpClient(httpClient *http.Client) *Client {
+	this.rest.SetHttpClient(httpClient)
+	return this
+}
+
+// 创建群，accounts为创建时加入的成员，创建者自动成为群主
+func (this *Client) Create(topicName string, accounts []string, extra string) (*Topic, error) {
+	body := map[string]string{"topicName": topicName, "accounts": strings.Join(accounts, ","), "extra": extra}
+	topic := new(Topic)
+	return topic, this.rest.Do(http.MethodPost, this.path(), body, topic)
+}
+
+func (this *Client) Get(topicId int64) (*Topic, error) {
+	topic := new(Topic)
+	return topic, this.rest.Do(http.MethodGet, this.path(topicId), nil, topic)
+}
+
+// 查询当前用户加入的所有群
+func (this *Client) Joined() ([]Info, error) {
+	var infos []Info
+	return infos, this.rest.Do(http.MethodGet, this.path()+"/account", nil, &infos)
+}
+
+func (this *Client) Join(topicId int64, accounts []string) (*Topic, error) {
+	topic := new(Topic)
+	body := map[string]string{"accounts": strings.Join(accounts, ",")}
+	return topic, this.rest.Do(http.MethodPost, this.path(topicId)+"/accounts", body, topic)
+}
+
+// 当前用户退出群
+func (this *Client) Quit(topicId int64) (*Topic, error) {
+	topic := new(Topic)
+	return topic, this.rest.Do(http.MethodDelete, this.path(topicId)+"/account", nil, topic)
+}
+
+// 群主把accounts移出群
+func (this *Client) Kick(topicId int64, accounts []string) (*Topic, error) {
+	topic := new(Topic)
+	query := "?accounts=" + url.QueryEscape(strings.Join(accounts, ","))
+	return topic, this.rest.Do(http.MethodDelete, this.path(topicId)+"/accounts"+query, nil, topic)
+}
+
+func (this *Client) Update(topicId int64, update Update) (*Topic, error) {
+	topic := new(Topic)
+	return topic, this.rest.Do(http.MethodPut, this.path(topicId), update, topic)
+}
+
+// 群主解散群
+func (this *Client) Dismiss(topicId int64) error {
+	return this.rest.Do(http.MethodDelete, this.path(topicId), nil, nil)
+}
+
+func (this *Client) path(topicId ...int64) string {
+	path := "/api/topic/" + strconv.FormatInt(this.appId, 10)
+	for _, id := range topicId {
+		path += "/" + strconv.FormatInt(id, 10)
+	}
+	return path
+}
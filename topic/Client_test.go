@@ -0,0 +1,120 @@
+package topic
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 模拟MIMC群管理接口，token即为appAccount
+type fakeServer struct {
+	mu     sync.Mutex
+	nextId int64
+	topics map[string]*Topic
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{nextId: 1000, topics: make(map[string]*Topic)}
+}
+
+func (this *fakeServer) reply(w http.ResponseWriter, code int, message string, data interface{}) {
+	body, _ := json.Marshal(map[string]interface{}{"code": code, "message": message, "data": data})
+	w.Write(body)
+}
+
+func (this *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	account := r.Header.Get("token")
+	if account == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		this.reply(w, 401, "invalid token", nil)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/topic/7/"), "/")
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+	if r.URL.Path == "/api/topic/7" && r.Method == http.MethodPost {
+		this.nextId += 1
+		topic := &Topic{Info: Info{TopicId: this.nextId, OwnerAccount: account, TopicName: body["topicName"], Extra: body["extra"]}}
+		topic.Members = append(topic.Members, Member{Account: account})
+		for _, member := range strings.Split(body["accounts"], ",") {
+			topic.Members = append(topic.Members, Member{Account: member})
+		}
+		this.topics[strconv.FormatInt(this.nextId, 10)] = topic
+		this.reply(w, 200, "success", topic)
+		return
+	}
+	topic, ok := this.topics[parts[0]]
+	if !ok {
+		this.reply(w, 404, "topic not exist", nil)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		this.reply(w, 200, "success", topic)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if topic.Info.OwnerAccount != account {
+			this.reply(w, 403, "not topic owner", nil)
+			return
+		}
+		delete(this.topics, parts[0])
+		this.reply(w, 200, "success", nil)
+	case len(parts) == 2 && parts[1] == "accounts" && r.Method == http.MethodDelete:
+		kicked := "," + r.URL.Query().Get("accounts") + ","
+		remain := topic.Members[:0]
+		for _, member := range topic.Members {
+			if !strings.Contains(kicked, ","+member.Account+",") {
+				remain = append(remain, member)
+			}
+		}
+		topic.Members = remain
+		this.reply(w, 200, "success", topic)
+	default:
+		this.reply(w, 400, "unsupported", nil)
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(newFakeServer())
+	defer server.Close()
+	token := "Alice"
+	client := NewClient(server.URL+"/", 7, func() string { return token })
+
+	topic, err := client.Create("team", []string{"Bob", "Carol"}, "x")
+	if err != nil {
+		t.Fatalf("create fail: %v", err)
+	}
+	if topic.Info.TopicId != 1001 || topic.Info.TopicName != "team" || strings.Join(topic.Accounts(), ",") != "Alice,Bob,Carol" {
+		t.Fatalf("unexpected topic: %+v", topic)
+	}
+	topic, err = client.Kick(1001, []string{"Bob"})
+	if err != nil || strings.Join(topic.Accounts(), ",") != "Alice,Carol" {
+		t.Fatalf("kick mismatch: %+v, %v", topic, err)
+	}
+	if topic, err = client.Get(1001); err != nil || len(topic.Members) != 2 {
+		t.Fatalf("get mismatch: %+v, %v", topic, err)
+	}
+
+	token = "Carol"
+	var serverError *Error
+	if err := client.Dismiss(1001); !errors.As(err, &serverError) || serverError.Code != 403 {
+		t.Errorf("expect 403 error, got %v", err)
+	}
+	token = "Alice"
+	if err := client.Dismiss(1001); err != nil {
+		t.Fatalf("dismiss fail: %v", err)
+	}
+	if _, err := client.Get(1001); !errors.As(err, &serverError) || serverError.Code != 404 {
+		t.Errorf("expect 404 error, got %v", err)
+	}
+	token = ""
+	if _, err := client.Get(1001); err != ErrNoToken {
+		t.Errorf("expect ErrNoToken, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package topic
+
+import (
+	"github.com/Xiaomi-mimc/mimc-go-sdk/util/rest"
+)
+
+var (
+	ErrNoToken         = rest.ErrNoToken
+	ErrInvalidResponse = rest.ErrInvalidResponse
+)
+
+// 服务端返回的错误，StatusCode为HTTP状态码，Code和Message来自响应体
+type Error = rest.Error
+
+type Info struct {
+	TopicId      int64  `json:"topicId,string"`
+	OwnerUuid    int64  `json:"ownerUuid,string"`
+	OwnerAccount string `json:"ownerAccount"`
+	TopicName    string `json:"topicName"`
+	Bulletin     string `json:"bulletin"`
+	Extra        string `json:"extra"`
+	CreateTime   int64  `json:"createTime,string,omitempty"`
+	UpdateTime   int64  `json:"updateTime,string,omitempty"`
+}
+
+type Member struct {
+	Uuid    int64  `json:"uuid,string"`
+	Account string `json:"account"`
+}
+
+type Topic struct {
+	Info    Info     `json:"topicInfo"`
+	Members []Member `json:"members"`
+}
+
+func (this *Topic) Accounts() []string {
+	accounts := make([]string, 0, len(this.Members))
+	for _, member := range this.Members {
+		accounts = append(accounts, member.Account)
+	}
+	return accounts
+}
+
+// 修改群信息，为空的字段不修改
+type Update struct {
+	TopicName    string `json:"topicName,omitempty"`
+	OwnerAccount string `json:"ownerAccount,omitempty"`
+	Bulletin     string `json:"bulletin,omitempty"`
+	Extra        string `json:"extra,omitempty"`
+}
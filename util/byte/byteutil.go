@@ -14,7 +14,11 @@ func ToBytes(int8s []int8) []byte {
 	return bytes
 }
 
+// 复制src[from:from+length]，越界时返回nil
 func Copy(src *[]byte, from, length int) []byte {
+	if src == nil || from < 0 || length < 0 || from+length > len(*src) {
+		return nil
+	}
 	dst := make([]byte, length)
 	for i := 0; i < length; i++ {
 		dst[i] = (*src)[from+i]
@@ -4,20 +4,13 @@ import (
 	"github.com/Xiaomi-mimc/mimc-go-sdk/common/constant"
 	"github.com/Xiaomi-mimc/mimc-go-sdk/util/string"
 	"strconv"
-	"sync"
+	"sync/atomic"
 )
 
-var idGenerator *IdGenerator
-var lock *sync.Mutex = &sync.Mutex{}
+// 多个用户的发送goroutine会同时生成id，计数器通过atomic递增
+var idGenerator = &IdGenerator{}
 
 func Generate() *string {
-	if idGenerator == nil {
-		lock.Lock()
-		defer lock.Unlock()
-		if idGenerator == nil {
-			idGenerator = &IdGenerator{}
-		}
-	}
 	id := strutil.RandomStrWithLength(10) + "_" + *(idGenerator.generate())
 	return &id
 }
@@ -27,7 +20,7 @@ type IdGenerator struct {
 }
 
 func (this *IdGenerator) generate() *string {
-	this.counter += uint64(cnst.MIMC_COUNTER_VALUE)
-	str := strconv.FormatUint(this.counter, 10)
+	counter := atomic.AddUint64(&this.counter, uint64(cnst.MIMC_COUNTER_VALUE))
+	str := strconv.FormatUint(counter, 10)
 	return &str
 }
@@ -0,0 +1,152 @@
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+// 单行记录的最大长度
+const maxLineSize = 64 * 1024 * 1024
+
+/**
+ * 以JSON Lines格式追加记录的文件，消息存储和会话存储共用。
+ * 打开时逐行交给replay重放，进程崩溃导致的不完整行由replay解析失败后忽略。
+ * 不是并发安全的，调用方需要自己加锁。
+ */
+type AppendLog struct {
+	path string
+	file *os.File
+	size int64
+}
+
+func Open(path string, replay func(line []byte)) (*AppendLog, error) {
+	this := &AppendLog{path: path}
+	if err := this.load(replay); err != nil {
+		return nil, err
+	}
+	if err := this.openForAppend(); err != nil {
+		return nil, err
+	}
+	if err := this.terminateLastLine(); err != nil {
+		this.file.Close()
+		return nil, err
+	}
+	return this, nil
+}
+
+func (this *AppendLog) load(replay func(line []byte)) error {
+	file, err := os.Open(this.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		replay(scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+func (this *AppendLog) openForAppend() error {
+	file, err := os.OpenFile(this.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	this.file = file
+	this.size = info.Size()
+	return nil
+}
+
+// 上次写入不完整时补上换行，避免新记录接在不完整的行后面
+func (this *AppendLog) terminateLastLine() error {
+	if this.size == 0 {
+		return nil
+	}
+	reader, err := os.Open(this.path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	last := make([]byte, 1)
+	if _, err := reader.ReadAt(last, this.size-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = this.write([]byte{'\n'})
+	}
+	return err
+}
+
+func (this *AppendLog) write(data []byte) (int, error) {
+	n, err := this.file.Write(data)
+	this.size += int64(n)
+	return n, err
+}
+
+// 追加一条记录
+func (this *AppendLog) Append(record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = this.write(append(data, '\n'))
+	return err
+}
+
+// 文件当前的字节数
+func (this *AppendLog) Size() int64 {
+	return this.size
+}
+
+// 用records重写文件，先写入临时文件再替换，失败时原文件不变
+func (this *AppendLog) Rewrite(records []interface{}) error {
+	tmpPath := this.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err == nil {
+			writer.Write(data)
+			err = writer.WriteByte('\n')
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, this.path); err != nil {
+		return err
+	}
+	this.file.Close()
+	return this.openForAppend()
+}
+
+func (this *AppendLog) Close() error {
+	if err := this.file.Sync(); err != nil {
+		this.file.Close()
+		return err
+	}
+	return this.file.Close()
+}
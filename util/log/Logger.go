@@ -1,10 +1,14 @@
 package log
 
 import (
+	"context"
 	"fmt"
-	syslog "log"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
 	"sync"
+	"time"
 )
 
 type LogLevel int
@@ -18,66 +22,153 @@ const (
 	FatalLevel
 )
 
-type Logger struct {
-	level LogLevel
-	log   *syslog.Logger
+// 结构化日志字段名
+const (
+	FieldAppAccount = "appAccount"
+	FieldResource   = "resource"
+	FieldPacketId   = "packetId"
+	FieldCmd        = "cmd"
+)
+
+/**
+ * SDK内部统一使用的日志接口，format/args与fmt.Sprintf一致，
+ * With返回附带了结构化字段(key, value交替)的新Logger，原Logger不受影响。
+ */
+type Logger interface {
+	Debug(format string, args ...interface{})
+	Info(format string, args ...interface{})
+	Warn(format string, args ...interface{})
+	Error(format string, args ...interface{})
+	With(kvs ...interface{}) Logger
 }
 
-var lock sync.Mutex
-var level = InfoLevel
-var log *Logger = nil
-var logPath = "./mimc.log"
+var lock sync.RWMutex
+var level = new(slog.LevelVar)
+var log Logger = nil
 
-func SetLogPath(path string) {
-	logPath = path
+func init() {
+	level.Set(slog.LevelInfo)
 }
+
+// 设置默认Logger的日志级别，可以在任意时刻调用
 func SetLogLevel(lvl LogLevel) {
-	level = lvl
-	fmt.Printf("setLogLevel: %d, %d\n", level, lvl)
-}
-
-func GetLogger() *Logger {
-	if log == nil {
-		lock.Lock()
-		if log == nil {
-			log = new(Logger)
-			logFile, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0766)
-			if nil != err {
-				panic(err)
-			}
-			log.level = level
-			fmt.Printf("GetLogger: %d, %d\n", level, level)
-			log.log = syslog.New(logFile, "\r\n", syslog.Ldate|syslog.Ltime|syslog.Lshortfile)
-		}
-		lock.Unlock()
+	level.Set(toSlogLevel(lvl))
+}
+
+// 替换SDK的默认Logger，传nil恢复为基于slog.Default()的Logger
+func SetLogger(logger Logger) {
+	lock.Lock()
+	defer lock.Unlock()
+	log = logger
+}
+
+// 将默认Logger输出到文件，兼容旧版本行为
+func SetLogPath(path string) error {
+	logFile, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
 	}
-	return log
+	handler := slog.NewTextHandler(logFile, &slog.HandlerOptions{Level: level, AddSource: true})
+	SetLogger(NewSlogLogger(slog.New(handler)))
+	return nil
 }
 
-func (this *Logger) Info(format string, args ...interface{}) {
-	if this.level <= InfoLevel {
-		this.log.SetPrefix("[info] ")
-		this.log.Output(2, fmt.Sprintf(format, args...))
+func GetLogger() Logger {
+	lock.RLock()
+	logger := log
+	lock.RUnlock()
+	if logger == nil {
+		return defaultLogger
 	}
+	return logger
 }
 
-func (this *Logger) Debug(format string, args ...interface{}) {
-	if this.level <= DebugLevel {
-		this.log.SetPrefix("[debug]")
-		this.log.Output(2, fmt.Sprintf(format, args...))
+func toSlogLevel(lvl LogLevel) slog.Level {
+	switch lvl {
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
 	}
 }
 
-func (this *Logger) Warn(format string, args ...interface{}) {
-	if this.level <= WarnLevel {
-		this.log.SetPrefix("[warn]")
-		this.log.Output(2, fmt.Sprintf(format, args...))
+// 默认Logger，每次输出时使用当前的slog.Default()，并受SetLogLevel控制
+var defaultLogger Logger = &SlogLogger{level: level}
+
+type SlogLogger struct {
+	logger *slog.Logger
+	level  slog.Leveler
+	attrs  []interface{}
+}
+
+// 基于log/slog的Logger，日志级别由handler自身决定
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{logger: logger}
+}
+
+func (this *SlogLogger) With(kvs ...interface{}) Logger {
+	if len(kvs) == 0 {
+		return this
 	}
+	attrs := make([]interface{}, 0, len(this.attrs)+len(kvs))
+	attrs = append(attrs, this.attrs...)
+	attrs = append(attrs, kvs...)
+	return &SlogLogger{logger: this.logger, level: this.level, attrs: attrs}
+}
+
+func (this *SlogLogger) Debug(format string, args ...interface{}) {
+	this.output(slog.LevelDebug, format, args)
 }
 
-func (this *Logger) Error(format string, args ...interface{}) {
-	if this.level <= ErrorLevel {
-		this.log.SetPrefix("[error]")
-		this.log.Output(2, fmt.Sprintf(format, args...))
+func (this *SlogLogger) Info(format string, args ...interface{}) {
+	this.output(slog.LevelInfo, format, args)
+}
+
+func (this *SlogLogger) Warn(format string, args ...interface{}) {
+	this.output(slog.LevelWarn, format, args)
+}
+
+func (this *SlogLogger) Error(format string, args ...interface{}) {
+	this.output(slog.LevelError, format, args)
+}
+
+func (this *SlogLogger) output(lvl slog.Level, format string, args []interface{}) {
+	if this.level != nil && lvl < this.level.Level() {
+		return
+	}
+	logger := this.logger
+	if logger == nil {
+		logger = slog.Default()
 	}
+	ctx := context.Background()
+	if !logger.Enabled(ctx, lvl) {
+		return
+	}
+	// 跳过runtime.Callers, output以及Info等方法本身，定位到调用方
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	record := slog.NewRecord(time.Now(), lvl, msg, pcs[0])
+	record.Add(this.attrs...)
+	logger.Handler().Handle(ctx, record)
+}
+
+type nopLogger struct {
+}
+
+// 丢弃所有日志
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (this nopLogger) Debug(format string, args ...interface{}) {}
+func (this nopLogger) Info(format string, args ...interface{})  {}
+func (this nopLogger) Warn(format string, args ...interface{})  {}
+func (this nopLogger) Error(format string, args ...interface{}) {}
+func (this nopLogger) With(kvs ...interface{}) Logger {
+	return this
 }
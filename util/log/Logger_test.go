@@ -1,6 +1,9 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"testing"
 )
 
@@ -11,3 +14,44 @@ func TestInfoLogger(test *testing.T) {
 	logger.Info("%s.\n", "hello world1")
 	logger.Warn("%s.\n", "hello world2")
 }
+
+func TestSlogLoggerFields(test *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(buffer, nil)))
+	logger.With(FieldAppAccount, "Alice").With(FieldPacketId, "abc_1").Info("send %v.\n", "msg")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		test.Fatalf("unmarshal log record fail: %v, %s", err, buffer.String())
+	}
+	if record["msg"] != "send msg." {
+		test.Errorf("unexpected msg: %v", record["msg"])
+	}
+	if record[FieldAppAccount] != "Alice" || record[FieldPacketId] != "abc_1" {
+		test.Errorf("missing fields: %v", record)
+	}
+}
+
+func TestDefaultLoggerLevel(test *testing.T) {
+	buffer := new(bytes.Buffer)
+	origin := slog.Default()
+	defer slog.SetDefault(origin)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	SetLogLevel(WarnLevel)
+	defer SetLogLevel(InfoLevel)
+	GetLogger().Info("dropped")
+	if buffer.Len() != 0 {
+		test.Errorf("info should be filtered: %s", buffer.String())
+	}
+	GetLogger().Warn("kept")
+	if buffer.Len() == 0 {
+		test.Errorf("warn should be written")
+	}
+}
+
+func TestNopLogger(test *testing.T) {
+	SetLogger(NewNopLogger())
+	defer SetLogger(nil)
+	GetLogger().With(FieldCmd, "PING").Error("%v", "ignored")
+}
@@ -34,6 +34,12 @@ func (this *ConMap) Pop(key interface{}) interface{} {
 	}
 }
 
+func (this *ConMap) Size() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return len(this.kvs)
+}
+
 func (this *ConMap) Lock() *ConMap {
 	this.mu.Lock()
 	return this
@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DEFAULT_TIMEOUT = 10 * time.Second
+
+var (
+	ErrNoToken         = errors.New("mimc rest: token is empty, login first")
+	ErrInvalidResponse = errors.New("mimc rest: invalid response")
+)
+
+// 服务端返回的错误，StatusCode为HTTP状态码，Code和Message来自响应体
+type Error struct {
+	StatusCode int
+	Code       int
+	Message    string
+}
+
+func (this *Error) Error() string {
+	return fmt.Sprintf("mimc rest: status %v, code %v, %v", this.StatusCode, this.Code, this.Message)
+}
+
+type response struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+/**
+ * MIMC REST接口的客户端，请求头中携带用户登录使用的token。
+ * token在每次请求时通过tokenFunc获取，重新登录后不需要重建客户端。
+ */
+type Client struct {
+	baseUrl    string
+	tokenFunc  func() string
+	httpClient *http.Client
+}
+
+func NewClient(baseUrl string, tokenFunc func() string) *Client {
+	return &Client{
+		baseUrl:    strings.TrimRight(baseUrl, "/"),
+		tokenFunc:  tokenFunc,
+		httpClient: &http.Client{Timeout: DEFAULT_TIMEOUT},
+	}
+}
+
+func (this *Client) SetHttpClient(httpClient *http.Client) *Client {
+	this.httpClient = httpClient
+	return this
+}
+
+/**
+ * 发送请求并把响应中的data解析到result，body不为nil时以JSON发送。
+ * HTTP状态码不是200或响应code不是200时返回*Error。
+ */
+func (this *Client) Do(method, path string, body interface{}, result interface{}) error {
+	token := ""
+	if this.tokenFunc != nil {
+		token = this.tokenFunc()
+	}
+	if token == "" {
+		return ErrNoToken
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, this.baseUrl+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("token", token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	httpResponse, err := this.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+	data, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+	resp := new(response)
+	if err := json.Unmarshal(data, resp); err != nil {
+		if httpResponse.StatusCode != http.StatusOK {
+			return &Error{StatusCode: httpResponse.StatusCode, Message: strings.TrimSpace(string(data))}
+		}
+		return ErrInvalidResponse
+	}
+	if httpResponse.StatusCode != http.StatusOK || resp.Code != 200 {
+		return &Error{StatusCode: httpResponse.StatusCode, Code: resp.Code, Message: resp.Message}
+	}
+	if result == nil || len(resp.Data) == 0 || string(resp.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Data, result); err != nil {
+		return ErrInvalidResponse
+	}
+	return nil
+}